@@ -2,13 +2,22 @@ package log_v1
 
 import (
 	"fmt"
+	"time"
 
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/status"
 )
 
+// 送り直せばよいエラーのステータスにはRetryInfoを付ける。クライアントはRetryInfoがあれば、
+// その時間を待って送り直せる。リーダーがわかっていればErrorInfoのleader_addrに入れる。
+func retryInfo(delay time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+}
+
 type ErrOffsetOutOfRange struct {
 	Offset uint64
 }
@@ -34,3 +43,505 @@ func (e ErrOffsetOutOfRange) GRPCStatus() *status.Status {
 func (e ErrOffsetOutOfRange) Error() string {
 	return e.GRPCStatus().Err().Error()
 }
+
+// ErrOffsetBeforeLowest は読み出しを始めるオフセットが、保持期間などで削除されたログの先頭より前であることを表す。
+// 詳細のErrorInfoに、読み出せるオフセットの範囲を入れる。
+type ErrOffsetBeforeLowest struct {
+	Offset uint64
+	// ログに残っている最も古いオフセットと、次に追加されるオフセット
+	LowestOffset uint64
+	NextOffset   uint64
+}
+
+func (e ErrOffsetBeforeLowest) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.OutOfRange,
+		fmt.Sprintf("offset before lowest offset: %d < %d", e.Offset, e.LowestOffset),
+	)
+	msg := fmt.Sprintf(
+		"The requested offset %d was removed from the log. Valid offsets are from %d up to %d",
+		e.Offset, e.LowestOffset, e.NextOffset,
+	)
+
+	std, err := st.WithDetails(
+		&errdetails.LocalizedMessage{
+			Locale:  "en-US",
+			Message: msg,
+		},
+		&errdetails.ErrorInfo{
+			Reason: "OFFSET_BEFORE_LOWEST",
+			Metadata: map[string]string{
+				"lowest_offset": fmt.Sprintf("%d", e.LowestOffset),
+				"next_offset":   fmt.Sprintf("%d", e.NextOffset),
+			},
+		},
+	)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrOffsetBeforeLowest) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrCorruptRecord はディスク上のレコードのチェックサムが一致しない、
+// もしくはレコードが途中で途切れていることを表す。
+type ErrCorruptRecord struct {
+	Offset uint64
+}
+
+func (e ErrCorruptRecord) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.DataLoss,
+		fmt.Sprintf("record corrupted: %d", e.Offset),
+	)
+	msg := fmt.Sprintf("The record at offset %d failed its checksum and can't be served", e.Offset)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrCorruptRecord) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrCompacted はオフセットはログの範囲内だが、そのレコードが圧縮で削除済みであることを表す。
+type ErrCompacted struct {
+	Offset uint64
+}
+
+func (e ErrCompacted) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("offset compacted: %d", e.Offset),
+	)
+	msg := fmt.Sprintf("The record at offset %d was removed by log compaction", e.Offset)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrCompacted) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrNotLeader はリーダーでなければ処理できないリクエストを、リーダーでないノードが受け取ったことを表す。
+// LeaderAddrはわかっていればリーダーのRPCアドレスで、クライアントはそこに送り直せる。
+type ErrNotLeader struct {
+	LeaderAddr string
+}
+
+func (e ErrNotLeader) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("not the leader: leader is %q", e.LeaderAddr),
+	)
+	msg := "This server is not the leader. Retry the request against the leader"
+	if e.LeaderAddr != "" {
+		msg = fmt.Sprintf("%s at %s", msg, e.LeaderAddr)
+	}
+
+	// リーダーにはすぐに送り直せる
+	std, err := st.WithDetails(
+		&errdetails.LocalizedMessage{
+			Locale:  "en-US",
+			Message: msg,
+		},
+		&errdetails.ErrorInfo{
+			Reason:   "NOT_LEADER",
+			Metadata: map[string]string{"leader_addr": e.LeaderAddr},
+		},
+		retryInfo(0),
+	)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrNotLeader) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrStaleReadで送り直すまでに待つ時間
+const staleReadRetryDelay = 100 * time.Millisecond
+
+// ErrStaleRead はノードが指定されたオフセットのレコードをまだ適用していないことを表す。
+// 時間をおくか、別のノードに送り直せば読み出せる。
+type ErrStaleRead struct {
+	MinOffset uint64
+}
+
+func (e ErrStaleRead) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.Unavailable,
+		fmt.Sprintf("offset not applied yet: %d", e.MinOffset),
+	)
+	msg := fmt.Sprintf("This server has not applied offset %d yet. Retry later or against another server", e.MinOffset)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d, retryInfo(staleReadRetryDelay))
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrStaleRead) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrTopicNotFound は指定されたトピックが存在しないことを表す。
+type ErrTopicNotFound struct {
+	Topic string
+}
+
+func (e ErrTopicNotFound) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("topic not found: %q", e.Topic),
+	)
+	msg := fmt.Sprintf("The topic %q does not exist. Create it with CreateTopic first", e.Topic)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrTopicNotFound) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrTopicExists は作成しようとしたトピックがすでに存在することを表す。
+type ErrTopicExists struct {
+	Topic string
+}
+
+func (e ErrTopicExists) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.AlreadyExists,
+		fmt.Sprintf("topic already exists: %q", e.Topic),
+	)
+	msg := fmt.Sprintf("The topic %q already exists", e.Topic)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrTopicExists) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrInvalidTopic はトピックの名前が使えないものであるか、デフォルトのトピックを削除しようとしたことを表す。
+type ErrInvalidTopic struct {
+	Topic  string
+	Reason string
+}
+
+func (e ErrInvalidTopic) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.InvalidArgument,
+		fmt.Sprintf("invalid topic %q: %s", e.Topic, e.Reason),
+	)
+	msg := fmt.Sprintf("The topic %q can't be used: %s", e.Topic, e.Reason)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrInvalidTopic) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrPartitionNotFound は指定されたパーティションがトピックにないことを表す。
+type ErrPartitionNotFound struct {
+	Topic     string
+	Partition uint32
+}
+
+func (e ErrPartitionNotFound) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("partition not found: %q/%d", e.Topic, e.Partition),
+	)
+	msg := fmt.Sprintf("The topic %q has no partition %d", e.Topic, e.Partition)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrPartitionNotFound) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrNoCommittedOffset はコンシューマーグループがパーティションにオフセットをコミットしていないことを表す。
+type ErrNoCommittedOffset struct {
+	Group     string
+	Topic     string
+	Partition uint32
+}
+
+func (e ErrNoCommittedOffset) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("no committed offset: %q for %q/%d", e.Group, e.Topic, e.Partition),
+	)
+	msg := fmt.Sprintf("The group %q hasn't committed an offset for partition %d of the topic %q", e.Group, e.Partition, e.Topic)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrNoCommittedOffset) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrInvalidGroup はコンシューマーグループの名前が使えないことを表す。
+type ErrInvalidGroup struct {
+	Group string
+}
+
+func (e ErrInvalidGroup) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.InvalidArgument,
+		fmt.Sprintf("invalid group: %q", e.Group),
+	)
+	msg := "Consumer group names must not be empty"
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrInvalidGroup) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrOutOfOrderSequence はプロデューサーの連番が、次に追加する番号でも再送された番号でもないことを表す。
+type ErrOutOfOrderSequence struct {
+	ProducerID uint64
+	Sequence   uint64
+	Expected   uint64
+}
+
+func (e ErrOutOfOrderSequence) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.Aborted,
+		fmt.Sprintf("out of order sequence: producer %d sent %d, expected %d", e.ProducerID, e.Sequence, e.Expected),
+	)
+	msg := fmt.Sprintf(
+		"The producer %d sent sequence %d but the partition expected %d",
+		e.ProducerID, e.Sequence, e.Expected,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrOutOfOrderSequence) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrUnavailable はリーダーの交代中やノードの停止中などで、一時的に処理できないことを表す。
+// 書き込みはされていないか、されていてもプロデューサーのIDがあれば送り直して重複しない。
+type ErrUnavailable struct {
+	Reason string
+	// わかっていれば、送り直す先のリーダーのRPCアドレス
+	LeaderAddr string
+	RetryDelay time.Duration
+}
+
+func (e ErrUnavailable) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.Unavailable,
+		fmt.Sprintf("unavailable: %s", e.Reason),
+	)
+	msg := fmt.Sprintf("The server is temporarily unavailable (%s). Retry the request", e.Reason)
+	if e.LeaderAddr != "" {
+		msg = fmt.Sprintf("%s against the leader at %s", msg, e.LeaderAddr)
+	}
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d, retryInfo(e.RetryDelay))
+	if e.LeaderAddr != "" {
+		std, err = st.WithDetails(d, retryInfo(e.RetryDelay), &errdetails.ErrorInfo{
+			Reason:   "UNAVAILABLE",
+			Metadata: map[string]string{"leader_addr": e.LeaderAddr},
+		})
+	}
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrUnavailable) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrTimeout はRaftへのエントリの追加などが時間内に終わらなかったことを表す。
+type ErrTimeout struct {
+	Reason     string
+	RetryDelay time.Duration
+}
+
+func (e ErrTimeout) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.DeadlineExceeded,
+		fmt.Sprintf("timed out: %s", e.Reason),
+	)
+	msg := fmt.Sprintf("The server timed out (%s). Retry the request", e.Reason)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d, retryInfo(e.RetryDelay))
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrTimeout) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrResourceExhausted はディスクの空きがないなど、サーバの資源が足りないことを表す。
+type ErrResourceExhausted struct {
+	Reason     string
+	RetryDelay time.Duration
+}
+
+func (e ErrResourceExhausted) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.ResourceExhausted,
+		fmt.Sprintf("resource exhausted: %s", e.Reason),
+	)
+	msg := fmt.Sprintf("The server ran out of resources (%s). Retry later", e.Reason)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d, retryInfo(e.RetryDelay))
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrResourceExhausted) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrDataLoss はインデックスやストアのデータが失われていて、あるはずのレコードを読み出せないことを表す。
+// 送り直しても直らないので、RetryInfoは付けない。
+type ErrDataLoss struct {
+	Reason string
+}
+
+func (e ErrDataLoss) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.DataLoss,
+		fmt.Sprintf("data loss: %s", e.Reason),
+	)
+	msg := fmt.Sprintf("The server's data is damaged (%s) and the request can't be served", e.Reason)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrDataLoss) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// NewProduceError はerrのgRPCのステータスを、ProduceStreamのレスポンスで返すProduceErrorにする。
+func NewProduceError(err error) *ProduceError {
+	st := status.Convert(err).Proto()
+	return &ProduceError{Code: st.Code, Message: st.Message, Details: st.Details}
+}
+
+// Err はProduceErrorをgRPCのステータスのエラーに戻す。nilならnilを返す。
+func (e *ProduceError) Err() error {
+	if e == nil {
+		return nil
+	}
+	return status.FromProto(&spb.Status{Code: e.Code, Message: e.Message, Details: e.Details}).Err()
+}
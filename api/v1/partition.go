@@ -0,0 +1,11 @@
+package log_v1
+
+import "hash/fnv"
+
+// PartitionForKey はキーを持つレコードを追加するパーティションを返す。
+// クライアントのロードバランサーとサーバで同じパーティションを選ぶために、両方でこの関数を使う。
+func PartitionForKey(key []byte, partitions uint32) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32() % partitions
+}
@@ -6,12 +6,14 @@ import (
 	"os/signal"
 	"path"
 	"syscall"
+	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/spf13/cobra"
 	"github.com/yurakawa/proglog/internal/agent"
 	"github.com/yurakawa/proglog/internal/config"
+	"github.com/yurakawa/proglog/internal/discovery"
 )
 
 func main() {
@@ -80,6 +82,33 @@ func setupFlags(cmd *cobra.Command) error {
 		nil,
 		"Serf addresses to join.")
 	cmd.Flags().Bool("bootstrap", false, "Bootstrap the cluster.")
+	cmd.Flags().String("role",
+		"voter",
+		"Raft role of this server: \"voter\", or \"nonvoter\" for a read replica that never votes or leads.")
+	cmd.Flags().Duration("retention-max-age",
+		0,
+		"Delete log segments older than this. 0 disables age-based retention.")
+	cmd.Flags().Uint64("retention-max-bytes",
+		0,
+		"Delete the oldest log segments once the log grows past this size. 0 disables size-based retention.")
+	cmd.Flags().Int("snapshot-retain",
+		1,
+		"Number of Raft snapshots to keep for each partition.")
+	cmd.Flags().Uint64("snapshot-threshold",
+		8192,
+		"Take a Raft snapshot once this many log entries have been applied since the last one.")
+	cmd.Flags().Duration("snapshot-interval",
+		2*time.Minute,
+		"How often to check whether a Raft snapshot should be taken.")
+	cmd.Flags().String("encryption-key-file",
+		"",
+		"Path to the key file used to encrypt log data at rest. Each line is \"<key id> <hex key>\"; the last key encrypts new records.")
+	cmd.Flags().Bool("forward-produce",
+		true,
+		"Forward produce requests received by followers to the leader.")
+	cmd.Flags().Duration("drain-timeout",
+		10*time.Second,
+		"How long to wait for in-flight requests and streams to finish on shutdown.")
 	cmd.Flags().String("acl-model-file", "", "Path to ACL model.")
 	cmd.Flags().String("acl-policy-file", "", "Path to ACL policy.")
 	cmd.Flags().String("server-tls-cert-file", "", "Path to server tls cert.")
@@ -113,6 +142,15 @@ func (c *cli) setupConfig(cmd *cobra.Command, args []string) error {
 	c.cfg.RPCPort = viper.GetInt("rpc-port")
 	c.cfg.StartJoinAddrs = viper.GetStringSlice("start-join-addrs")
 	c.cfg.Bootstrap = viper.GetBool("bootstrap")
+	c.cfg.Role = discovery.Role(viper.GetString("role"))
+	c.cfg.RetentionMaxAge = viper.GetDuration("retention-max-age")
+	c.cfg.RetentionMaxBytes = viper.GetUint64("retention-max-bytes")
+	c.cfg.SnapshotRetain = viper.GetInt("snapshot-retain")
+	c.cfg.SnapshotThreshold = viper.GetUint64("snapshot-threshold")
+	c.cfg.SnapshotInterval = viper.GetDuration("snapshot-interval")
+	c.cfg.EncryptionKeyFile = viper.GetString("encryption-key-file")
+	c.cfg.ForwardProduce = viper.GetBool("forward-produce")
+	c.cfg.DrainTimeout = viper.GetDuration("drain-timeout")
 	c.cfg.ACLModelFile = viper.GetString("acl-mode-file")
 	c.cfg.ACLPolicyFile = viper.GetString("acl-policy-file")
 	c.cfg.ServerTLSConfig.CertFile = viper.GetString("server-tls-cert-file")
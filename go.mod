@@ -1,6 +1,6 @@
 module github.com/yurakawa/proglog
 
-go 1.18
+go 1.22
 
 require (
 	github.com/casbin/casbin v1.9.1
@@ -9,6 +9,7 @@ require (
 	github.com/hashicorp/raft v1.3.6
 	github.com/hashicorp/raft-boltdb v0.0.0-00010101000000-000000000000
 	github.com/hashicorp/serf v0.10.1
+	github.com/klauspost/compress v1.18.0
 	github.com/soheilhy/cmux v0.1.5
 	github.com/spf13/cobra v1.6.1
 	github.com/spf13/viper v1.14.0
@@ -10,11 +10,13 @@ import (
 	"time"
 
 	"github.com/hashicorp/raft"
+	"github.com/hashicorp/serf/serf"
 
 	"github.com/soheilhy/cmux"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
 
 	"github.com/yurakawa/proglog/internal/auth"
 	"github.com/yurakawa/proglog/internal/discovery"
@@ -28,7 +30,11 @@ type Agent struct {
 	mux        cmux.CMux
 	log        *log.DistributedLog
 	server     *grpc.Server
+	health     *health.Server
+	forwarder  *server.LeaderForwarder
 	membership *discovery.Membership
+	// 閉じると、サーバはレコードの追加を待っているストリームを終わらせる
+	draining chan struct{}
 
 	shutdown     bool
 	shutdownLock sync.Mutex
@@ -45,8 +51,26 @@ type Config struct {
 	ACLModelFile    string
 	ACLPolicyFile   string
 	Bootstrap       bool
+	// ログの保持期間と保持サイズ。0なら制限しない
+	RetentionMaxAge   time.Duration
+	RetentionMaxBytes uint64
+	// Raftのグループごとに保持するスナップショットの数と、スナップショットを取るエントリ数と確認する間隔。
+	// 0ならRaftのデフォルト
+	SnapshotRetain    int
+	SnapshotThreshold uint64
+	SnapshotInterval  time.Duration
+	// 空でなければ、この鍵ファイルの鍵でログとRaftのログのレコードを暗号化する
+	EncryptionKeyFile string
+	// フォロワーが受け取ったProduceをリーダーに転送する
+	ForwardProduce bool
+	// シャットダウンするときに、処理中のRPCとストリームが終わるのを待つ最大の時間。0なら10秒
+	DrainTimeout time.Duration
+	// Raftのグループに参加するときの役割。空なら投票者で、読み出し用のレプリカは投票しない
+	Role discovery.Role
 }
 
+const defaultDrainTimeout = 10 * time.Second
+
 func (c Config) RPCAddr() (string, error) {
 	host, _, err := net.SplitHostPort(c.BindAddr)
 	if err != nil {
@@ -55,10 +79,29 @@ func (c Config) RPCAddr() (string, error) {
 	return fmt.Sprintf("%s:%d", host, c.RPCPort), nil
 }
 
+// 役割を返す。空なら投票者。
+func (c Config) role() (discovery.Role, error) {
+	switch c.Role {
+	case "", discovery.RoleVoter:
+		return discovery.RoleVoter, nil
+	case discovery.RoleNonvoter:
+		// 投票しないノードだけではリーダーを選べない
+		if c.Bootstrap {
+			return "", fmt.Errorf("cannot bootstrap the cluster as a %s", c.Role)
+		}
+		return c.Role, nil
+	default:
+		return "", fmt.Errorf("unknown role: %q", c.Role)
+	}
+}
+
 func New(config Config) (*Agent, error) {
 	a := &Agent{
 		Config: config,
 	}
+	if _, err := config.role(); err != nil {
+		return nil, err
+	}
 	setup := []func() error{
 		a.setupLogger,
 		a.setupMux,
@@ -129,6 +172,17 @@ func (a *Agent) setupLog() error {
 	logConfig.Raft.LocalID = raft.ServerID(a.Config.NodeName)
 	logConfig.Raft.Bootstrap = a.Config.Bootstrap
 	logConfig.Raft.CommitTimeout = 1000 * time.Millisecond
+	logConfig.Retention.MaxAge = a.Config.RetentionMaxAge
+	logConfig.Retention.MaxBytes = a.Config.RetentionMaxBytes
+	logConfig.Raft.SnapshotRetain = a.Config.SnapshotRetain
+	logConfig.Raft.SnapshotThreshold = a.Config.SnapshotThreshold
+	logConfig.Raft.SnapshotInterval = a.Config.SnapshotInterval
+	if a.Config.EncryptionKeyFile != "" {
+		logConfig.Segment.Keyring, err = log.LoadKeyring(a.Config.EncryptionKeyFile)
+		if err != nil {
+			return err
+		}
+	}
 
 	// 前段で作成したlogConfigを使ってDis
 	a.log, err = log.NewDistributedLog(
@@ -157,9 +211,24 @@ func (a *Agent) setupServer() error {
 		a.Config.ACLPolicyFile,
 	)
 	serverConfig := &server.Config{
-		CommitLog:   a.log,
-		Authorizer:  authorizer,
-		GetServerer: a.log,
+		CommitLog:    a.log,
+		Authorizer:   authorizer,
+		GetServerer:  a.log,
+		ReadVerifier: a.log,
+		TopicManager: a.log,
+		LogDescriber: a.log,
+		Admin:        a.log,
+		Membership:   agentMembers{a},
+	}
+	a.health = health.NewServer()
+	serverConfig.Health = a.health
+	a.draining = make(chan struct{})
+	serverConfig.Draining = a.draining
+	// ほかのノードへの接続はDescribeLogでも使うので、転送しなくても作る
+	a.forwarder = server.NewLeaderForwarder(a.Config.PeerTLSConfig)
+	serverConfig.Peers = a.forwarder
+	if a.Config.ForwardProduce {
+		serverConfig.Forwarder = a.forwarder
 	}
 	var opts []grpc.ServerOption
 	if a.Config.ServerTLSConfig != nil {
@@ -197,16 +266,31 @@ func (a *Agent) setupServer() error {
 	return nil
 }
 
+// agentMembers はAgentのMembershipのメンバーを返す。gRPCサーバはMembershipより先に作るので、
+// 呼び出されたときのMembershipを使う。リクエストはすべての準備が終わってから受け付ける。
+type agentMembers struct {
+	a *Agent
+}
+
+func (m agentMembers) Members() []serf.Member {
+	return m.a.membership.Members()
+}
+
 func (a *Agent) setupMembership() error {
 	rpcAddr, err := a.Config.RPCAddr()
 	if err != nil {
 		return err
 	}
+	role, err := a.Config.role()
+	if err != nil {
+		return err
+	}
 	a.membership, err = discovery.New(a.log, discovery.Config{
 		NodeName: a.Config.NodeName,
 		BindAddr: a.Config.BindAddr,
 		Tags: map[string]string{
-			"rpc_addr": rpcAddr,
+			discovery.RPCAddrTag: rpcAddr,
+			discovery.RoleTag:    string(role),
 		},
 		StartJoinAddrs: a.Config.StartJoinAddrs,
 	})
@@ -224,13 +308,25 @@ func (a *Agent) Shutdown() error {
 	// 一度だけshutdownするようにshutdownフラグを立てる
 	a.shutdown = true
 	// 各コンポーネントを閉じるメソッドをsliceにしている。
+	// 止めることを伝えてリーダーを移し、新しいリクエストを受け付けないようにして処理中のものを待ってから、
+	// クラスタから抜けてログを閉じる
 	shutdown := []func() error{
-		a.membership.Leave,
+		a.markLeaving,
+		a.transferLeadership,
 		func() error {
-			a.server.GracefulStop()
-			// gracefulstopはerrorを返さないのでエラー型を返す無名関数にしている
+			a.health.Shutdown()
+			close(a.draining)
+			a.drain()
+			// drainはerrorを返さないのでエラー型を返す無名関数にしている
 			return nil
 		},
+		func() error {
+			if a.forwarder == nil {
+				return nil
+			}
+			return a.forwarder.Close()
+		},
+		a.membership.Leave,
 		a.log.Close,
 	}
 	// shutdown funcsを順番に実行する
@@ -242,6 +338,45 @@ func (a *Agent) Shutdown() error {
 	return nil
 }
 
+// ほかのノードがこのノードにパーティションのリーダーを戻さないように、止めようとしていることを伝える。
+// 伝えられなくても止めることはできるので、ログに残して続ける。
+func (a *Agent) markLeaving() error {
+	if err := a.membership.MarkLeaving(); err != nil {
+		zap.L().Named("agent").Warn("failed to mark leaving", zap.Error(err))
+	}
+	return nil
+}
+
+// このノードがリーダーのグループのリーダーをほかのノードに移す。
+// 移しておけば、ほかのノードは選挙のタイムアウトを待たずに書き込みを受け付けられる。
+// 移せなくても止めることはできるので、ログに残して続ける。
+func (a *Agent) transferLeadership() error {
+	if err := a.log.TransferLeadership(); err != nil {
+		zap.L().Named("agent").Warn("failed to transfer leadership", zap.Error(err))
+	}
+	return nil
+}
+
+// 処理中のRPCとストリームが終わるのを待ってgRPCサーバを止める。
+// DrainTimeoutを過ぎても終わらなければ、残っているものを打ち切る。
+func (a *Agent) drain() {
+	timeout := a.Config.DrainTimeout
+	if timeout == 0 {
+		timeout = defaultDrainTimeout
+	}
+	stopped := make(chan struct{})
+	go func() {
+		a.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		a.server.Stop()
+		<-stopped
+	}
+}
+
 func (a *Agent) serve() error {
 	if err := a.mux.Serve(); err != nil {
 		_ = a.Shutdown()
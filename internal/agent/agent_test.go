@@ -1,15 +1,18 @@
 package agent_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/travisjeffery/go-dynaport"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -18,10 +21,362 @@ import (
 	api "github.com/yurakawa/proglog/api/v1"
 	"github.com/yurakawa/proglog/internal/agent"
 	"github.com/yurakawa/proglog/internal/config"
+	"github.com/yurakawa/proglog/internal/discovery"
 	"github.com/yurakawa/proglog/internal/loadbalance"
 )
 
 func TestAgent(t *testing.T) {
+	serverTLSConfig, peerTLSConfig := setupTLS(t)
+
+	// すべてのノードで同じ鍵を使ってログを暗号化する
+	keyFile, err := os.CreateTemp("", "agent-test-key")
+	require.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	_, err = keyFile.WriteString("1 000102030405060708090a0b0c0d0e0f\n")
+	require.NoError(t, err)
+	require.NoError(t, keyFile.Close())
+
+	agents := setupAgents(t, 3, serverTLSConfig, peerTLSConfig, func(i int, c *agent.Config) {
+		c.EncryptionKeyFile = keyFile.Name()
+		// 3つ目のノードは転送せずにリーダーを教える
+		c.ForwardProduce = i != 2
+	})
+	time.Sleep(3 * time.Second)
+
+	leaderClient := client(t, agents[0], peerTLSConfig)
+	produceResponse, err := leaderClient.Produce(
+		context.Background(),
+		&api.ProduceRequest{
+			Record: &api.Record{
+				Value: []byte("foo"),
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	// レプリケーションが完了するまで待つ
+	time.Sleep(3 * time.Second)
+
+	consumeResponse, err := leaderClient.Consume(
+		context.Background(),
+		&api.ConsumeRequest{
+			Offset: produceResponse.Offset,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, consumeResponse.Record.Value, []byte("foo"))
+
+	followerClient := client(t, agents[1], peerTLSConfig)
+	consumeResponse, err = followerClient.Consume(
+		context.Background(),
+		&api.ConsumeRequest{
+			Offset: produceResponse.Offset,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, consumeResponse.Record.Value, []byte("foo"))
+
+	consumeResponse, err = leaderClient.Consume(
+		context.Background(),
+		&api.ConsumeRequest{
+			Offset: produceResponse.Offset + 1,
+		},
+	)
+	require.Nil(t, consumeResponse)
+	require.Error(t, err)
+	got := status.Code(err)
+	want := codes.OutOfRange
+	require.Equal(t, got, want)
+
+	// リゾルバを使わないクライアントがフォロワーにProduceしても、リーダーに転送される
+	produceResponse, err = directClient(t, agents[1], peerTLSConfig).Produce(
+		context.Background(),
+		&api.ProduceRequest{
+			Record: &api.Record{
+				Value: []byte("bar"),
+			},
+		},
+	)
+	require.NoError(t, err)
+	// Consumeはフォロワーに振り分けられることがあるので、レコードが複製されるまで待たせる
+	consumeResponse, err = leaderClient.Consume(
+		context.Background(),
+		&api.ConsumeRequest{
+			Offset:    produceResponse.Offset,
+			MinOffset: &produceResponse.Offset,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), consumeResponse.Record.Value)
+
+	// トピックはすべてのノードに作成される
+	_, err = leaderClient.CreateTopic(
+		context.Background(),
+		&api.CreateTopicRequest{Name: "orders"},
+	)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		for _, agent := range agents {
+			res, err := directClient(t, agent, peerTLSConfig).ListTopics(
+				context.Background(),
+				&api.ListTopicsRequest{},
+			)
+			if err != nil || len(res.Topics) != 2 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 100*time.Millisecond)
+
+	// パーティションに分けたトピックでは、キーが同じレコードは同じパーティションに追加される
+	_, err = leaderClient.CreateTopic(
+		context.Background(),
+		&api.CreateTopicRequest{
+			Name:   "payments",
+			Config: &api.TopicConfig{Partitions: 3},
+		},
+	)
+	require.NoError(t, err)
+	key := []byte("customer-1")
+	for i := 0; i < 2; i++ {
+		produceResponse, err = leaderClient.Produce(
+			loadbalance.WithPartitionKey(context.Background(), "payments", key),
+			&api.ProduceRequest{
+				Topic:  "payments",
+				Record: &api.Record{Key: key, Value: []byte("payment")},
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, api.PartitionForKey(key, 3), produceResponse.Partition)
+		require.Equal(t, uint64(i), produceResponse.Offset)
+	}
+
+	// 転送しないフォロワーはリーダーのアドレスを返す
+	_, err = directClient(t, agents[2], peerTLSConfig).Produce(
+		context.Background(),
+		&api.ProduceRequest{
+			Record: &api.Record{
+				Value: []byte("baz"),
+			},
+		},
+	)
+	st := status.Convert(err)
+	// 教えるのはデフォルトのトピックのパーティションのリーダー。GetServersはパーティションごとのリーダーも返す
+	servers, err := leaderClient.GetServers(context.Background(), &api.GetServersRequest{})
+	require.NoError(t, err)
+	var leaderAddr string
+	for _, partition := range servers.Partitions {
+		if partition.Topic == "default" && partition.Id == 0 {
+			leaderAddr = partition.LeaderAddr
+		}
+	}
+	require.NotEmpty(t, leaderAddr)
+	require.Equal(t, codes.FailedPrecondition, st.Code())
+	var info *errdetails.ErrorInfo
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.ErrorInfo); ok {
+			info = d
+		}
+	}
+	require.NotNil(t, info)
+	require.Equal(t, leaderAddr, info.Metadata["leader_addr"])
+}
+
+// リーダーのノードを止めたときに、ほかのノードに書き込めない時間が選挙のタイムアウトより短いことをテストする。
+func TestAgentLeaderShutdown(t *testing.T) {
+	serverTLSConfig, peerTLSConfig := setupTLS(t)
+	agents := setupAgents(t, 3, serverTLSConfig, peerTLSConfig, func(i int, c *agent.Config) {
+		c.ForwardProduce = true
+	})
+	time.Sleep(3 * time.Second)
+
+	// デフォルトのトピックのパーティションのリーダーを止めて、残りのノードに書き込み続ける
+	servers, err := directClient(t, agents[0], peerTLSConfig).GetServers(context.Background(), &api.GetServersRequest{})
+	require.NoError(t, err)
+	leader := -1
+	for _, partition := range servers.Partitions {
+		if partition.Topic != "default" || partition.Id != 0 {
+			continue
+		}
+		for i, agent := range agents {
+			if addr, err := agent.Config.RPCAddr(); err == nil && addr == partition.LeaderAddr {
+				leader = i
+			}
+		}
+	}
+	require.NotEqual(t, -1, leader)
+	producer := directClient(t, agents[(leader+1)%len(agents)], peerTLSConfig)
+
+	var (
+		mu      sync.Mutex
+		last    = time.Now()
+		maxGap  time.Duration
+		records int
+	)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err := producer.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("foo")}})
+			cancel()
+			if err != nil {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			mu.Lock()
+			if gap := time.Since(last); gap > maxGap {
+				maxGap = gap
+			}
+			last = time.Now()
+			records++
+			mu.Unlock()
+		}
+	}()
+	// 書き込めることを確認してから止める
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return records > 0
+	}, 3*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	maxGap = 0
+	mu.Unlock()
+	require.NoError(t, agents[leader].Shutdown())
+	// 止めた後にも書き込めるようになるまで待つ
+	mu.Lock()
+	before := records
+	mu.Unlock()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return records > before+10
+	}, 5*time.Second, 10*time.Millisecond)
+	close(done)
+	<-stopped
+
+	// リーダーを移さなければ、Raftのデフォルトのハートビートと選挙のタイムアウトの1秒以上書き込めない
+	t.Logf("max write gap while shutting down the leader: %s", maxGap)
+	require.Less(t, maxGap, time.Second)
+}
+
+// roleタグで投票しないノードとして参加した読み出し用のレプリカに、レコードが複製されることをテストする。
+func TestAgentReadReplica(t *testing.T) {
+	serverTLSConfig, peerTLSConfig := setupTLS(t)
+	agents := setupAgents(t, 3, serverTLSConfig, peerTLSConfig, func(i int, c *agent.Config) {
+		if i == 2 {
+			c.Role = discovery.RoleNonvoter
+		}
+	})
+	time.Sleep(3 * time.Second)
+
+	leaderClient := directClient(t, agents[0], peerTLSConfig)
+	servers, err := leaderClient.GetServers(context.Background(), &api.GetServersRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(servers.Servers))
+	for _, server := range servers.Servers {
+		want := api.Suffrage_VOTER
+		if server.Id == "2" {
+			want = api.Suffrage_NONVOTER
+		}
+		require.Equal(t, want, server.Suffrage)
+	}
+
+	produceResponse, err := client(t, agents[0], peerTLSConfig).Produce(
+		context.Background(),
+		&api.ProduceRequest{Record: &api.Record{Value: []byte("foo")}},
+	)
+	require.NoError(t, err)
+	replicaClient := directClient(t, agents[2], peerTLSConfig)
+	require.Eventually(t, func() bool {
+		res, err := replicaClient.Consume(
+			context.Background(),
+			&api.ConsumeRequest{Offset: produceResponse.Offset},
+		)
+		return err == nil && bytes.Equal([]byte("foo"), res.Record.Value)
+	}, 3*time.Second, 50*time.Millisecond)
+
+	// メタデータのグループのリーダーは、レプリカを含むすべてのノードのログの状態を返す
+	require.Eventually(t, func() bool {
+		describe, err := leaderClient.DescribeLog(context.Background(), &api.DescribeLogRequest{Cluster: true})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(describe.Nodes))
+		for _, node := range describe.Nodes {
+			require.Empty(t, node.Error)
+			require.NotNil(t, node.Raft)
+			if node.Id == "2" {
+				require.Equal(t, "Follower", node.Raft.State)
+			}
+			if node.NextOffset != produceResponse.Offset+1 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 50*time.Millisecond)
+	_, err = replicaClient.DescribeLog(context.Background(), &api.DescribeLogRequest{Cluster: true})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// SerfとRaftのどちらから見ても、レプリカは投票しないメンバー
+	members, err := api.NewAdminClient(directConn(t, agents[0], peerTLSConfig)).ListMembers(
+		context.Background(),
+		&api.ListMembersRequest{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(members.Members))
+	for i, member := range members.Members {
+		require.Equal(t, fmt.Sprintf("%d", i), member.Id)
+		require.Equal(t, "alive", member.SerfStatus)
+		require.True(t, member.Raft)
+		require.Equal(t, i == 0, member.IsLeader)
+		if i == 2 {
+			require.Equal(t, string(discovery.RoleNonvoter), member.Role)
+			require.Equal(t, api.Suffrage_NONVOTER, member.Suffrage)
+		}
+	}
+
+	// 投票しないノードでクラスタはブートストラップできない
+	for _, c := range []agent.Config{
+		{Role: discovery.RoleNonvoter, Bootstrap: true},
+		{Role: "observer"},
+	} {
+		_, err = agent.New(c)
+		require.Error(t, err)
+	}
+}
+
+// 止めるときに、レコードの追加を待っているConsumeStreamをUnavailableで終わらせて、DrainTimeoutまで待たないことをテストする。
+func TestAgentDrainTailingStream(t *testing.T) {
+	serverTLSConfig, peerTLSConfig := setupTLS(t)
+	agents := setupAgents(t, 1, serverTLSConfig, peerTLSConfig, func(i int, c *agent.Config) {
+		c.DrainTimeout = 10 * time.Second
+	})
+	time.Sleep(3 * time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := directClient(t, agents[0], peerTLSConfig).ConsumeStream(
+		ctx,
+		&api.ConsumeRequest{StartPosition: api.StartPosition_LATEST},
+	)
+	require.NoError(t, err)
+	// ストリームが末尾で追加を待ち始めるまで待つ
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, agents[0].Shutdown())
+	require.Less(t, time.Since(start), 5*time.Second)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func setupTLS(t *testing.T) (serverTLSConfig, peerTLSConfig *tls.Config) {
+	t.Helper()
 	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
 		CertFile:      config.ServerCertFile,
 		KeyFile:       config.ServerKeyFile,
@@ -31,7 +386,7 @@ func TestAgent(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+	peerTLSConfig, err = config.SetupTLSConfig(config.TLSConfig{
 		CertFile:      config.RootClientCertFile,
 		KeyFile:       config.RootClientKeyFile,
 		CAFile:        config.CAFile,
@@ -39,9 +394,20 @@ func TestAgent(t *testing.T) {
 		ServerAddress: "127.0.0.1",
 	})
 	require.NoError(t, err)
+	return serverTLSConfig, peerTLSConfig
+}
 
+// count個のエージェントを起動する。最初のエージェントがクラスタをブートストラップし、残りはそれに参加する。
+// configureでノードごとの設定を変えられる。
+func setupAgents(
+	t *testing.T,
+	count int,
+	serverTLSConfig, peerTLSConfig *tls.Config,
+	configure func(i int, c *agent.Config),
+) []*agent.Agent {
+	t.Helper()
 	var agents []*agent.Agent
-	for i := 0; i < 3; i++ {
+	for i := 0; i < count; i++ {
 		ports := dynaport.Get(2)
 		bindAddr := fmt.Sprintf("%s:%d", "127.0.0.1", ports[0])
 		rpcPort := ports[1]
@@ -57,7 +423,7 @@ func TestAgent(t *testing.T) {
 			)
 		}
 
-		agent, err := agent.New(agent.Config{
+		c := agent.Config{
 			NodeName:        fmt.Sprintf("%d", i),
 			StartJoinAddrs:  startJoinAddrs,
 			BindAddr:        bindAddr,
@@ -68,12 +434,14 @@ func TestAgent(t *testing.T) {
 			ServerTLSConfig: serverTLSConfig,
 			PeerTLSConfig:   peerTLSConfig,
 			Bootstrap:       i == 0,
-		})
+		}
+		configure(i, &c)
+		agent, err := agent.New(c)
 		require.NoError(t, err)
 
 		agents = append(agents, agent)
 	}
-	defer func() {
+	t.Cleanup(func() {
 		for _, agent := range agents {
 			err := agent.Shutdown()
 			require.NoError(t, err)
@@ -81,53 +449,8 @@ func TestAgent(t *testing.T) {
 				os.RemoveAll(agent.Config.DataDir),
 			)
 		}
-	}()
-	time.Sleep(3 * time.Second)
-
-	leaderClient := client(t, agents[0], peerTLSConfig)
-	produceResponse, err := leaderClient.Produce(
-		context.Background(),
-		&api.ProduceRequest{
-			Record: &api.Record{
-				Value: []byte("foo"),
-			},
-		},
-	)
-	require.NoError(t, err)
-
-	// レプリケーションが完了するまで待つ
-	time.Sleep(3 * time.Second)
-
-	consumeResponse, err := leaderClient.Consume(
-		context.Background(),
-		&api.ConsumeRequest{
-			Offset: produceResponse.Offset,
-		},
-	)
-	require.NoError(t, err)
-	require.Equal(t, consumeResponse.Record.Value, []byte("foo"))
-
-	followerClient := client(t, agents[1], peerTLSConfig)
-	consumeResponse, err = followerClient.Consume(
-		context.Background(),
-		&api.ConsumeRequest{
-			Offset: produceResponse.Offset,
-		},
-	)
-	require.NoError(t, err)
-	require.Equal(t, consumeResponse.Record.Value, []byte("foo"))
-
-	consumeResponse, err = leaderClient.Consume(
-		context.Background(),
-		&api.ConsumeRequest{
-			Offset: produceResponse.Offset + 1,
-		},
-	)
-	require.Nil(t, consumeResponse)
-	require.Error(t, err)
-	got := status.Code(err)
-	want := codes.OutOfRange
-	require.Equal(t, got, want)
+	})
+	return agents
 }
 
 func client(
@@ -150,3 +473,25 @@ func client(
 	client := api.NewLogClient(conn)
 	return client
 }
+
+// リゾルバを使わずにagentのノードだけに接続するクライアントを返す。
+func directClient(
+	t *testing.T,
+	agent *agent.Agent,
+	tlsConfig *tls.Config,
+) api.LogClient {
+	return api.NewLogClient(directConn(t, agent, tlsConfig))
+}
+
+func directConn(
+	t *testing.T,
+	agent *agent.Agent,
+	tlsConfig *tls.Config,
+) *grpc.ClientConn {
+	tlsCreds := credentials.NewTLS(tlsConfig)
+	rpcAddr, err := agent.Config.RPCAddr()
+	require.NoError(t, err)
+	conn, err := grpc.Dial(rpcAddr, grpc.WithTransportCredentials(tlsCreds))
+	require.NoError(t, err)
+	return conn
+}
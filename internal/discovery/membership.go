@@ -65,8 +65,34 @@ func (m *Membership) setupSerf() (err error) {
 	return nil
 }
 
+// ノードのアドレスと役割と、止めようとしていることを伝えるSerfのタグ
+const (
+	RPCAddrTag = "rpc_addr"
+	RoleTag    = "role"
+	LeavingTag = "leaving"
+)
+
+// Role はノードがRaftのグループに参加するときの役割で、Serfのroleタグで伝える。
+type Role string
+
+const (
+	// 投票してリーダーになれるノード。タグがなければ投票者とみなす
+	RoleVoter Role = "voter"
+	// 投票しない、読み出し用のレプリカのノード
+	RoleNonvoter Role = "nonvoter"
+)
+
+// Member はクラスタに参加したノード。
+type Member struct {
+	Name    string
+	RPCAddr string
+	Role    Role
+	// 止めようとしていて、パーティションのリーダーを移すべきでないノード。Serfのleavingタグで伝える
+	Leaving bool
+}
+
 type Handler interface {
-	Join(name, addr string) error
+	Join(member Member) error
 	Leave(name string) error
 }
 
@@ -80,6 +106,11 @@ func (m *Membership) eventHandler() {
 				}
 				m.handleJoin(member)
 			}
+		case serf.EventMemberUpdate:
+			// タグが変わったメンバーは参加し直す。止めようとしていることは自分のノードにも伝える
+			for _, member := range e.(serf.MemberEvent).Members {
+				m.handleJoin(member)
+			}
 		case serf.EventMemberLeave, serf.EventMemberFailed:
 			for _, member := range e.(serf.MemberEvent).Members {
 				if m.isLocal(member) {
@@ -91,10 +122,16 @@ func (m *Membership) eventHandler() {
 	}
 }
 func (m *Membership) handleJoin(member serf.Member) {
-	if err := m.handler.Join(
-		member.Name,
-		member.Tags["rpc_addr"],
-	); err != nil {
+	role := Role(member.Tags[RoleTag])
+	if role == "" {
+		role = RoleVoter
+	}
+	if err := m.handler.Join(Member{
+		Name:    member.Name,
+		RPCAddr: member.Tags[RPCAddrTag],
+		Role:    role,
+		Leaving: member.Tags[LeavingTag] == "true",
+	}); err != nil {
 		m.logError(err, "failed to join", member)
 	}
 }
@@ -115,6 +152,17 @@ func (m *Membership) Members() []serf.Member {
 func (m *Membership) Leave() error {
 	return m.serf.Leave()
 }
+
+// MarkLeaving はleavingタグを付けて、このノードを止めようとしていることをクラスタに伝える。
+// 伝わったノードは、このノードにパーティションのリーダーを移さない。
+func (m *Membership) MarkLeaving() error {
+	tags := make(map[string]string, len(m.Tags)+1)
+	for k, v := range m.Tags {
+		tags[k] = v
+	}
+	tags[LeavingTag] = "true"
+	return m.serf.SetTags(tags)
+}
 func (m *Membership) logError(err error, msg string, member serf.Member) {
 	// リーダでないノードでクラスタを変更しようとするとRaftはErrNotLeaderを返す。
 	// 全てのハンドラエラーを重大なエラーとしてログに記録しているが、ノードがリーダでない場合はログに記録しない様にする。
@@ -127,6 +175,7 @@ func (m *Membership) logError(err error, msg string, member serf.Member) {
 		msg,
 		zap.Error(err),
 		zap.String("name", member.Name),
-		zap.String("rpc_addr", member.Tags["rpc_addr"]),
+		zap.String("rpc_addr", member.Tags[RPCAddrTag]),
+		zap.String("role", member.Tags[RoleTag]),
 	)
 }
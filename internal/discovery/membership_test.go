@@ -12,17 +12,36 @@ import (
 )
 
 func TestMembership(t *testing.T) {
-	m, handler := setupMember(t, nil)
-	m, _ = setupMember(t, m)
-	m, _ = setupMember(t, m)
+	m, handler := setupMember(t, nil, "")
+	m, _ = setupMember(t, m, "")
+	m, _ = setupMember(t, m, RoleNonvoter)
 	require.Eventually(t, func() bool {
 		return len(handler.joins) == 2 &&
 			len(m[0].Members()) == 3 &&
 			len(handler.leaves) == 0
 	}, 3*time.Second, 250*time.Millisecond)
+	// roleタグがなければ投票者として参加する
+	joins := make(map[string]Member)
+	for i := 0; i < 2; i++ {
+		member := <-handler.joins
+		joins[member.Name] = member
+	}
+	require.Equal(t, RoleVoter, joins["1"].Role)
+	require.Equal(t, RoleNonvoter, joins["2"].Role)
+	require.Equal(t, m[2].BindAddr, joins["2"].RPCAddr)
+	require.False(t, joins["1"].Leaving)
+	// 止めようとしていることはタグの更新で伝わる
+	require.NoError(t, m[1].MarkLeaving())
+	select {
+	case member := <-handler.joins:
+		require.Equal(t, "1", member.Name)
+		require.True(t, member.Leaving)
+	case <-time.After(3 * time.Second):
+		t.Fatal("leaving member was not updated")
+	}
 	require.NoError(t, m[2].Leave())
 	require.Eventually(t, func() bool {
-		return len(handler.joins) == 2 &&
+		return len(handler.joins) == 0 &&
 			len(m[0].Members()) == 3 &&
 			m[0].Members()[2].Status == serf.StatusLeft &&
 			len(handler.leaves) == 1
@@ -30,7 +49,7 @@ func TestMembership(t *testing.T) {
 	require.Equal(t, "2", <-handler.leaves)
 }
 
-func setupMember(t *testing.T, members []*Membership) (
+func setupMember(t *testing.T, members []*Membership, role Role) (
 	[]*Membership, *handler,
 ) {
 	id := len(members)
@@ -39,6 +58,9 @@ func setupMember(t *testing.T, members []*Membership) (
 	tags := map[string]string{
 		"rpc_addr": addr,
 	}
+	if role != "" {
+		tags["role"] = string(role)
+	}
 	c := Config{
 		NodeName: fmt.Sprintf("%d", id),
 		BindAddr: addr,
@@ -46,7 +68,7 @@ func setupMember(t *testing.T, members []*Membership) (
 	}
 	h := &handler{}
 	if len(members) == 0 {
-		h.joins = make(chan map[string]string, 3)
+		h.joins = make(chan Member, 3)
 		h.leaves = make(chan string, 3)
 	} else {
 		c.StartJoinAddrs = []string{
@@ -60,16 +82,13 @@ func setupMember(t *testing.T, members []*Membership) (
 }
 
 type handler struct {
-	joins  chan map[string]string
+	joins  chan Member
 	leaves chan string
 }
 
-func (h *handler) Join(id, addr string) error {
+func (h *handler) Join(member Member) error {
 	if h.joins != nil {
-		h.joins <- map[string]string{
-			"id":   id,
-			"addr": addr,
-		}
+		h.joins <- member
 	}
 	return nil
 }
@@ -1,12 +1,17 @@
 package loadbalance
 
 import (
+	"context"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 
+	api "github.com/yurakawa/proglog/api/v1"
+	"github.com/yurakawa/proglog/internal/log"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
 )
 
 var _ base.PickerBuilder = (*Picker)(nil)
@@ -15,14 +20,62 @@ type Picker struct {
 	mu        sync.RWMutex
 	leader    balancer.SubConn
 	followers []balancer.SubConn
+	// 投票しない読み出し用のレプリカ。Consumeはこちらを優先する
+	nonvoters []balancer.SubConn
 	current   uint64
+
+	// パーティションのリーダーのサブコネクションと、トピックごとのパーティションの数
+	partitionLeaders map[partition]balancer.SubConn
+	partitions       map[string]uint32
+}
+
+type partition struct {
+	topic string
+	id    uint32
+}
+
+// Produceを送るパーティションをピッカーに伝えるメタデータのキー。サーバには使われない
+const (
+	topicMetadataKey     = "proglog-topic"
+	keyMetadataKey       = "proglog-key-bin"
+	partitionMetadataKey = "proglog-partition"
+)
+
+// WithPartitionKey はctxで送るProduceを、topicのkeyを持つレコードを追加するパーティションのリーダーに送らせる。
+func WithPartitionKey(ctx context.Context, topic string, key []byte) context.Context {
+	return metadata.AppendToOutgoingContext(
+		ctx,
+		topicMetadataKey, topic,
+		keyMetadataKey, string(key),
+	)
+}
+
+// WithPartition はctxで送るProduceとCommitOffsetを、topicのpartitionのリーダーに送らせる。
+func WithPartition(ctx context.Context, topic string, partition uint32) context.Context {
+	return metadata.AppendToOutgoingContext(
+		ctx,
+		topicMetadataKey, topic,
+		partitionMetadataKey, strconv.FormatUint(uint64(partition), 10),
+	)
 }
 
 func (p *Picker) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	var followers []balancer.SubConn
+	var followers, nonvoters []balancer.SubConn
+	partitionLeaders := make(map[partition]balancer.SubConn)
+	partitions := make(map[string]uint32)
 	for sc, scInfo := range buildInfo.ReadySCs {
+		if ps, ok := scInfo.Address.Attributes.Value(partitionsAttr).(Partitions); ok {
+			for topic, ids := range ps.Leads {
+				for _, id := range ids {
+					partitionLeaders[partition{topic: topic, id: id}] = sc
+				}
+			}
+			for topic, n := range ps.Counts {
+				partitions[topic] = n
+			}
+		}
 		isLeader := scInfo.
 			Address.
 			Attributes.
@@ -31,9 +84,17 @@ func (p *Picker) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
 			p.leader = sc
 			continue
 		}
+		// is_voterがなければ投票者とみなす
+		if isVoter, ok := scInfo.Address.Attributes.Value("is_voter").(bool); ok && !isVoter {
+			nonvoters = append(nonvoters, sc)
+			continue
+		}
 		followers = append(followers, sc)
 	}
 	p.followers = followers
+	p.nonvoters = nonvoters
+	p.partitionLeaders = partitionLeaders
+	p.partitions = partitions
 	return p
 }
 
@@ -44,22 +105,59 @@ func (p *Picker) Pick(info balancer.PickInfo) (
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	var result balancer.PickResult
-	if strings.Contains(info.FullMethodName, "Produce") ||
-		len(p.followers) == 0 {
+	// 読み出しは読み出し用のレプリカに、なければフォロワーに振り分け、書き込みやトピックの作成などそれ以外はリーダーに送る。
+	// パーティションがわかる書き込みとオフセットのコミットはそのパーティションのリーダーに送る
+	if strings.Contains(info.FullMethodName, "Consume") &&
+		len(p.nonvoters) > 0 {
+		result.SubConn = p.next(p.nonvoters)
+	} else if strings.Contains(info.FullMethodName, "Consume") &&
+		len(p.followers) > 0 {
+		result.SubConn = p.next(p.followers)
+	} else if sc := p.partitionLeader(info); sc != nil {
+		result.SubConn = sc
+	} else {
 		result.SubConn = p.leader
-	} else if strings.Contains(info.FullMethodName, "Consume") {
-		result.SubConn = p.nextFollower()
 	}
 	if result.SubConn == nil {
 		return result, balancer.ErrNoSubConnAvailable
 	}
 	return result, nil
 }
-func (p *Picker) nextFollower() balancer.SubConn {
+
+// WithPartitionKeyかWithPartitionで指定されたパーティションのリーダーを返す。わからなければnilを返す。
+func (p *Picker) partitionLeader(info balancer.PickInfo) balancer.SubConn {
+	if !(strings.Contains(info.FullMethodName, "Produce") ||
+		strings.Contains(info.FullMethodName, "CommitOffset")) || info.Ctx == nil {
+		return nil
+	}
+	md, ok := metadata.FromOutgoingContext(info.Ctx)
+	if !ok {
+		return nil
+	}
+	topic := log.DefaultTopic
+	if v := md.Get(topicMetadataKey); len(v) > 0 && v[0] != "" {
+		topic = v[0]
+	}
+	var id uint32
+	if v := md.Get(partitionMetadataKey); len(v) > 0 {
+		n, err := strconv.ParseUint(v[0], 10, 32)
+		if err != nil {
+			return nil
+		}
+		id = uint32(n)
+	} else if v := md.Get(keyMetadataKey); len(v) > 0 && v[0] != "" && p.partitions[topic] > 0 {
+		id = api.PartitionForKey([]byte(v[0]), p.partitions[topic])
+	} else {
+		return nil
+	}
+	return p.partitionLeaders[partition{topic: topic, id: id}]
+}
+
+func (p *Picker) next(subConns []balancer.SubConn) balancer.SubConn {
 	cur := atomic.AddUint64(&p.current, uint64(1))
-	len := uint64(len(p.followers))
+	len := uint64(len(subConns))
 	idx := int(cur % len)
-	return p.followers[idx]
+	return subConns[idx]
 }
 
 func init() {
@@ -1,6 +1,7 @@
 package loadbalance_test
 
 import (
+	"context"
 	"testing"
 
 	"google.golang.org/grpc/attributes"
@@ -8,6 +9,7 @@ import (
 	"google.golang.org/grpc/resolver"
 
 	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
 	"github.com/yurakawa/proglog/internal/loadbalance"
 	"google.golang.org/grpc/balancer"
 )
@@ -29,13 +31,20 @@ func TestPickerNoSubConnAvailable(t *testing.T) {
 
 func TestPickerProducesToLeader(t *testing.T) {
 	picker, subConns := setupTest()
-	info := balancer.PickInfo{
-		FullMethodName: "/log.vX.Log/Produce",
-	}
-	for i := 0; i < 5; i++ {
-		gotPick, err := picker.Pick(info)
-		require.NoError(t, err)
-		require.Equal(t, subConns[0], gotPick.SubConn)
+	for _, method := range []string{
+		"/log.vX.Log/Produce",
+		"/log.vX.Log/ProduceBatch",
+		"/log.vX.Log/CreateTopic",
+		"/log.vX.Log/DeleteTopic",
+	} {
+		info := balancer.PickInfo{
+			FullMethodName: method,
+		}
+		for i := 0; i < 5; i++ {
+			gotPick, err := picker.Pick(info)
+			require.NoError(t, err)
+			require.Equal(t, subConns[0], gotPick.SubConn)
+		}
 	}
 }
 func TestPickerConsumesFromFollowers(t *testing.T) {
@@ -50,15 +59,100 @@ func TestPickerConsumesFromFollowers(t *testing.T) {
 	}
 }
 
+// 読み出し用のレプリカがあれば、Consumeはフォロワーではなくレプリカに振り分けられる
+func TestPickerConsumesFromNonvoters(t *testing.T) {
+	picker, subConns := setupPicker(4, func(i int) *attributes.Attributes {
+		return attributes.New("is_leader", i == 0).WithValue("is_voter", i < 2)
+	})
+	info := balancer.PickInfo{
+		FullMethodName: "/log.vX.Log/Consume",
+	}
+	for i := 0; i < 6; i++ {
+		pick, err := picker.Pick(info)
+		require.NoError(t, err)
+		require.Contains(t, subConns[2:], pick.SubConn)
+	}
+	// 投票しないノードはリーダーにならないので、書き込みはリーダーに送る
+	pick, err := picker.Pick(balancer.PickInfo{
+		FullMethodName: "/log.vX.Log/Produce",
+	})
+	require.NoError(t, err)
+	require.Equal(t, subConns[0], pick.SubConn)
+}
+
+// パーティションがわかるProduceとCommitOffsetは、メタデータのリーダーではなくパーティションのリーダーに送られる
+func TestPickerProducesToPartitionLeader(t *testing.T) {
+	picker, subConns := setupPartitionTest()
+	key := []byte("customer-1")
+	byKey := api.PartitionForKey(key, 3)
+	for _, tc := range []struct {
+		ctx  context.Context
+		want *subConn
+	}{
+		{loadbalance.WithPartition(context.Background(), "orders", 1), subConns[1]},
+		{loadbalance.WithPartition(context.Background(), "orders", 2), subConns[2]},
+		{loadbalance.WithPartitionKey(context.Background(), "orders", key), subConns[byKey]},
+		{loadbalance.WithPartition(context.Background(), "", 0), subConns[1]},
+		// パーティションがわからなければリーダーに送る
+		{loadbalance.WithPartitionKey(context.Background(), "orders", nil), subConns[0]},
+		{loadbalance.WithPartition(context.Background(), "missing", 0), subConns[0]},
+		{context.Background(), subConns[0]},
+	} {
+		for _, method := range []string{
+			"/log.vX.Log/Produce",
+			"/log.vX.Log/ProduceBatch",
+			"/log.vX.Log/CommitOffset",
+		} {
+			pick, err := picker.Pick(balancer.PickInfo{
+				FullMethodName: method,
+				Ctx:            tc.ctx,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.want, pick.SubConn)
+		}
+	}
+	// 読み出しはこれまでどおりフォロワーに振り分ける
+	pick, err := picker.Pick(balancer.PickInfo{
+		FullMethodName: "/log.vX.Log/Consume",
+		Ctx:            loadbalance.WithPartition(context.Background(), "orders", 0),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, subConns[0], pick.SubConn)
+}
+
 func setupTest() (*loadbalance.Picker, []*subConn) {
+	return setupPicker(3, func(i int) *attributes.Attributes {
+		return attributes.New("is_leader", i == 0)
+	})
+}
+
+// ordersトピックのパーティションiのリーダーがi番目のサブコネクションで、
+// デフォルトのトピックのパーティション0のリーダーが1番目のサブコネクションのピッカーを返す。
+func setupPartitionTest() (*loadbalance.Picker, []*subConn) {
+	return setupPicker(3, func(i int) *attributes.Attributes {
+		leads := map[string][]uint32{"orders": {uint32(i)}}
+		if i == 1 {
+			leads["default"] = []uint32{0}
+		}
+		return attributes.New("is_leader", i == 0).WithValue(
+			"partitions",
+			loadbalance.Partitions{
+				Leads:  leads,
+				Counts: map[string]uint32{"default": 1, "orders": 3},
+			},
+		)
+	})
+}
+
+func setupPicker(count int, attrs func(i int) *attributes.Attributes) (*loadbalance.Picker, []*subConn) {
 	var subConns []*subConn
 	buildInfo := base.PickerBuildInfo{
 		ReadySCs: make(map[balancer.SubConn]base.SubConnInfo),
 	}
-	for i := 0; i < 3; i++ {
+	for i := 0; i < count; i++ {
 		sc := &subConn{}
 		addr := resolver.Address{
-			Attributes: attributes.New("is_leader", i == 0),
+			Attributes: attrs(i),
 		}
 		// 0 番目のサブコネクションは、リーダーです。
 		sc.UpdateAddresses([]resolver.Address{addr})
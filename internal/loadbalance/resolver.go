@@ -3,6 +3,7 @@ package loadbalance
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 
 	api "github.com/yurakawa/proglog/api/v1"
@@ -52,6 +53,23 @@ func (r *Resolver) Build(
 
 const Name = "proglog"
 
+// アドレスの属性のキーで、値はPartitions
+const partitionsAttr = "partitions"
+
+// Partitions はアドレスのノードがリーダーのパーティションと、トピックごとのパーティションの数。
+// ピッカーはこれを使ってProduceをパーティションのリーダーに送る。
+type Partitions struct {
+	// トピックごとの、このノードがリーダーのパーティション
+	Leads map[string][]uint32
+	// トピックごとのパーティションの数
+	Counts map[string]uint32
+}
+
+// Equal はアドレスの属性を比較するときに使われる。
+func (p Partitions) Equal(o interface{}) bool {
+	return reflect.DeepEqual(p, o)
+}
+
 func (r *Resolver) Scheme() string {
 	return Name
 }
@@ -75,14 +93,39 @@ func (r *Resolver) ResolveNow(resolver.ResolveNowOptions) {
 		)
 		return
 	}
+	// トピックごとのパーティションの数と、ノードごとのリーダーのパーティション
+	counts := make(map[string]uint32)
+	leads := make(map[string]map[string][]uint32)
+	for _, partition := range res.Partitions {
+		if partition.Id >= counts[partition.Topic] {
+			counts[partition.Topic] = partition.Id + 1
+		}
+		if leads[partition.LeaderAddr] == nil {
+			leads[partition.LeaderAddr] = make(map[string][]uint32)
+		}
+		leads[partition.LeaderAddr][partition.Topic] = append(
+			leads[partition.LeaderAddr][partition.Topic],
+			partition.Id,
+		)
+	}
 	var addrs []resolver.Address
 	for _, server := range res.Servers {
+		attrs := attributes.New(
+			"is_leader",
+			server.IsLeader,
+		).WithValue(
+			"is_voter",
+			server.Suffrage == api.Suffrage_VOTER,
+		)
+		if len(counts) > 0 {
+			attrs = attrs.WithValue(partitionsAttr, Partitions{
+				Leads:  leads[server.RpcAddr],
+				Counts: counts,
+			})
+		}
 		addrs = append(addrs, resolver.Address{
-			Addr: server.RpcAddr,
-			Attributes: attributes.New(
-				"is_leader",
-				server.IsLeader,
-			),
+			Addr:       server.RpcAddr,
+			Attributes: attrs,
 		})
 	}
 	r.clientConn.UpdateState(resolver.State{
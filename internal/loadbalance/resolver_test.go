@@ -57,13 +57,40 @@ func TestResolver(t *testing.T) {
 		opts,
 	)
 	require.NoError(t, err)
+	counts := map[string]uint32{"default": 1, "orders": 2}
 	wantState := resolver.State{
 		Addresses: []resolver.Address{{
-			Addr:       "localhost:9001",
-			Attributes: attributes.New("is_leader", true),
+			Addr: "localhost:9001",
+			Attributes: attributes.New("is_leader", true).WithValue(
+				"is_voter", true,
+			).WithValue(
+				"partitions",
+				loadbalance.Partitions{
+					Leads:  map[string][]uint32{"default": {0}, "orders": {0}},
+					Counts: counts,
+				},
+			),
 		}, {
-			Addr:       "localhost:9002",
-			Attributes: attributes.New("is_leader", false),
+			Addr: "localhost:9002",
+			Attributes: attributes.New("is_leader", false).WithValue(
+				"is_voter", true,
+			).WithValue(
+				"partitions",
+				loadbalance.Partitions{
+					Leads:  map[string][]uint32{"orders": {1}},
+					Counts: counts,
+				},
+			),
+		}, {
+			Addr: "localhost:9003",
+			Attributes: attributes.New("is_leader", false).WithValue(
+				"is_voter", false,
+			).WithValue(
+				"partitions",
+				loadbalance.Partitions{
+					Counts: counts,
+				},
+			),
 		}},
 	}
 	require.Equal(t, wantState, conn.state)
@@ -82,9 +109,21 @@ func (s *getServers) GetServers() ([]*api.Server, error) {
 	}, {
 		Id:      "follower",
 		RpcAddr: "localhost:9002",
+	}, {
+		Id:       "replica",
+		RpcAddr:  "localhost:9003",
+		Suffrage: api.Suffrage_NONVOTER,
 	}}, nil
 }
 
+func (s *getServers) GetPartitions() ([]*api.Partition, error) {
+	return []*api.Partition{
+		{Topic: "default", Id: 0, LeaderAddr: "localhost:9001"},
+		{Topic: "orders", Id: 0, LeaderAddr: "localhost:9001"},
+		{Topic: "orders", Id: 1, LeaderAddr: "localhost:9002"},
+	}, nil
+}
+
 type clientConn struct {
 	resolver.ClientConn
 	state resolver.State
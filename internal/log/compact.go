@@ -0,0 +1,193 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	api "github.com/yurakawa/proglog/api/v1"
+	"go.uber.org/zap"
+)
+
+// 圧縮したセグメントを書き出す一時ディレクトリ。ログのディレクトリの中に作る。
+const compactionDir = "compacting"
+
+// 圧縮してファイルを置き換える順番。ストアを最後に置き換えるので、途中でクラッシュしても
+// 新しいインデックスと古いストアの組み合わせになり、起動時のリカバリで古いストアからインデックスが作り直される。
+var segmentExts = []string{".index", ".timeindex", ".store"}
+
+// 圧縮で書き直したセグメント
+type compacted struct {
+	// 置き換える元のセグメント
+	orig *segment
+	// 残したレコードの数と削除したレコードの数
+	kept, removed int
+}
+
+// CheckIntervalごとにアクティブでないセグメントを圧縮する。
+func (l *Log) compactionLoop(done <-chan struct{}) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.Config.Compaction.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := l.compact(); err != nil {
+			l.logger.Error(
+				"failed to compact log",
+				zap.String("dir", l.Dir),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// アクティブでないセグメントから、同じキーを持つより新しいレコードがあるレコードと、
+// TombstoneRetentionが過ぎたトゥームストーンを取り除く。オフセットは変わらない。
+//
+// 読み出しロックを取って圧縮したセグメントを一時ディレクトリに書き出してから、
+// 書き込みロックを取って元のセグメントと置き換える。書き出している間も読み出しはできる。
+func (l *Log) compact() error {
+	dir := filepath.Join(l.Dir, compactionDir)
+	defer os.RemoveAll(dir)
+	cs, err := l.writeCompacted(dir)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range cs {
+		if err = l.replaceSegment(c.orig); err != nil {
+			return err
+		}
+		l.logger.Info(
+			"compacted segment",
+			zap.String("dir", l.Dir),
+			zap.Uint64("base_offset", c.orig.baseOffset),
+			zap.Int("kept_records", c.kept),
+			zap.Int("removed_records", c.removed),
+		)
+	}
+	return nil
+}
+
+// 圧縮して小さくなるセグメントを一時ディレクトリdirに書き出す。
+func (l *Log) writeCompacted(dir string) ([]compacted, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// キーごとに最新のレコードのオフセットを集める。アクティブセグメントのレコードも含める。
+	latest := make(map[string]uint64)
+	for _, s := range l.segments {
+		if err := s.each(func(record *api.Record) error {
+			if len(record.Key) > 0 {
+				latest[string(record.Key)] = record.Offset
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	keep := func(record *api.Record) bool {
+		if len(record.Key) == 0 {
+			return true
+		}
+		if latest[string(record.Key)] != record.Offset {
+			return false
+		}
+		// トゥームストーンは、削除されたことを読み手が知れるように猶予期間の間だけ残す
+		if len(record.Value) == 0 && record.Timestamp != nil {
+			return now.Sub(record.Timestamp.AsTime()) <= l.Config.Compaction.TombstoneRetention
+		}
+		return true
+	}
+
+	var cs []compacted
+	for _, s := range l.segments[:len(l.segments)-1] {
+		c := compacted{orig: s}
+		if err := s.each(func(record *api.Record) error {
+			if keep(record) {
+				c.kept++
+			} else {
+				c.removed++
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if c.removed == 0 {
+			continue
+		}
+		ns, err := newSegment(dir, s.baseOffset, l.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.each(func(record *api.Record) error {
+			if !keep(record) {
+				return nil
+			}
+			return ns.write(record)
+		}); err != nil {
+			ns.Close()
+			return nil, err
+		}
+		if err = ns.Close(); err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// 一時ディレクトリに書き出したセグメントのファイルで元のセグメントのファイルを置き換えて開き直す。
+// 書き出している間に保持期間などで元のセグメントが削除されていれば何もしない。
+func (l *Log) replaceSegment(orig *segment) error {
+	i := -1
+	for j, s := range l.segments {
+		if s == orig {
+			i = j
+			break
+		}
+	}
+	if i == -1 {
+		return nil
+	}
+	if err := orig.Close(); err != nil {
+		return err
+	}
+	var err error
+	for _, ext := range segmentExts {
+		name := fmt.Sprintf("%d%s", orig.baseOffset, ext)
+		if err = os.Rename(
+			filepath.Join(l.Dir, compactionDir, name),
+			filepath.Join(l.Dir, name),
+		); err != nil {
+			break
+		}
+	}
+	// 置き換えに失敗しても、閉じたセグメントを残さないように開き直す
+	s, serr := newSegment(l.Dir, orig.baseOffset, l.Config)
+	if serr != nil {
+		return serr
+	}
+	l.segments[i] = s
+	if !s.isConsistent() {
+		if rerr := l.recover(s); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
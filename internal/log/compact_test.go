@@ -0,0 +1,132 @@
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
+)
+
+func TestLogCompaction(t *testing.T) {
+	setup := func(t *testing.T, c Config, records []*api.Record) (*Log, func()) {
+		t.Helper()
+		dir, err := os.MkdirTemp("", "log-compaction-test")
+		require.NoError(t, err)
+		// 1セグメントに2レコードずつ入るようにする
+		c.Segment.MaxIndexBytes = entWidth * 2
+		log, err := NewLog(dir, c)
+		require.NoError(t, err)
+		for i, record := range records {
+			off, err := log.Append(record)
+			require.NoError(t, err)
+			require.Equal(t, uint64(i), off)
+		}
+		return log, func() {
+			os.RemoveAll(dir)
+		}
+	}
+
+	t.Run("keeps latest record per key", func(t *testing.T) {
+		records := []*api.Record{
+			{Key: []byte("a"), Value: []byte("a1")},
+			{Key: []byte("b"), Value: []byte("b1")},
+			{Key: []byte("a"), Value: []byte("a2")},
+			{Value: []byte("no key")},
+			{Key: []byte("b"), Value: []byte("b2")},
+			{Key: []byte("c"), Value: []byte("c1")},
+			{Key: []byte("a"), Value: []byte("a3")},
+		}
+		log, teardown := setup(t, Config{}, records)
+		defer teardown()
+		require.Equal(t, 4, len(log.segments))
+
+		check := func(t *testing.T, log *Log, next uint64) {
+			t.Helper()
+			// 新しいレコードで上書きされたキーのレコードは読めなくなるが、オフセットは変わらない
+			for _, off := range []uint64{0, 1, 2} {
+				_, err := log.Read(off)
+				require.Equal(t, api.ErrCompacted{Offset: off}, err)
+			}
+			for off := uint64(3); off < uint64(len(records)); off++ {
+				read, err := log.Read(off)
+				require.NoError(t, err)
+				require.Equal(t, off, read.Offset)
+				require.Equal(t, records[off].Value, read.Value)
+			}
+			_, err := log.Read(next)
+			require.Equal(t, api.ErrOffsetOutOfRange{Offset: next}, err)
+			off, err := log.LowestOffset()
+			require.NoError(t, err)
+			require.Equal(t, uint64(0), off)
+		}
+
+		require.NoError(t, log.compact())
+		// 最初のセグメントはすべて削除されて空になる
+		require.Equal(t, uint64(0), log.segments[0].store.Size())
+		check(t, log, uint64(len(records)))
+
+		// 圧縮したセグメントを既存のファイルから読み直しても同じ結果になる
+		require.NoError(t, log.Close())
+		log, err := NewLog(log.Dir, log.Config)
+		require.NoError(t, err)
+		check(t, log, uint64(len(records)))
+		off, err := log.Append(&api.Record{Value: []byte("next")})
+		require.NoError(t, err)
+		require.Equal(t, uint64(len(records)), off)
+
+		// もう一度圧縮しても変わらない
+		require.NoError(t, log.compact())
+		check(t, log, uint64(len(records))+1)
+		require.NoError(t, log.Close())
+	})
+
+	t.Run("tombstones", func(t *testing.T) {
+		records := []*api.Record{
+			{Key: []byte("a"), Value: []byte("a1")},
+			{Key: []byte("a")},
+			{Value: []byte("filler")},
+		}
+		c := Config{}
+		c.Compaction.TombstoneRetention = time.Hour
+		log, teardown := setup(t, c, records)
+		defer teardown()
+
+		// 猶予期間の間はトゥームストーンを残す
+		require.NoError(t, log.compact())
+		_, err := log.Read(0)
+		require.Equal(t, api.ErrCompacted{Offset: 0}, err)
+		read, err := log.Read(1)
+		require.NoError(t, err)
+		require.Equal(t, []byte("a"), read.Key)
+		require.Empty(t, read.Value)
+
+		log.Config.Compaction.TombstoneRetention = time.Nanosecond
+		require.NoError(t, log.compact())
+		_, err = log.Read(1)
+		require.Equal(t, api.ErrCompacted{Offset: 1}, err)
+		require.NoError(t, log.Close())
+	})
+
+	t.Run("background", func(t *testing.T) {
+		records := []*api.Record{
+			{Key: []byte("a"), Value: []byte("a1")},
+			{Key: []byte("a"), Value: []byte("a2")},
+			{Key: []byte("a"), Value: []byte("a3")},
+		}
+		c := Config{}
+		c.Compaction.Enabled = true
+		c.Compaction.CheckInterval = 10 * time.Millisecond
+		log, teardown := setup(t, c, records)
+		defer teardown()
+		require.Eventually(t, func() bool {
+			_, err := log.Read(1)
+			return err == api.ErrCompacted{Offset: 1}
+		}, time.Second, 10*time.Millisecond)
+		read, err := log.Read(2)
+		require.NoError(t, err)
+		require.Equal(t, []byte("a3"), read.Value)
+		require.NoError(t, log.Close())
+	})
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression はストアに書き込むレコードの圧縮方式を表す。
+// 値はフレームの属性にそのまま書き込むので、既存の値を変えてはいけない。
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionSnappy
+	CompressionZstd
+)
+
+// EncodeAllとDecodeAllは複数のゴルーチンから同時に呼び出せるので、ひとつを使い回す
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// pをcで圧縮する。
+func compress(c Compression, p []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return p, nil
+	case CompressionGzip:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(p); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, p), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(p, nil), nil
+	}
+	return nil, fmt.Errorf("log: unknown compression: %d", c)
+}
+
+// cで圧縮されたpを展開する。展開できなければerrCorruptFrameを返す。
+func decompress(c Compression, p []byte) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+	switch c {
+	case CompressionNone:
+		return p, nil
+	case CompressionGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(p)); err == nil {
+			out, err = io.ReadAll(r)
+		}
+	case CompressionSnappy:
+		out, err = snappy.Decode(nil, p)
+	case CompressionZstd:
+		out, err = zstdDecoder.DecodeAll(p, nil)
+	default:
+		return nil, errCorruptFrame
+	}
+	if err != nil {
+		return nil, errCorruptFrame
+	}
+	return out, nil
+}
@@ -1,6 +1,8 @@
 package log
 
 import (
+	"time"
+
 	"github.com/hashicorp/raft"
 )
 
@@ -10,10 +12,59 @@ type Config struct {
 		BindAddr    string
 		StreamLayer *StreamLayer
 		Bootstrap   bool
+		// パーティションのグループのメンバーとリーダーを、メタデータのグループに合わせる間隔。0なら1秒
+		ReconcileInterval time.Duration
+		// グループごとに保持するスナップショットの数。0なら1。
+		// スナップショットを取る間隔は、埋め込んだraft.ConfigのSnapshotThresholdとSnapshotIntervalで設定する
+		SnapshotRetain int
 	}
 	Segment struct {
 		MaxStoreBytes uint64
 		MaxIndexBytes uint64
 		InitialOffset uint64
+		// タイムインデックスのエントリを追加する間隔(ストアに書き込んだバイト数)
+		TimeIndexIntervalBytes uint64
+		// ストアに追加するレコードの圧縮方式。まとめて追加したレコードは1つのフレームにして圧縮する。
+		// フレームごとに圧縮方式を記録するので、途中で変えても既存のレコードは読み出せる。
+		Compression Compression
+		// nilでなければストアに追加するレコードを暗号化する。既存のレコードの復号にも使う。
+		Keyring *Keyring
+		// ストアへの追加をいつディスクにfsyncするか
+		Sync struct {
+			Mode SyncMode
+			// SyncIntervalのとき、最後のfsyncからIntervalが経過するか、
+			// Bytes分書き込まれるとfsyncする。Bytesが0ならバイト数では判定しない。
+			Interval time.Duration
+			Bytes    uint64
+		}
+	}
+	// 古いセグメントを削除する条件。MaxAgeより古いセグメントと、ログ全体のストアのサイズが
+	// MaxBytesを超えている分の古いセグメントをCheckIntervalごとに削除する。0なら制限しない。
+	Retention struct {
+		MaxAge        time.Duration
+		MaxBytes      uint64
+		CheckInterval time.Duration
+		// trueならログ自身では削除しない。DistributedLogがパーティションのリーダーで削除するオフセットを決めて、
+		// その削除をRaftで複製するので、すべてのレプリカで同じセグメントが削除される
+		Replicated bool
+	}
+	// Enabledのとき、CheckIntervalごとにアクティブでないセグメントを書き直して、キーごとに最新のレコードだけを残す。
+	// キーのないレコードは削除しない。トゥームストーンは最新のものでもTombstoneRetentionが過ぎたら削除する。
+	Compaction struct {
+		Enabled            bool
+		TombstoneRetention time.Duration
+		CheckInterval      time.Duration
 	}
 }
+
+// SyncMode はストアに追加したレコードをいつディスクに永続化するかを表す。
+type SyncMode uint8
+
+const (
+	// fsyncはセグメントを閉じるときだけ行い、それまではOSに任せる
+	SyncNever SyncMode = iota
+	// 追加ごとにfsyncしてからAppendを返す。同時に追加されたレコードは1回のfsyncでまとめてコミットする(グループコミット)
+	SyncAlways
+	// 一定の時間か一定のバイト数ごとにバックグラウンドでfsyncする。Appendはfsyncを待たない
+	SyncInterval
+)
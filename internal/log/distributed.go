@@ -2,25 +2,45 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/raft"
-	raftboltdb "github.com/hashicorp/raft-boltdb"
 	api "github.com/yurakawa/proglog/api/v1"
+	"github.com/yurakawa/proglog/internal/discovery"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// DistributedLog はトピックのパーティションごとにRaftのグループを動かして、ログを複製する。
+// トピックの作成と削除はメタデータのグループで複製し、レコードの追加はパーティションのグループで複製するので、
+// パーティションのリーダーを別々のノードに分散させて書き込みを分担できる。
 type DistributedLog struct {
 	config  Config
-	log     *Log
-	raftLog *logStore
-	raft    *raft.Raft
+	dataDir string
+	topics  *Topics
+	meta    *raftGroup
+	logger  *zap.Logger
+
+	mu     sync.RWMutex
+	groups map[string]*raftGroup
+	closed bool
+	// 止めようとしているノード。パーティションのリーダーを移さない
+	leaving map[raft.ServerID]bool
+
+	reconcilec chan struct{}
+	closec     chan struct{}
+	donec      chan struct{}
 }
 
 func NewDistributedLog(dataDir string, config Config) (
@@ -28,14 +48,26 @@ func NewDistributedLog(dataDir string, config Config) (
 	error,
 ) {
 	l := &DistributedLog{
-		config: config,
+		config:     config,
+		dataDir:    dataDir,
+		logger:     zap.L().Named("distributed-log"),
+		groups:     make(map[string]*raftGroup),
+		leaving:    make(map[raft.ServerID]bool),
+		reconcilec: make(chan struct{}, 1),
+		closec:     make(chan struct{}),
+		donec:      make(chan struct{}),
 	}
 	if err := l.setupLog(dataDir); err != nil {
 		return nil, err
 	}
+	// メタデータのRaftがログを再適用する前に、既存のトピックのグループを起動しておく
+	if err := l.setupPartitions(); err != nil {
+		return nil, err
+	}
 	if err := l.setupRaft(dataDir); err != nil {
 		return nil, err
 	}
+	go l.reconcileLoop()
 	return l, nil
 }
 
@@ -44,183 +76,404 @@ func (l *DistributedLog) setupLog(dataDir string) error {
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return err
 	}
+	config := l.config
+	// 保持期間と保持サイズによる削除は、パーティションのリーダーからRaftで複製する
+	config.Retention.Replicated = true
 	var err error
-	l.log, err = NewLog(logDir, l.config)
+	l.topics, err = NewTopics(logDir, config)
 	return err
 }
 
+// メタデータのRaftのグループを起動する
 func (l *DistributedLog) setupRaft(dataDir string) error {
+	var bootstrap []raft.Server
+	if l.config.Raft.Bootstrap {
+		bootstrap = []raft.Server{{
+			ID:      l.config.Raft.LocalID,
+			Address: raft.ServerAddress(l.config.Raft.BindAddr),
+		}}
+	}
 	var err error
-	fsm := &fsm{log: l.log}
-	logDir := filepath.Join(dataDir, "raft", "log")
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return err
+	l.meta, err = newRaftGroup(
+		filepath.Join(dataDir, "raft"),
+		&metadataFSM{topics: l.topics, groups: l},
+		l.config.Raft.StreamLayer,
+		l.config,
+		bootstrap,
+	)
+	return err
+}
+
+// Raft がそれらのコマンドを保存するログストア（log store）
+func (l *DistributedLog) Append(topic string, partition uint32, record *api.Record) (uint64, error) {
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return 0, err
 	}
-	logConfig := l.config
-	logConfig.Segment.InitialOffset = 1
-	l.raftLog, err = newLogStore(logDir, logConfig)
+	// タイムスタンプはリーダーが付与し、フォロワーは複製されたものをそのまま使う
+	record.Timestamp = timestamppb.Now()
+	res, err := g.apply(
+		AppendRequestType,
+		&api.ProduceRequest{Record: record, Topic: topic, Partition: &partition},
+	)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return res.(*api.ProduceResponse).Offset, nil
+}
 
-	stableStore, err := raftboltdb.NewBoltStore(
-		filepath.Join(dataDir, "raft", "stable"),
-	)
+// 複数のレコードをRaftの1エントリとして複製して、まとめてログに追加する。
+// Raftの合意を1回で済ませるので、1件ずつAppendするよりスループットが出る。
+func (l *DistributedLog) AppendBatch(topic string, partition uint32, records []*api.Record) ([]uint64, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	g, err := l.group(topic, partition)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	retain := 1
-	snapshotStore, err := raft.NewFileSnapshotStore(
-		filepath.Join(dataDir, "raft"),
-		retain,
-		os.Stderr,
+	now := timestamppb.Now()
+	for _, record := range records {
+		record.Timestamp = now
+	}
+	res, err := g.apply(
+		AppendBatchRequestType,
+		&api.ProduceBatchRequest{Records: records, Topic: topic, Partition: &partition},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	maxPool := 5
-	timeout := 10 * time.Second
-	transport := raft.NewNetworkTransport(
-		l.config.Raft.StreamLayer,
-		maxPool,
-		timeout,
-		os.Stderr,
-	)
+	return res.(*api.ProduceBatchResponse).Offsets, nil
+}
 
-	config := raft.DefaultConfig()
-	config.LocalID = l.config.Raft.LocalID
-	if l.config.Raft.HeartbeatTimeout != 0 {
-		config.HeartbeatTimeout = l.config.Raft.HeartbeatTimeout
+// InitProducer はプロデューサーのIDの払い出しをメタデータのグループで複製して、クラスタで一意なIDを返す。
+func (l *DistributedLog) InitProducer() (uint64, error) {
+	res, err := l.meta.apply(InitProducerRequestType, &api.InitProducerRequest{})
+	if err != nil {
+		return 0, err
 	}
-	if l.config.Raft.ElectionTimeout != 0 {
-		config.ElectionTimeout = l.config.Raft.ElectionTimeout
+	return res.(*api.InitProducerResponse).ProducerId, nil
+}
+
+// AppendIdempotent はproducerIDのsequenceから始まるレコードをRaftの1エントリとして複製してパーティションに追加する。
+// Applyがタイムアウトしてクライアントが再送しても、FSMが重複を捨てて最初に追加したときのオフセットを返す。
+func (l *DistributedLog) AppendIdempotent(
+	topic string,
+	partition uint32,
+	producerID, sequence uint64,
+	records []*api.Record,
+) ([]uint64, error) {
+	if len(records) == 0 {
+		return nil, nil
 	}
-	if l.config.Raft.LeaderLeaseTimeout != 0 {
-		config.LeaderLeaseTimeout = l.config.Raft.LeaderLeaseTimeout
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return nil, err
 	}
-	if l.config.Raft.CommitTimeout != 0 {
-		config.CommitTimeout = l.config.Raft.CommitTimeout
+	now := timestamppb.Now()
+	for _, record := range records {
+		record.Timestamp = now
 	}
-
-	l.raft, err = raft.NewRaft(
-		config,
-		fsm,
-		l.raftLog,
-		stableStore,
-		snapshotStore,
-		transport,
+	res, err := g.apply(
+		AppendBatchRequestType,
+		&api.ProduceBatchRequest{
+			Records:    records,
+			Topic:      topic,
+			Partition:  &partition,
+			ProducerId: producerID,
+			Sequence:   sequence,
+		},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	hasState, err := raft.HasExistingState(
-		l.raftLog,
-		stableStore,
-		snapshotStore,
-	)
+	return res.(*api.ProduceBatchResponse).Offsets, nil
+}
+
+// CommitOffset はコンシューマーグループのオフセットのコミットをパーティションのグループで複製する。
+// コミットはパーティションのスナップショットに含まれるので、後から参加したノードにも届く。
+func (l *DistributedLog) CommitOffset(topic string, partition uint32, group string, offset uint64) error {
+	// 使えないグループ名はRaftのログに残さない
+	if group == "" {
+		return api.ErrInvalidGroup{Group: group}
+	}
+	g, err := l.group(topic, partition)
 	if err != nil {
 		return err
 	}
-	if l.config.Raft.Bootstrap && !hasState {
-		config := raft.Configuration{
-			Servers: []raft.Server{{
-				ID:      config.LocalID,
-				Address: raft.ServerAddress(l.config.Raft.BindAddr),
-			}},
-		}
-		err = l.raft.BootstrapCluster(config).Error()
-	}
+	_, err = g.apply(
+		CommitOffsetRequestType,
+		&api.CommitOffsetRequest{Group: group, Offset: offset, Topic: topic, Partition: partition},
+	)
 	return err
 }
 
-// Raft がそれらのコマンドを保存するログストア（log store）
-func (l *DistributedLog) Append(record *api.Record) (uint64, error) {
-	res, err := l.apply(
-		AppendRequestType,
-		&api.ProduceRequest{Record: record},
-	)
-	if err != nil {
-		return 0, err
-	}
-	return res.(*api.ProduceResponse).Offset, nil
+// FetchCommittedOffset はこのノードに適用されている、コンシューマーグループがコミットしたオフセットを返す。
+func (l *DistributedLog) FetchCommittedOffset(topic string, partition uint32, group string) (uint64, error) {
+	return l.topics.FetchCommittedOffset(topic, partition, group)
 }
 
-func (l *DistributedLog) apply(reqType RequestType, req proto.Message) (
-	interface{},
-	error,
-) {
-	var buf bytes.Buffer
-	_, err := buf.Write([]byte{byte(reqType)})
-	if err != nil {
+// Partition はkeyを持つレコードを追加するパーティションを返す。
+func (l *DistributedLog) Partition(topic string, key []byte) (uint32, error) {
+	return l.topics.Partition(topic, key)
+}
+
+// トピックの作成をRaftで複製して、すべてのノードでトピックとパーティションのグループを作成する。
+// パーティションのグループは作成した時点のメタデータのグループの投票者で始めるので、
+// すべてのノードが同じ構成でブートストラップする。
+func (l *DistributedLog) CreateTopic(name string, config *api.TopicConfig) (*api.Topic, error) {
+	// 使えない名前や設定はRaftのログに残さない
+	if err := validateTopic(name); err != nil {
+		return nil, err
+	}
+	if err := validateTopicConfig(name, config); err != nil {
 		return nil, err
 	}
-	// 同様にproto.Mershalでrequestをバイト列に変換してbufに書き込む
-	b, err := proto.Marshal(req)
+	servers, err := l.voters()
 	if err != nil {
 		return nil, err
 	}
-	_, err = buf.Write(b)
+	entry := &api.CreateTopicEntry{
+		Request: &api.CreateTopicRequest{Name: name, Config: config},
+	}
+	for _, server := range servers {
+		entry.Servers = append(entry.Servers, &api.Server{
+			Id:      string(server.ID),
+			RpcAddr: string(server.Address),
+		})
+	}
+	res, err := l.meta.apply(CreateTopicEntryRequestType, entry)
 	if err != nil {
 		return nil, err
 	}
+	// 作成してすぐにProduceできるように、パーティションのリーダーが選ばれるまで待つ
+	l.waitForTopic(name, 10*time.Second)
+	return res.(*api.CreateTopicResponse).Topic, nil
+}
+
+// トピックの削除をRaftで複製して、すべてのノードでトピックとそのログを削除する。
+func (l *DistributedLog) DeleteTopic(name string) error {
+	_, err := l.meta.apply(
+		DeleteTopicRequestType,
+		&api.DeleteTopicRequest{Name: name},
+	)
+	return err
+}
+
+// このノードに適用されているトピックの一覧を返す。
+func (l *DistributedLog) ListTopics() ([]*api.Topic, error) {
+	return l.topics.ListTopics()
+}
+
+func (l *DistributedLog) Read(topic string, partition uint32, offset uint64) (*api.Record, error) {
+	return l.topics.Read(topic, partition, offset)
+}
+
+// consistencyで指定された一貫性でパーティションのローカルのログから読み出せるか確認する。
+// LEADER_LEASEはRaftのリーダーがLeaderLeaseTimeoutの間に過半数と通信できなければ降格することを利用して、
+// リーダーであることだけを確認する。LINEARIZABLEはバリアをRaftでコミットして、それまでのエントリが
+// すべて適用されるのを待つので、確認した時点でコミット済みのレコードはすべて読み出せる。
+// パーティションのリーダーでなければapi.ErrNotLeaderを返す。
+func (l *DistributedLog) VerifyRead(
+	ctx context.Context,
+	topic string,
+	partition uint32,
+	consistency api.Consistency,
+) error {
+	if consistency == api.Consistency_ANY {
+		return nil
+	}
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return err
+	}
+	if g.raft.State() != raft.Leader {
+		return g.errNotLeader()
+	}
+	if consistency == api.Consistency_LEADER_LEASE {
+		return nil
+	}
+	// Barrierはタイムアウトが0以下だと無期限に待つので、期限を過ぎていれば待たずに返す
 	timeout := 10 * time.Second
-	// ここでraftにバイト列を渡している
-	// リーダーのログにレコードを追加する?
-	// future object pattern
-	future := l.raft.Apply(buf.Bytes(), timeout)
-	// Error()でresが届くまでblockする。
-	if future.Error() != nil {
-		return nil, future.Error()
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return context.DeadlineExceeded
+		}
 	}
-
-	res := future.Response()
-	if err, ok := res.(error); ok {
-		return nil, err
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.raft.Barrier(timeout).Error()
+	}()
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return res, nil
+	if err == raft.ErrNotLeader || err == raft.ErrLeadershipLost {
+		return g.errNotLeader()
+	}
+	return err
+}
+
+// ローカルのログにオフセットoffのレコードが追加されるまで待つ。
+// フォロワーではリーダーから複製されたレコードが適用されるまで待つことになる。
+func (l *DistributedLog) WaitForOffset(ctx context.Context, topic string, partition uint32, off uint64) error {
+	return l.topics.WaitForOffset(ctx, topic, partition, off)
+}
+
+func (l *DistributedLog) OffsetForTime(topic string, partition uint32, t time.Time) (uint64, error) {
+	return l.topics.OffsetForTime(topic, partition, t)
 }
 
-func (l *DistributedLog) Read(offset uint64) (*api.Record, error) {
-	return l.log.Read(offset)
+func (l *DistributedLog) OffsetRange(topic string, partition uint32) (uint64, uint64, error) {
+	return l.topics.OffsetRange(topic, partition)
+}
+
+// DescribeLog はこのノードのパーティションのログと、そのRaftのグループの状態を返す。
+func (l *DistributedLog) DescribeLog(topic string, partition uint32) (*api.NodeLog, error) {
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	nl, err := l.topics.DescribeLog(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	nl.Id = string(l.config.Raft.LocalID)
+	nl.RpcAddr = l.config.Raft.BindAddr
+	nl.Raft = g.status()
+	return nl, nil
 }
 
 // Raftクラスタにサーバを追加する
-func (l *DistributedLog) Join(id, addr string) error {
+// 読み出し用のレプリカは投票しないサーバとして追加する。
+// パーティションのグループへの追加は、それぞれのリーダーがメタデータのグループに合わせて行う。
+// 止めようとしているノードは、リーダーでなくても記録して、パーティションのリーダーを移さないようにする。
+func (l *DistributedLog) Join(member discovery.Member) error {
+	defer l.triggerReconcile()
+	serverID := raft.ServerID(member.Name)
+	l.mu.Lock()
+	if member.Leaving {
+		l.leaving[serverID] = true
+	} else {
+		delete(l.leaving, serverID)
+	}
+	l.mu.Unlock()
 	// Raftが使用する最新のコンフィグを取得する
-	configFuture := l.raft.GetConfiguration()
+	configFuture := l.meta.raft.GetConfiguration()
 	if err := configFuture.Error(); err != nil {
 		return err
 	}
-	serverID := raft.ServerID(id)
-	serverAddr := raft.ServerAddress(addr)
+	serverAddr := raft.ServerAddress(member.RPCAddr)
+	suffrage := raft.Voter
+	if member.Role == discovery.RoleNonvoter {
+		suffrage = raft.Nonvoter
+	}
 	// Configurationには、最新のコンフィギュレーションが含まれています。これはErrorメソッドが返された後でないと呼び出されてはいけません。
 	for _, srv := range configFuture.Configuration().Servers {
 		// serverID, serverAddrが共に一致する時は既に参加しているので削除しない
 		// どちらか一方ならRemoveServerでJoin対象のserverIDを削除している
 		if srv.ID == serverID || srv.Address == serverAddr { // serverAddrの条件と↓
 			if srv.ID == serverID && srv.Address == serverAddr { // serverIDの条件は不要では
-				// サーバはすでに参加している
-				return nil
+				// サーバはすでに参加している。役割が変わったときだけ投票権を変える
+				switch {
+				case srv.Suffrage == suffrage:
+					return nil
+				case suffrage == raft.Nonvoter:
+					return l.meta.raft.DemoteVoter(serverID, 0, 0).Error()
+				default:
+					return l.meta.raft.AddVoter(serverID, serverAddr, 0, 0).Error()
+				}
 			}
 			// Joinの対象を投票者として再登録するために一度削除する???
 			// 既存のサーバを取り除く
-			removeFuture := l.raft.RemoveServer(serverID, 0, 0)
+			removeFuture := l.meta.raft.RemoveServer(serverID, 0, 0)
 			if err := removeFuture.Error(); err != nil {
 				return err
 			}
 		}
 	}
-	addFuture := l.raft.AddVoter(serverID, serverAddr, 0, 0)
+	var addFuture raft.IndexFuture
+	if suffrage == raft.Nonvoter {
+		addFuture = l.meta.raft.AddNonvoter(serverID, serverAddr, 0, 0)
+	} else {
+		addFuture = l.meta.raft.AddVoter(serverID, serverAddr, 0, 0)
+	}
 	if err := addFuture.Error(); err != nil {
 		return err
 	}
 	return nil
 }
+
 func (l *DistributedLog) Leave(id string) error {
-	removeFuture := l.raft.RemoveServer(raft.ServerID(id), 0, 0)
+	defer l.triggerReconcile()
+	l.mu.Lock()
+	delete(l.leaving, raft.ServerID(id))
+	l.mu.Unlock()
+	removeFuture := l.meta.raft.RemoveServer(raft.ServerID(id), 0, 0)
 	return removeFuture.Error()
 }
 
+// AddVoter はidのノードを投票者としてクラスタに追加する。
+// Serfで参加していないノードも追加できる。メタデータのグループのリーダーでなければapi.ErrNotLeaderを返す。
+func (l *DistributedLog) AddVoter(id, addr string) error {
+	err := l.Join(discovery.Member{Name: id, RPCAddr: addr, Role: discovery.RoleVoter})
+	if errors.Is(err, raft.ErrNotLeader) {
+		return l.meta.errNotLeader()
+	}
+	return err
+}
+
+// RemoveServer はidのノードをクラスタから取り除く。パーティションのグループからも取り除かれる。
+// メタデータのグループのリーダーでなければapi.ErrNotLeaderを返す。
+func (l *DistributedLog) RemoveServer(id string) error {
+	err := l.Leave(id)
+	if errors.Is(err, raft.ErrNotLeader) {
+		return l.meta.errNotLeader()
+	}
+	return err
+}
+
+// TakeSnapshot はこのノードのパーティションのグループのスナップショットを取って、そのインデックスとタームを返す。
+// 前のスナップショットから新しいエントリがなければ、前のスナップショットのものを返す。
+func (l *DistributedLog) TakeSnapshot(topic string, partition uint32) (index, term uint64, err error) {
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	future := g.raft.Snapshot()
+	if err = future.Error(); errors.Is(err, raft.ErrNothingNewToSnapshot) {
+		status := g.status()
+		return status.LastSnapshotIndex, status.LastSnapshotTerm, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	meta, rc, err := future.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
+	return meta.Index, meta.Term, nil
+}
+
+// TruncateBefore はオフセットoffより前のセグメントの削除をパーティションのグループで複製して、
+// このノードのログに残っている最も古いオフセットを返す。
+func (l *DistributedLog) TruncateBefore(topic string, partition uint32, off uint64) (uint64, error) {
+	g, err := l.group(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	res, err := g.apply(
+		TruncateBeforeRequestType,
+		&api.TruncateBeforeRequest{Topic: topic, Partition: partition, Offset: off},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.(*api.TruncateBeforeResponse).LowestOffset, nil
+}
+
+// メタデータのグループとすべてのパーティションのグループでリーダーが選ばれるまで待つ。
 func (l *DistributedLog) WaitForLeader(timeout time.Duration) error {
 	timeoutc := time.After(timeout)
 	ticker := time.NewTicker(time.Second)
@@ -233,29 +486,84 @@ func (l *DistributedLog) WaitForLeader(timeout time.Duration) error {
 			return fmt.Errorf("timed out")
 		case <-ticker.C:
 			// リーダーが選出されているか確認しに行く
-			if l := l.raft.Leader(); l != "" {
+			if l.meta.raft.Leader() == "" {
+				continue
+			}
+			if l.hasLeaders(func(string) bool { return true }) {
 				return nil
 			}
 		}
 	}
 }
 
+// このノードがリーダーのグループのリーダーを、ログが最も進んでいる投票者に移す。
+// 止める前に呼び出して、リーダーの選挙を待たずにほかのノードが書き込みを受け付けられるようにする。
+// ほかに投票者がいないグループはそのままにする。
+func (l *DistributedLog) TransferLeadership() error {
+	l.mu.RLock()
+	groups := make([]*raftGroup, 0, len(l.groups)+1)
+	for _, g := range l.groups {
+		groups = append(groups, g)
+	}
+	l.mu.RUnlock()
+	// パーティションのグループを先に移して、書き込めない時間を短くする
+	groups = append(groups, l.meta)
+	var errs []error
+	for _, g := range groups {
+		if g.raft.State() != raft.Leader {
+			continue
+		}
+		future := g.raft.GetConfiguration()
+		if err := future.Error(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		voters := 0
+		for _, server := range future.Configuration().Servers {
+			if server.Suffrage == raft.Voter {
+				voters++
+			}
+		}
+		if voters < 2 {
+			continue
+		}
+		if err := g.raft.LeadershipTransfer().Error(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (l *DistributedLog) Close() error {
-	// Raftいんすたんすをしゃっとだうん
-	f := l.raft.Shutdown()
-	if err := f.Error(); err != nil {
-		return err
+	// パーティションのグループを先に止める。メタデータのグループのストリームレイヤを閉じるとリスナーも閉じる。
+	// 調整のループが終わるのを待つのは、その後にする。止まったノードに構成の変更を複製できずに待っている調整は、
+	// グループを止めると終わる
+	l.mu.Lock()
+	// 2回目以降は何もしない
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.closec)
+	groups := l.groups
+	l.groups = make(map[string]*raftGroup)
+	l.mu.Unlock()
+	for _, g := range groups {
+		if err := g.close(); err != nil {
+			return err
+		}
 	}
-	// RaftのログストアをClose
-	if err := l.raftLog.Log.Close(); err != nil {
+	<-l.donec
+	if err := l.meta.close(); err != nil {
 		return err
 	}
 	// RaftのローカルログをClose
-	return l.log.Close()
+	return l.topics.Close()
 }
 
 func (l *DistributedLog) GetServers() ([]*api.Server, error) {
-	future := l.raft.GetConfiguration()
+	future := l.meta.raft.GetConfiguration()
 	if err := future.Error(); err != nil {
 		return nil, err
 	}
@@ -264,49 +572,271 @@ func (l *DistributedLog) GetServers() ([]*api.Server, error) {
 		servers = append(servers, &api.Server{
 			Id:       string(server.ID),
 			RpcAddr:  string(server.Address),
-			IsLeader: l.raft.Leader() == server.Address,
+			IsLeader: l.meta.raft.Leader() == server.Address,
+			Suffrage: suffrage(server.Suffrage),
 		})
 	}
 	return servers, nil
 }
 
-var _ raft.FSM = (*fsm)(nil)
+// GetPartitions はすべてのトピックのパーティションと、このノードが知っているそのリーダーのアドレスを返す。
+func (l *DistributedLog) GetPartitions() ([]*api.Partition, error) {
+	topics, err := l.topics.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+	var partitions []*api.Partition
+	for _, topic := range topics {
+		for p := uint32(0); p < topic.Config.Partitions; p++ {
+			partition := &api.Partition{Topic: topic.Name, Id: p}
+			l.mu.RLock()
+			g, ok := l.groups[groupID(topic.Name, p)]
+			l.mu.RUnlock()
+			if ok {
+				partition.LeaderAddr = string(g.raft.Leader())
+			}
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, nil
+}
+
+// メタデータのグループの投票者をIDの順に返す。
+func (l *DistributedLog) voters() ([]raft.Server, error) {
+	servers, err := l.servers()
+	if err != nil {
+		return nil, err
+	}
+	return votersOf(servers), nil
+}
+
+// メタデータのグループのすべてのサーバをIDの順に返す。
+func (l *DistributedLog) servers() ([]raft.Server, error) {
+	future := l.meta.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+	servers := future.Configuration().Servers
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].ID < servers[j].ID
+	})
+	return servers, nil
+}
+
+// パーティションのリーダーにできる投票者を返す。止めようとしているノードは除く。
+func (l *DistributedLog) leaderCandidates(servers []raft.Server) []raft.Server {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var candidates []raft.Server
+	for _, server := range votersOf(servers) {
+		if !l.leaving[server.ID] {
+			candidates = append(candidates, server)
+		}
+	}
+	return candidates
+}
+
+func votersOf(servers []raft.Server) []raft.Server {
+	var voters []raft.Server
+	for _, server := range servers {
+		if server.Suffrage == raft.Voter {
+			voters = append(voters, server)
+		}
+	}
+	return voters
+}
 
-type fsm struct {
-	log *Log
+func suffrage(s raft.ServerSuffrage) api.Suffrage {
+	if s == raft.Voter {
+		return api.Suffrage_VOTER
+	}
+	return api.Suffrage_NONVOTER
+}
+
+var _ raft.FSM = (*metadataFSM)(nil)
+
+// metadataFSM はメタデータのグループのFSMで、トピックの一覧を管理する。
+type metadataFSM struct {
+	topics *Topics
+	groups partitionGroups
+	// パーティションを導入する前のログにあるレコードの追加を適用し直すときに、
+	// 次のエントリのレコードが追加されたはずのオフセット。トピックごとに持つ
+	legacyOffsets map[string]uint64
+}
+
+// partitionGroups はトピックのパーティションのRaftのグループを起動、停止する。
+type partitionGroups interface {
+	// serversが空でなく、このノードを含んでいれば、そのサーバでグループをブートストラップする
+	startTopic(topic *api.Topic, servers []*api.Server) error
+	stopTopic(name string) error
 }
 
 type RequestType uint8
 
 const (
-	AppendRequestType RequestType = 0
+	AppendRequestType      RequestType = 0
+	AppendBatchRequestType RequestType = 1
+	CreateTopicRequestType RequestType = 2
+	DeleteTopicRequestType RequestType = 3
+	// パーティションのグループをブートストラップするサーバを含むトピックの作成
+	CreateTopicEntryRequestType RequestType = 4
+	// コンシューマーグループのオフセットのコミット。パーティションのグループで複製する
+	CommitOffsetRequestType RequestType = 5
+	// プロデューサーのIDの払い出し。メタデータのグループで複製する
+	InitProducerRequestType RequestType = 6
+	// 古いセグメントの削除。パーティションのグループで複製する
+	TruncateBeforeRequestType RequestType = 7
 )
 
-func (l *fsm) Apply(record *raft.Log) interface{} {
+func (f *metadataFSM) Apply(record *raft.Log) interface{} {
 	buf := record.Data
 	reqType := RequestType(buf[0])
 	switch reqType {
-	case AppendRequestType:
-		return l.applyAppend(buf[1:])
+	case CreateTopicRequestType:
+		var req api.CreateTopicRequest
+		if err := proto.Unmarshal(buf[1:], &req); err != nil {
+			return err
+		}
+		return f.applyCreateTopic(&api.CreateTopicEntry{Request: &req})
+	case CreateTopicEntryRequestType:
+		var entry api.CreateTopicEntry
+		if err := proto.Unmarshal(buf[1:], &entry); err != nil {
+			return err
+		}
+		return f.applyCreateTopic(&entry)
+	case DeleteTopicRequestType:
+		return f.applyDeleteTopic(buf[1:])
+	case InitProducerRequestType:
+		id, err := f.topics.InitProducer()
+		if err != nil {
+			return err
+		}
+		return &api.InitProducerResponse{ProducerId: id}
+	case AppendRequestType, AppendBatchRequestType:
+		return f.applyLegacyAppend(reqType, buf[1:])
 	}
 	return nil
 }
-func (l *fsm) applyAppend(b []byte) interface{} {
-	var req api.ProduceRequest
-	err := proto.Unmarshal(b, &req)
+
+// パーティションを導入する前のログにあるレコードの追加を、トピックのパーティション0のログに適用する。
+// 起動するとRaftは最後のスナップショットより後のエントリを適用し直すが、そのレコードはローカルのログに追加済みのことがある。
+// エントリのレコードが追加されたはずのオフセットを数えて、まだログにないレコードだけを追加する。
+func (f *metadataFSM) applyLegacyAppend(reqType RequestType, b []byte) interface{} {
+	var topic string
+	var records []*api.Record
+	if reqType == AppendRequestType {
+		var req api.ProduceRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			return err
+		}
+		topic, records = req.Topic, []*api.Record{req.Record}
+	} else {
+		var req api.ProduceBatchRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			return err
+		}
+		topic, records = req.Topic, req.Records
+	}
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	l, err := f.topics.Log(topic, 0)
 	if err != nil {
 		return err
 	}
-	offset, err := l.log.Append(req.Record)
+	off, ok := f.legacyOffsets[topic]
+	if !ok {
+		off = f.topics.Config.Segment.InitialOffset
+	}
+	next := l.nextOffset()
+	var pending []*api.Record
+	for _, record := range records {
+		if off >= next {
+			pending = append(pending, record)
+		}
+		off++
+	}
+	f.setLegacyOffset(topic, off)
+	if len(pending) == 0 {
+		return nil
+	}
+	if _, err = l.AppendBatch(pending); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *metadataFSM) setLegacyOffset(topic string, off uint64) {
+	if f.legacyOffsets == nil {
+		f.legacyOffsets = make(map[string]uint64)
+	}
+	f.legacyOffsets[topic] = off
+}
+
+func (f *metadataFSM) applyCreateTopic(entry *api.CreateTopicEntry) interface{} {
+	topic, err := f.topics.CreateTopic(entry.Request.Name, entry.Request.Config)
 	if err != nil {
 		return err
 	}
-	return &api.ProduceResponse{Offset: offset}
+	if err = f.groups.startTopic(topic, entry.Servers); err != nil {
+		return err
+	}
+	return &api.CreateTopicResponse{Topic: topic}
 }
 
-func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
-	r := f.log.Reader()
-	return &snapshot{reader: r}, nil
+func (f *metadataFSM) applyDeleteTopic(b []byte) interface{} {
+	var req api.DeleteTopicRequest
+	err := proto.Unmarshal(b, &req)
+	if err != nil {
+		return err
+	}
+	if req.Name != DefaultTopic {
+		if err = f.groups.stopTopic(req.Name); err != nil {
+			return err
+		}
+	}
+	if err = f.topics.DeleteTopic(req.Name); err != nil {
+		return err
+	}
+	return &api.DeleteTopicResponse{}
+}
+
+// メタデータのスナップショットは先頭のmetadataSnapshotMagicに続けて、最後に払い出したプロデューサーのIDと、
+// トピックを次の形式で並べたもの。レコードはパーティションのグループのスナップショットに含める。
+//
+//	| last producer id (8) | topic size (8) | Topic | topic size (8) | Topic | ...
+//
+// 冪等なプロデューサーを導入する前のスナップショットは先頭がtopicsSnapshotMagicで、プロデューサーのIDがない。
+//
+// パーティションを導入する前のスナップショットはレコードを含む。先頭がsnapshotMagicのものはトピックごとに
+//
+//	| topic size (8) | Topic | lowest offset (8) | store size (8) | store frames |
+//
+// を並べたもので、トピックを導入する前のものはデフォルトのトピックのストアのフレームをそのまま並べたもの。
+// どちらもレコードはパーティション0に復元する。
+const (
+	metadataSnapshotMagic = "proglog\x06"
+	topicsSnapshotMagic   = "proglog\x03"
+	snapshotMagic         = "proglog\x02"
+)
+
+func (f *metadataFSM) Snapshot() (raft.FSMSnapshot, error) {
+	// SnapshotはApplyと同時に呼ばれないので、トピックの一覧は変わらない
+	topics, err := f.topics.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(metadataSnapshotMagic)
+	h := make([]byte, lenWidth)
+	enc.PutUint64(h, f.topics.producerID())
+	buf.Write(h)
+	for _, topic := range topics {
+		if err = writeSnapshotMessage(&buf, topic); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshot{reader: &buf}, nil
 }
 
 var _ raft.FSMSnapshot = (*snapshot)(nil)
@@ -324,108 +854,355 @@ func (s *snapshot) Persist(sink raft.SnapshotSink) error {
 }
 func (s *snapshot) Release() {}
 
-func (f *fsm) Restore(r io.ReadCloser) error {
-	b := make([]byte, lenWidth)
-	var buf bytes.Buffer
-	for i := 0; ; i++ {
-		_, err := io.ReadFull(r, b)
+func (f *metadataFSM) Restore(r io.ReadCloser) error {
+	// パーティションを導入する前のログのエントリは、復元したログの続きのオフセットに追加されていた
+	f.legacyOffsets = nil
+	magic := make([]byte, len(snapshotMagic))
+	n, err := io.ReadFull(r, magic)
+	switch {
+	case err == io.EOF:
+		return f.syncTopics(nil)
+	case string(magic) == metadataSnapshotMagic:
+		h := make([]byte, lenWidth)
+		if _, err = io.ReadFull(r, h); err != nil {
+			return err
+		}
+		if err = f.topics.resetProducerID(enc.Uint64(h)); err != nil {
+			return err
+		}
+		return f.restoreTopics(r)
+	case string(magic) == topicsSnapshotMagic:
+		return f.restoreTopics(r)
+	case string(magic) == snapshotMagic:
+		return f.restoreTopicsWithRecords(r)
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return err
+	default:
+		return f.restoreLegacy(io.MultiReader(bytes.NewReader(magic[:n]), r))
+	}
+}
+
+func (f *metadataFSM) restoreTopics(r io.Reader) error {
+	var topics []*api.Topic
+	for {
+		topic, err := readSnapshotTopic(r)
 		if err == io.EOF {
-			break
+			return f.syncTopics(topics)
 		} else if err != nil {
 			return err
 		}
-		size := int64(enc.Uint64(b))
-		if _, err = io.CopyN(&buf, r, size); err != nil {
+		topics = append(topics, topic)
+	}
+}
+
+// パーティションを導入する前のスナップショットのトピックとレコードを復元する。
+func (f *metadataFSM) restoreTopicsWithRecords(r io.Reader) error {
+	var topics []*api.Topic
+	for {
+		topic, err := readSnapshotTopic(r)
+		if err == io.EOF {
+			return f.syncTopics(topics)
+		} else if err != nil {
 			return err
 		}
-		record := &api.Record{}
-		if err = proto.Unmarshal(buf.Bytes(), record); err != nil {
+		topics = append(topics, topic)
+		if err = f.syncTopic(topic); err != nil {
 			return err
 		}
-		if i == 0 {
-			f.log.Config.Segment.InitialOffset = record.Offset
-			if err := f.log.Reset(); err != nil {
-				return err
-			}
+		h := make([]byte, 2*lenWidth)
+		if _, err = io.ReadFull(r, h); err != nil {
+			return err
+		}
+		l, err := f.topics.installPartition(topic.Name, 0, enc.Uint64(h[:lenWidth]), nil)
+		if err != nil {
+			return err
 		}
-		if _, err = f.log.Append(record); err != nil {
+		if err = restoreRecords(l, io.LimitReader(r, int64(enc.Uint64(h[lenWidth:])))); err != nil {
 			return err
 		}
-		buf.Reset()
+		f.setLegacyOffset(topic.Name, l.nextOffset())
 	}
-	return nil
 }
 
-var _ raft.LogStore = (*logStore)(nil)
-
-type logStore struct {
-	*Log
+// トピックを導入する前のスナップショットを、デフォルトのトピックに復元する。
+// フレームにチェックサムを入れる前のスナップショットは | length (8) | Record | を並べたもので、
+// 先頭のフレームがチェックサムで検証できなければそちらとして読む。
+func (f *metadataFSM) restoreLegacy(r io.Reader) error {
+	if err := f.syncTopics(nil); err != nil {
+		return err
+	}
+	h := make([]byte, lenWidth)
+	if _, err := io.ReadFull(r, h); err == io.EOF {
+		_, err = f.topics.installPartition(DefaultTopic, 0, 0, nil)
+		return err
+	} else if err != nil {
+		return err
+	}
+	first := bytes.NewBuffer(h)
+	if _, err := io.CopyN(first, r, int64(enc.Uint64(h))+headerWidth-lenWidth); err != nil && err != io.EOF {
+		return err
+	}
+	_, _, _, err := readFrame(bytes.NewReader(first.Bytes()), f.topics.Config.Segment.Keyring)
+	r = io.MultiReader(first, r)
+	read := frameRecords(r, f.topics.Config.Segment.Keyring)
+	if err == errCorruptFrame {
+		read = legacyFrameRecords(r)
+	}
+	// ログは最初のレコードのオフセットから始める
+	records, err := read()
+	if err != nil {
+		return err
+	}
+	l, err := f.topics.installPartition(DefaultTopic, 0, records[0].Offset, nil)
+	if err != nil {
+		return err
+	}
+	if err = appendRecords(l, records, read); err != nil {
+		return err
+	}
+	f.setLegacyOffset(DefaultTopic, l.nextOffset())
+	return nil
 }
 
-func newLogStore(dir string, c Config) (*logStore, error) {
-	log, err := NewLog(dir, c)
+// ローカルのトピックをtopicsに合わせる。topicsにないトピックは削除する。デフォルトのトピックは常に残す。
+func (f *metadataFSM) syncTopics(topics []*api.Topic) error {
+	want := map[string]bool{DefaultTopic: true}
+	for _, topic := range topics {
+		want[topic.Name] = true
+		if err := f.syncTopic(topic); err != nil {
+			return err
+		}
+	}
+	current, err := f.topics.ListTopics()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for _, topic := range current {
+		if want[topic.Name] {
+			continue
+		}
+		if err = f.groups.stopTopic(topic.Name); err != nil {
+			return err
+		}
+		if err = f.topics.DeleteTopic(topic.Name); err != nil {
+			return err
+		}
 	}
-	return &logStore{log}, nil
+	return nil
 }
 
-func (l *logStore) FirstIndex() (uint64, error) {
-	return l.LowestOffset()
-}
-func (l *logStore) LastIndex() (uint64, error) {
-	off, err := l.HighestOffset()
-	return off, err
-}
-func (l *logStore) GetLog(index uint64, out *raft.Log) error {
-	in, err := l.Read(index)
+// ローカルにtopicと同じ設定のトピックがなければ作成する。設定が異なるものは作り直す。
+func (f *metadataFSM) syncTopic(topic *api.Topic) error {
+	config := normalizeTopicConfig(topic.Config)
+	current, err := f.topics.ListTopics()
 	if err != nil {
 		return err
 	}
-	out.Data = in.Value
-	out.Index = in.Offset
-	out.Type = raft.LogType(in.Type)
-	out.Term = in.Term
-	return nil
+	for _, c := range current {
+		if c.Name != topic.Name {
+			continue
+		}
+		if proto.Equal(c.Config, config) || c.Name == DefaultTopic {
+			return nil
+		}
+		if err = f.groups.stopTopic(c.Name); err != nil {
+			return err
+		}
+		if err = f.topics.DeleteTopic(c.Name); err != nil {
+			return err
+		}
+	}
+	created, err := f.topics.CreateTopic(topic.Name, config)
+	if err != nil {
+		return err
+	}
+	// このノードは後からクラスタに参加したので、パーティションのリーダーに追加されるのを待つ
+	return f.groups.startTopic(created, nil)
 }
 
-func (l *logStore) StoreLog(record *raft.Log) error {
-	return l.StoreLogs([]*raft.Log{record})
+func readSnapshotTopic(r io.Reader) (*api.Topic, error) {
+	topic := &api.Topic{}
+	return topic, readSnapshotMessage(r, topic)
 }
-func (l *logStore) StoreLogs(records []*raft.Log) error {
-	for _, record := range records {
-		if _, err := l.Append(&api.Record{
-			Value: record.Data,
-			Term:  record.Term,
-			Type:  uint32(record.Type),
-		}); err != nil {
+
+// スナップショットのフレームを1件ずつ読み出して、チェックサムを検証しながらlに追加する。
+func restoreRecords(l *Log, r io.Reader) error {
+	read := frameRecords(r, l.Config.Segment.Keyring)
+	records, err := read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return appendRecords(l, records, read)
+}
+
+// recordsに続けて、readがio.EOFを返すまで読み出したレコードをlに追加する。
+func appendRecords(l *Log, records []*api.Record, read func() ([]*api.Record, error)) error {
+	for {
+		if _, err := l.AppendBatch(records); err != nil {
+			return err
+		}
+		var err error
+		if records, err = read(); err == io.EOF {
+			return nil
+		} else if err != nil {
 			return err
 		}
 	}
-	return nil
 }
 
-func (l *logStore) DeleteRange(min, max uint64) error {
-	return l.Truncate(max)
+// rに並んだストアのフレームを1つずつ読み出して、含まれるレコードを返す関数を返す。
+func frameRecords(r io.Reader, k *Keyring) func() ([]*api.Record, error) {
+	return func() ([]*api.Record, error) {
+		p, attrs, _, err := readFrame(r, k)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRecords(p, attrs)
+	}
+}
+
+// rに並んだチェックサムのないフレーム(| length (8) | Record |)を1つずつ読み出して、レコードを返す関数を返す。
+func legacyFrameRecords(r io.Reader) func() ([]*api.Record, error) {
+	return func() ([]*api.Record, error) {
+		h := make([]byte, lenWidth)
+		if _, err := io.ReadFull(r, h); err == io.ErrUnexpectedEOF {
+			return nil, errCorruptFrame
+		} else if err != nil {
+			return nil, err
+		}
+		size := enc.Uint64(h)
+		var b bytes.Buffer
+		if n, err := io.CopyN(&b, r, int64(size)); err != nil && err != io.EOF {
+			return nil, err
+		} else if uint64(n) != size {
+			return nil, errCorruptFrame
+		}
+		return decodeRecords(b.Bytes(), 0)
+	}
 }
 
 var _ raft.StreamLayer = (*StreamLayer)(nil)
 
+// StreamLayer はRaftのグループのノード間の接続を扱う。すべてのグループが1つのリスナーを共有し、
+// 接続の先頭に書き込んだグループのIDで、どのグループの接続かを識別する。
+//
+//	| RaftRPC (1) | group ID size (1) | group ID |
 type StreamLayer struct {
-	ln              net.Listener
+	group           string
+	mux             *streamMux
 	serverTLSConfig *tls.Config
 	peerTLSConfig   *tls.Config
+
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// streamMux はリスナーが受け付けた接続を、グループのIDでそのグループのストリームレイヤに振り分ける。
+type streamMux struct {
+	ln net.Listener
+
+	mu     sync.Mutex
+	layers map[string]*StreamLayer
 }
 
+// NewStreamLayer はlnで接続を受け付けて、メタデータのグループ("")のストリームレイヤを返す。
+// ほかのグループのストリームレイヤはGroupで作る。
 func NewStreamLayer(ln net.Listener, serverTLSConfig, peerTLSConfig *tls.Config) *StreamLayer {
-	return &StreamLayer{
-		ln:              ln,
+	m := &streamMux{
+		ln:     ln,
+		layers: make(map[string]*StreamLayer),
+	}
+	s, _ := m.layer("", serverTLSConfig, peerTLSConfig)
+	go m.serve()
+	return s
+}
+
+const RaftRPC = 1
+
+// グループのIDの長さは1バイトで書き込む
+const maxGroupIDLen = 255
+
+// 接続してからグループのIDを受け取るまでの制限時間
+const handshakeTimeout = 10 * time.Second
+
+// Group はリスナーを共有して、groupのRaftのグループの接続だけを受け付けるストリームレイヤを返す。
+func (s *StreamLayer) Group(group string) (*StreamLayer, error) {
+	if len(group) > maxGroupIDLen {
+		return nil, fmt.Errorf("group id is too long: %q", group)
+	}
+	return s.mux.layer(group, s.serverTLSConfig, s.peerTLSConfig)
+}
+
+func (m *streamMux) layer(group string, serverTLSConfig, peerTLSConfig *tls.Config) (*StreamLayer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.layers[group]; ok {
+		return nil, fmt.Errorf("stream layer for group %q already exists", group)
+	}
+	s := &StreamLayer{
+		group:           group,
+		mux:             m,
 		serverTLSConfig: serverTLSConfig,
 		peerTLSConfig:   peerTLSConfig,
+		conns:           make(chan net.Conn),
+		closed:          make(chan struct{}),
 	}
+	m.layers[group] = s
+	return s, nil
 }
 
-const RaftRPC = 1
+// リスナーを閉じるまで接続を受け付ける。グループのIDを読み込むのは接続ごとのゴルーチンで行うので、
+// 遅いピアがほかの接続を妨げることはない。
+func (m *streamMux) serve() {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+func (m *streamMux) handle(conn net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+	// コネクション種別を識別するためのRaftRPCバイトと、グループのIDを読み込む
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(conn, h); err != nil || h[0] != byte(RaftRPC) {
+		conn.Close()
+		return
+	}
+	group := make([]byte, h[1])
+	if _, err := io.ReadFull(conn, group); err != nil {
+		conn.Close()
+		return
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return
+	}
+	m.mu.Lock()
+	s, ok := m.layers[string(group)]
+	m.mu.Unlock()
+	// このノードでまだ作られていないか、削除されたグループへの接続。ピアは後でやり直す
+	if !ok {
+		conn.Close()
+		return
+	}
+	if s.serverTLSConfig != nil {
+		conn = tls.Server(conn, s.serverTLSConfig)
+	}
+	select {
+	case s.conns <- conn:
+	case <-s.closed:
+		conn.Close()
+	}
+}
 
 func (s *StreamLayer) Dial(addr raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
 	dialer := &net.Dialer{Timeout: timeout}
@@ -438,8 +1215,11 @@ func (s *StreamLayer) Dial(addr raft.ServerAddress, timeout time.Duration) (net.
 	}
 
 	// コネクション種別を識別するためにRaftRPCバイトを書き込む。ログのgRPCリクエストと同じポートでRaftを多重化できる
-	_, err = conn.Write([]byte{byte(RaftRPC)})
+	// 続けて、接続先のストリームレイヤを選ぶためのグループのIDを書き込む
+	h := append([]byte{byte(RaftRPC), byte(len(s.group))}, s.group...)
+	_, err = conn.Write(h)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
@@ -452,32 +1232,30 @@ func (s *StreamLayer) Dial(addr raft.ServerAddress, timeout time.Duration) (net.
 
 // Dialに対応する。
 func (s *StreamLayer) Accept() (net.Conn, error) {
-	conn, err := s.ln.Accept()
-	if err != nil {
-		return nil, err
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-s.closed:
+		return nil, net.ErrClosed
 	}
-	// コネクション種別を識別するためにRaftRPCバイトを読み込む
-	b := make([]byte, 1)
-	_, err = conn.Read(b)
-	if err != nil {
-		return nil, err
-	}
-
-	if !bytes.Equal([]byte{byte(RaftRPC)}, b) {
-		return nil, fmt.Errorf("not a raft rpc")
-	}
-	if s.serverTLSConfig != nil {
-		return tls.Server(conn, s.serverTLSConfig), nil
-	}
-	return conn, nil
 }
 
-// リスナーをクローズする
+// ストリームレイヤをクローズする。メタデータのグループのストリームレイヤならリスナーもクローズする
 func (s *StreamLayer) Close() error {
-	return s.ln.Close()
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.mux.mu.Lock()
+		delete(s.mux.layers, s.group)
+		s.mux.mu.Unlock()
+		if s.group == "" {
+			err = s.mux.ln.Close()
+		}
+	})
+	return err
 }
 
 // リスナーのアドレスを返す
 func (s *StreamLayer) Addr() net.Addr {
-	return s.ln.Addr()
+	return s.mux.ln.Addr()
 }
@@ -1,9 +1,12 @@
 package log_test
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -12,71 +15,31 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/travisjeffery/go-dynaport"
 	api "github.com/yurakawa/proglog/api/v1"
+	"github.com/yurakawa/proglog/internal/discovery"
 	"github.com/yurakawa/proglog/internal/log"
 )
 
 func TestMultipleNodes(t *testing.T) {
-	var logs []*log.DistributedLog
 	// 3つのサーバから構成されるクラスタを設定する。
 	nodeCount := 3
-	ports := dynaport.Get(nodeCount) // 空いているportをとってくる
-	for i := 0; i < nodeCount; i++ {
-		dataDir, err := os.MkdirTemp("", "distributed-log-test")
-		require.NoError(t, err)
-		// for loopのなかでdeferしている。メモリ食うので推奨されていないけどcountが3でtestだからいいか
-		defer func(dir string) {
-			_ = os.RemoveAll(dir)
-		}(dataDir)
-		ln, err := net.Listen(
-			"tcp",
-			fmt.Sprintf("127.0.0.1:%d", ports[i]),
-		)
-		require.NoError(t, err)
-		config := log.Config{}
-		config.Raft.StreamLayer = log.NewStreamLayer(ln, nil, nil)
-		config.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
-		// タイムアウト設定を短くして、Raftが素早くリーダを選出できるようにする。
-		config.Raft.HeartbeatTimeout = 100 * time.Millisecond
-		config.Raft.ElectionTimeout = 100 * time.Millisecond
-		config.Raft.LeaderLeaseTimeout = 100 * time.Millisecond
-		config.Raft.CommitTimeout = 5 * time.Millisecond
-		config.Raft.BindAddr = ln.Addr().String()
-
-		// 一つ目のサーバは、クラスタをブートストラップしてリーダーになり、残りの二つのサーバをク
-		// ラスタに追加しています。この後、リーダーは他のサーバをそのクラスタに参加させる必要があり
-		// ます。
-		if i == 0 {
-			config.Raft.Bootstrap = true
-		}
-		l, err := log.NewDistributedLog(dataDir, config)
-		require.NoError(t, err)
-		if i != 0 {
-			err = logs[0].Join(
-				fmt.Sprintf("%d", i), ln.Addr().String(),
-			)
-			require.NoError(t, err)
-		} else {
-			err = l.WaitForLeader(3 * time.Second)
-			require.NoError(t, err)
-		}
-		logs = append(logs, l)
-	}
+	logs, addrs := setupCluster(t, nodeCount)
 
 	// リーダーのサーバにレコードを追加し、Raft がそのレコードをフォロワーに複製したことを確認
 	// することで、レプリケーションをテストします。Raft のフォロワーは短い待ち時間の後に追加メッ
 	// セージ（AppendRequestTypeのメッセージ）を適用するので、testifyのEventuallyメソッド
 	// を使ってRaft の複製が終了するのに十分な時間を与えています。
+	// レコードはパーティションのリーダーに追加する。リーダーはノードに分散するので、最初のノードとは限らない
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
 	records := []*api.Record{
 		{Value: []byte("first")},
 		{Value: []byte("second")},
 	}
 	for _, record := range records {
-		// 0番目のサーバにappendを呼ぶ
-		off, err := logs[0].Append(record)
+		off, err := logs[leader].Append("", 0, record)
 		require.NoError(t, err)
 		require.Eventually(t, func() bool {
 			for j := 0; j < nodeCount; j++ {
-				got, err := logs[j].Read(off)
+				got, err := logs[j].Read("", 0, off)
 				if err != nil {
 					return false
 				}
@@ -89,6 +52,88 @@ func TestMultipleNodes(t *testing.T) {
 			return true
 		}, 500*time.Millisecond, 50*time.Millisecond)
 	}
+	// バッチは1つのエントリとして複製される
+	batch := []*api.Record{
+		{Value: []byte("batch 1")},
+		{Value: []byte("batch 2")},
+		{Value: []byte("batch 3")},
+	}
+	offs, err := logs[leader].AppendBatch("", 0, batch)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3, 4}, offs)
+	require.Eventually(t, func() bool {
+		for j := 0; j < nodeCount; j++ {
+			for i, off := range offs {
+				got, err := logs[j].Read("", 0, off)
+				if err != nil || !reflect.DeepEqual(got.Value, batch[i].Value) {
+					return false
+				}
+			}
+		}
+		return true
+	}, 500*time.Millisecond, 50*time.Millisecond)
+
+	// トピックの作成と削除もRaftで複製され、トピックごとにオフセットが振られる
+	topic, err := logs[0].CreateTopic("orders", &api.TopicConfig{Compaction: true})
+	require.NoError(t, err)
+	require.Equal(t, "orders", topic.Name)
+	_, err = logs[0].CreateTopic("orders", nil)
+	require.IsType(t, api.ErrTopicExists{}, err)
+	orders := partitionLeader(t, logs, addrs, "orders", 0)
+	off, err := logs[orders].Append("orders", 0, &api.Record{Value: []byte("order")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), off)
+	require.Eventually(t, func() bool {
+		for j := 0; j < nodeCount; j++ {
+			got, err := logs[j].Read("orders", 0, off)
+			if err != nil || !reflect.DeepEqual(got.Value, []byte("order")) {
+				return false
+			}
+			topics, err := logs[j].ListTopics()
+			if err != nil || len(topics) != 2 || !topics[1].Config.Compaction {
+				return false
+			}
+		}
+		return true
+	}, 500*time.Millisecond, 50*time.Millisecond)
+	require.NoError(t, logs[0].DeleteTopic("orders"))
+	require.Eventually(t, func() bool {
+		for j := 0; j < nodeCount; j++ {
+			if _, err := logs[j].Read("orders", 0, off); !reflect.DeepEqual(api.ErrTopicNotFound{Topic: "orders"}, err) {
+				return false
+			}
+		}
+		return true
+	}, 500*time.Millisecond, 50*time.Millisecond)
+	_, err = logs[orders].Append("orders", 0, &api.Record{Value: []byte("order")})
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, err)
+	_, err = logs[0].CreateTopic("../orders", nil)
+	require.IsType(t, api.ErrInvalidTopic{}, err)
+
+	// リーダーはどの一貫性でも読み出せるが、フォロワーはANY以外ではリーダーを教えて断る
+	follower := (leader + 1) % nodeCount
+	ctx := context.Background()
+	for _, consistency := range []api.Consistency{
+		api.Consistency_ANY,
+		api.Consistency_LEADER_LEASE,
+		api.Consistency_LINEARIZABLE,
+	} {
+		require.NoError(t, logs[leader].VerifyRead(ctx, "", 0, consistency))
+	}
+	require.NoError(t, logs[follower].VerifyRead(ctx, "", 0, api.Consistency_ANY))
+	// 期限を過ぎていれば待たずに断る
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	err = logs[leader].VerifyRead(expired, "", 0, api.Consistency_LINEARIZABLE)
+	require.Equal(t, context.DeadlineExceeded, err)
+	for _, consistency := range []api.Consistency{
+		api.Consistency_LEADER_LEASE,
+		api.Consistency_LINEARIZABLE,
+	} {
+		err := logs[follower].VerifyRead(ctx, "", 0, consistency)
+		require.Equal(t, api.ErrNotLeader{LeaderAddr: addrs[leader]}, err)
+	}
+
 	servers, err := logs[0].GetServers()
 	require.NoError(t, err)
 	require.Equal(t, 3, len(servers))
@@ -108,17 +153,619 @@ func TestMultipleNodes(t *testing.T) {
 	require.True(t, servers[0].IsLeader)
 	require.False(t, servers[1].IsLeader)
 
-	time.Sleep(50 * time.Millisecond)
-	off, err := logs[0].Append(&api.Record{
+	// パーティションのグループからも取り除かれて、残ったノードからリーダーが選ばれる
+	remaining := []*log.DistributedLog{logs[0], logs[2]}
+	leader = partitionLeader(t, remaining, []string{addrs[0], addrs[2]}, log.DefaultTopic, 0)
+	time.Sleep(500 * time.Millisecond)
+	off, err = remaining[leader].Append("", 0, &api.Record{
 		Value: []byte("third"),
 	})
 	require.NoError(t, err)
 	time.Sleep(50 * time.Millisecond)
-	record, err := logs[1].Read(off)
+	record, err := logs[1].Read("", 0, off)
 	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
 	require.Nil(t, record)
-	record, err = logs[2].Read(off)
-	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		record, err = logs[2].Read("", 0, off)
+		return err == nil
+	}, 500*time.Millisecond, 50*time.Millisecond)
 	require.Equal(t, []byte("third"), record.Value)
 	require.Equal(t, off, record.Offset)
 }
+
+// パーティションごとのRaftのグループのリーダーがノードに分散し、それぞれのグループで複製されることをテストする。
+func TestPartitionedTopic(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	_, err := logs[0].CreateTopic("orders", &api.TopicConfig{Partitions: 6})
+	require.NoError(t, err)
+
+	// どのノードから見ても同じリーダーで、それぞれのノードが2つずつパーティションのリーダーになる
+	var partitions []*api.Partition
+	require.Eventually(t, func() bool {
+		leaders := make(map[string]int)
+		for _, l := range logs {
+			got, err := l.GetPartitions()
+			if err != nil || len(got) != 7 {
+				return false
+			}
+			if partitions != nil && !reflect.DeepEqual(partitions, got) {
+				partitions = nil
+				return false
+			}
+			partitions = got
+		}
+		for _, p := range partitions {
+			if p.Topic == "orders" {
+				leaders[p.LeaderAddr]++
+			}
+		}
+		for _, addr := range addrs {
+			if leaders[addr] != 2 {
+				partitions = nil
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+
+	for _, p := range partitions {
+		if p.Topic != "orders" {
+			continue
+		}
+		// 負荷が高いと選挙でリーダーが変わることがあるので、その時点のリーダーを調べ直す
+		var off uint64
+		require.Eventually(t, func() bool {
+			leader := partitionLeader(t, logs, addrs, "orders", p.Id)
+			follower := logs[(leader+1)%nodeCount]
+			// リーダーでないノードはリーダーを教えて断る
+			_, err := follower.Append("orders", p.Id, &api.Record{Value: []byte("order")})
+			if err != (api.ErrNotLeader{LeaderAddr: addrs[leader]}) {
+				return false
+			}
+			off, err = logs[leader].Append("orders", p.Id, &api.Record{
+				Value: []byte(fmt.Sprintf("order %d", p.Id)),
+			})
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		require.Equal(t, uint64(0), off)
+		require.Eventually(t, func() bool {
+			for _, l := range logs {
+				got, err := l.Read("orders", p.Id, off)
+				if err != nil || string(got.Value) != fmt.Sprintf("order %d", p.Id) {
+					return false
+				}
+			}
+			return true
+		}, time.Second, 50*time.Millisecond)
+	}
+	_, err = logs[0].Append("orders", 6, &api.Record{Value: []byte("order")})
+	require.Equal(t, api.ErrPartitionNotFound{Topic: "orders", Partition: 6}, err)
+
+	// トピックを削除すると、そのパーティションのグループもなくなる
+	require.NoError(t, logs[0].DeleteTopic("orders"))
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			partitions, err := l.GetPartitions()
+			if err != nil || len(partitions) != 1 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 50*time.Millisecond)
+}
+
+// コンシューマーグループのオフセットのコミットがパーティションのグループで複製されることをテストする。
+func TestCommittedOffsets(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+	follower := (leader + 1) % nodeCount
+	err := logs[follower].CommitOffset("", 0, "billing", 3)
+	require.IsType(t, api.ErrNotLeader{}, err)
+	require.Equal(t, api.ErrInvalidGroup{}, logs[leader].CommitOffset("", 0, "", 3))
+
+	require.NoError(t, logs[leader].CommitOffset("", 0, "billing", 3))
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			off, err := l.FetchCommittedOffset("", 0, "billing")
+			if err != nil || off != 3 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 50*time.Millisecond)
+	_, err = logs[leader].FetchCommittedOffset("", 0, "shipping")
+	require.Equal(t, api.ErrNoCommittedOffset{Group: "shipping", Topic: log.DefaultTopic}, err)
+}
+
+// プロデューサーのIDがクラスタで一意に払い出されることと、
+// 再送されたバッチをリーダーもフォロワーも重複して追加しないことをテストする。
+func TestIdempotentProducer(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	ids := make(map[uint64]bool)
+	for i := 0; i < 3; i++ {
+		// メタデータのリーダーでなければapi.ErrNotLeaderを返す
+		var id uint64
+		var err error
+		for _, l := range logs {
+			if id, err = l.InitProducer(); err == nil {
+				break
+			}
+			require.IsType(t, api.ErrNotLeader{}, err)
+		}
+		require.NoError(t, err)
+		ids[id] = true
+	}
+	require.Equal(t, 3, len(ids))
+
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+	records := []*api.Record{{Value: []byte("first")}, {Value: []byte("second")}}
+	offs, err := logs[leader].AppendIdempotent("", 0, 1, 0, records)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1}, offs)
+	// Applyがタイムアウトしたクライアントが再送した
+	retried, err := logs[leader].AppendIdempotent("", 0, 1, 0, records)
+	require.NoError(t, err)
+	require.Equal(t, offs, retried)
+	_, err = logs[leader].AppendIdempotent("", 0, 1, 5, records)
+	require.IsType(t, api.ErrOutOfOrderSequence{}, err)
+	offs, err = logs[leader].AppendIdempotent("", 0, 1, 2, []*api.Record{{Value: []byte("third")}})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2}, offs)
+
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			if _, err := l.Read("", 0, 2); err != nil {
+				return false
+			}
+			if _, err := l.Read("", 0, 3); err == nil {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 50*time.Millisecond)
+}
+
+// 後から参加したノードに、パーティションのスナップショットでセグメントのファイルを送って複製することをテストする。
+func TestPartitionSnapshotInstall(t *testing.T) {
+	nodeCount := 2
+	var logs []*log.DistributedLog
+	ports := dynaport.Get(nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		dataDir, err := os.MkdirTemp("", "distributed-log-snapshot-test")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = os.RemoveAll(dataDir)
+		})
+		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", ports[i]))
+		require.NoError(t, err)
+		config := log.Config{}
+		config.Segment.MaxIndexBytes = 1024
+		config.Raft.StreamLayer = log.NewStreamLayer(ln, nil, nil)
+		config.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
+		config.Raft.HeartbeatTimeout = 100 * time.Millisecond
+		config.Raft.ElectionTimeout = 100 * time.Millisecond
+		config.Raft.LeaderLeaseTimeout = 100 * time.Millisecond
+		config.Raft.CommitTimeout = 5 * time.Millisecond
+		config.Raft.ReconcileInterval = 50 * time.Millisecond
+		config.Retention.CheckInterval = 50 * time.Millisecond
+		config.Raft.BindAddr = ln.Addr().String()
+		// すぐにスナップショットを取って、古いRaftのログを削除する
+		config.Raft.SnapshotThreshold = 5
+		config.Raft.SnapshotInterval = 20 * time.Millisecond
+		config.Raft.TrailingLogs = 1
+		config.Raft.Bootstrap = i == 0
+		l, err := log.NewDistributedLog(dataDir, config)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = l.Close()
+		})
+		if i == 0 {
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+			partitionLeader(t, []*log.DistributedLog{l}, []string{ln.Addr().String()}, log.DefaultTopic, 0)
+			for j := 0; j < 20; j++ {
+				_, err = l.Append("", 0, &api.Record{Value: []byte("hello world")})
+				require.NoError(t, err)
+			}
+			require.NoError(t, l.CommitOffset("", 0, "billing", 15))
+			// スナップショットを取り終えるまで待つ
+			segments := filepath.Join(dataDir, "raft", "partitions", log.DefaultTopic, "0", "snapshot-segments")
+			require.Eventually(t, func() bool {
+				entries, err := os.ReadDir(segments)
+				return err == nil && len(entries) > 0
+			}, 3*time.Second, 20*time.Millisecond)
+		} else {
+			require.NoError(t, logs[0].Join(discovery.Member{
+				Name:    fmt.Sprintf("%d", i),
+				RPCAddr: ln.Addr().String(),
+				Role:    discovery.RoleVoter,
+			}))
+		}
+		logs = append(logs, l)
+	}
+
+	require.Eventually(t, func() bool {
+		for off := uint64(0); off < 20; off++ {
+			if _, err := logs[1].Read("", 0, off); err != nil {
+				return false
+			}
+		}
+		off, err := logs[1].FetchCommittedOffset("", 0, "billing")
+		return err == nil && off == 15
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// このノードがリーダーのグループのリーダーを、ほかのノードに移すことをテストする。
+func TestTransferLeadership(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+	require.NoError(t, logs[leader].TransferLeadership())
+	// 移し終えてから返るので、すぐにリーダーでなくなっている。
+	// 優先するノードなので、しばらくするとリーダーが戻される
+	_, err := logs[leader].Append("", 0, &api.Record{Value: []byte("hello world")})
+	require.IsType(t, api.ErrNotLeader{}, err)
+
+	// ほかに投票者がいなければ何もしない
+	single, _ := setupCluster(t, 1)
+	require.NoError(t, single[0].TransferLeadership())
+	_, err = single[0].Append("", 0, &api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+}
+
+// パーティションのリーダーにするノードが止まっていても、新しいリーダーがそのノードにリーダーを移そうとせずに、
+// 書き込みを受け付け続けることをテストする。
+func TestPreferredLeaderDown(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	// 優先するノードにリーダーが移されるのを待ってから止める
+	var leader int
+	require.Eventually(t, func() bool {
+		leader = partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+		time.Sleep(200 * time.Millisecond)
+		return partitionLeader(t, logs, addrs, log.DefaultTopic, 0) == leader
+	}, 5*time.Second, 50*time.Millisecond)
+	require.NoError(t, logs[leader].Close())
+	rest := append(append([]*log.DistributedLog{}, logs[:leader]...), logs[leader+1:]...)
+	restAddrs := append(append([]string{}, addrs[:leader]...), addrs[leader+1:]...)
+	next := partitionLeader(t, rest, restAddrs, log.DefaultTopic, 0)
+
+	// 何度か調整する間、書き込みを受け付け続ける
+	for i := 0; i < 10; i++ {
+		_, err := rest[next].Append("", 0, &api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// 止めようとしているノードからリーダーを移すと、優先するノードでもリーダーが戻されないことをテストする。
+func TestLeavingNode(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	var leader int
+	require.Eventually(t, func() bool {
+		leader = partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+		time.Sleep(200 * time.Millisecond)
+		return partitionLeader(t, logs, addrs, log.DefaultTopic, 0) == leader
+	}, 5*time.Second, 50*time.Millisecond)
+	for _, l := range logs {
+		require.NoError(t, l.Join(discovery.Member{
+			Name:    fmt.Sprintf("%d", leader),
+			RPCAddr: addrs[leader],
+			Role:    discovery.RoleVoter,
+			Leaving: true,
+		}))
+	}
+	require.NoError(t, logs[leader].TransferLeadership())
+	// 何度か調整しても戻されない
+	time.Sleep(500 * time.Millisecond)
+	require.NotEqual(t, leader, partitionLeader(t, logs, addrs, log.DefaultTopic, 0))
+}
+
+// 投票しないサーバとして参加したノードに、パーティションのグループでもレコードが複製されて、
+// リーダーにはならないことをテストする。
+func TestNonvoter(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+	nonvoter := discovery.Member{Name: "2", RPCAddr: addrs[2], Role: discovery.RoleNonvoter}
+	require.NoError(t, logs[0].Join(nonvoter))
+	servers, err := logs[0].GetServers()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(servers))
+	for i, server := range servers {
+		want := api.Suffrage_VOTER
+		if i == 2 {
+			want = api.Suffrage_NONVOTER
+		}
+		require.Equal(t, want, server.Suffrage)
+	}
+	// 同じ役割でもう一度参加しても変わらない
+	require.NoError(t, logs[0].Join(nonvoter))
+
+	// パーティションのグループでも投票権が外されて、リーダーは投票者から選ばれる
+	leader := -1
+	require.Eventually(t, func() bool {
+		leader = partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+		return leader != 2
+	}, 5*time.Second, 50*time.Millisecond)
+	off, err := logs[leader].Append("", 0, &api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		record, err := logs[2].Read("", 0, off)
+		return err == nil && reflect.DeepEqual([]byte("hello world"), record.Value)
+	}, 500*time.Millisecond, 50*time.Millisecond)
+
+	// 投票者として参加し直すと投票権が戻る
+	nonvoter.Role = discovery.RoleVoter
+	require.NoError(t, logs[0].Join(nonvoter))
+	servers, err = logs[0].GetServers()
+	require.NoError(t, err)
+	require.Equal(t, api.Suffrage_VOTER, servers[2].Suffrage)
+}
+
+// DescribeLogがパーティションのログとRaftのグループの状態を返すことをテストする。
+func TestDescribeLog(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+	off, err := logs[leader].Append("", 0, &api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+
+	nl, err := logs[leader].DescribeLog("", 0)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d", leader), nl.Id)
+	require.Equal(t, addrs[leader], nl.RpcAddr)
+	require.Equal(t, off, nl.HighestOffset)
+	require.Equal(t, off+1, nl.NextOffset)
+	require.NotEmpty(t, nl.Segments)
+	require.Equal(t, raft.Leader.String(), nl.Raft.State)
+	require.Equal(t, addrs[leader], nl.Raft.LeaderAddr)
+	require.NotZero(t, nl.Raft.Term)
+	require.LessOrEqual(t, nl.Raft.AppliedIndex, nl.Raft.CommitIndex)
+	require.LessOrEqual(t, nl.Raft.CommitIndex, nl.Raft.LastLogIndex)
+	require.Nil(t, nl.Raft.LastContact)
+
+	// フォロワーにもレコードが適用されて、リーダーと最後に通信した時刻を持つ
+	follower := (leader + 1) % nodeCount
+	require.Eventually(t, func() bool {
+		nl, err = logs[follower].DescribeLog("", 0)
+		require.NoError(t, err)
+		return nl.NextOffset == off+1 && nl.Raft.LastContact != nil
+	}, 500*time.Millisecond, 50*time.Millisecond)
+	require.Equal(t, raft.Follower.String(), nl.Raft.State)
+
+	_, err = logs[leader].DescribeLog("", 1)
+	require.IsType(t, api.ErrPartitionNotFound{}, err)
+}
+
+// 運用者の操作でノードを取り除いて追加し直せて、スナップショットとログの削除ができることをテストする。
+func TestAdmin(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+
+	// メンバーの変更はメタデータのグループのリーダーだけが受け付ける
+	err := logs[1].RemoveServer("2")
+	require.Equal(t, api.ErrNotLeader{LeaderAddr: addrs[0]}, err)
+	require.NoError(t, logs[0].RemoveServer("2"))
+	servers, err := logs[0].GetServers()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(servers))
+	require.NoError(t, logs[0].AddVoter("2", addrs[2]))
+	servers, err = logs[0].GetServers()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(servers))
+
+	leader := partitionLeader(t, logs, addrs, log.DefaultTopic, 0)
+	records := make([]*api.Record, 200)
+	for i := range records {
+		records[i] = &api.Record{Value: []byte("hello world")}
+	}
+	_, err = logs[leader].AppendBatch("", 0, records)
+	require.NoError(t, err)
+
+	index, term, err := logs[leader].TakeSnapshot("", 0)
+	require.NoError(t, err)
+	require.NotZero(t, index)
+	require.NotZero(t, term)
+	// 新しいエントリがなければ前のスナップショットを返す
+	again, _, err := logs[leader].TakeSnapshot("", 0)
+	require.NoError(t, err)
+	require.Equal(t, index, again)
+
+	// すべてのノードで削除される
+	follower := (leader + 1) % nodeCount
+	_, err = logs[follower].TruncateBefore("", 0, 150)
+	require.IsType(t, api.ErrNotLeader{}, err)
+	lowest, err := logs[leader].TruncateBefore("", 0, 150)
+	require.NoError(t, err)
+	require.NotZero(t, lowest)
+	require.LessOrEqual(t, lowest, uint64(150))
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			nl, err := l.DescribeLog("", 0)
+			if err != nil || nl.LowestOffset != lowest {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 50*time.Millisecond)
+}
+
+// 保持サイズを超えたセグメントが、リーダーが決めたオフセットまですべてのノードで削除されることをテストする。
+func TestRetention(t *testing.T) {
+	nodeCount := 3
+	logs, addrs := setupCluster(t, nodeCount)
+	_, err := logs[0].CreateTopic("events", &api.TopicConfig{RetentionMaxBytes: 1})
+	require.NoError(t, err)
+	leader := partitionLeader(t, logs, addrs, "events", 0)
+	records := make([]*api.Record, 200)
+	for i := range records {
+		records[i] = &api.Record{Value: []byte("hello world")}
+	}
+	offs, err := logs[leader].AppendBatch("events", 0, records)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		var lowest uint64
+		for i, l := range logs {
+			nl, err := l.DescribeLog("events", 0)
+			// アクティブセグメントだけが残る
+			if err != nil || len(nl.Segments) != 1 || nl.NextOffset != offs[len(offs)-1]+1 {
+				return false
+			}
+			if i > 0 && nl.LowestOffset != lowest {
+				return false
+			}
+			lowest = nl.LowestOffset
+		}
+		return lowest != 0
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+// グループのIDで、同じリスナーで受け付けた接続をグループごとに振り分けることをテストする。
+func TestStreamLayer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	root := log.NewStreamLayer(ln, nil, nil)
+	defer root.Close()
+	group, err := root.Group("orders/0")
+	require.NoError(t, err)
+	defer group.Close()
+	_, err = root.Group("orders/0")
+	require.Error(t, err)
+
+	for _, s := range []*log.StreamLayer{root, group} {
+		conn, err := s.Dial(raft.ServerAddress(ln.Addr().String()), time.Second)
+		require.NoError(t, err)
+		accepted, err := s.Accept()
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("hello"))
+		require.NoError(t, err)
+		b := make([]byte, 5)
+		_, err = io.ReadFull(accepted, b)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello"), b)
+		require.NoError(t, conn.Close())
+		require.NoError(t, accepted.Close())
+	}
+
+	// 接続先にないグループへの接続は閉じられる
+	otherLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	other := log.NewStreamLayer(otherLn, nil, nil)
+	defer other.Close()
+	missing, err := other.Group("missing/0")
+	require.NoError(t, err)
+	conn, err := missing.Dial(raft.ServerAddress(ln.Addr().String()), time.Second)
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1))
+	require.Equal(t, io.EOF, err)
+
+	// 閉じたグループは接続を受け付けない
+	require.NoError(t, group.Close())
+	_, err = group.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+// nodeCount個のノードのクラスタを作り、ノードとそのアドレスを返す。
+func setupCluster(t *testing.T, nodeCount int) ([]*log.DistributedLog, []string) {
+	t.Helper()
+	var logs []*log.DistributedLog
+	var addrs []string
+	ports := dynaport.Get(nodeCount) // 空いているportをとってくる
+	for i := 0; i < nodeCount; i++ {
+		dataDir, err := os.MkdirTemp("", "distributed-log-test")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = os.RemoveAll(dataDir)
+		})
+		ln, err := net.Listen(
+			"tcp",
+			fmt.Sprintf("127.0.0.1:%d", ports[i]),
+		)
+		require.NoError(t, err)
+		config := log.Config{}
+		config.Raft.StreamLayer = log.NewStreamLayer(ln, nil, nil)
+		config.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
+		// タイムアウト設定を短くして、Raftが素早くリーダを選出できるようにする。
+		config.Raft.HeartbeatTimeout = 100 * time.Millisecond
+		config.Raft.ElectionTimeout = 100 * time.Millisecond
+		config.Raft.LeaderLeaseTimeout = 100 * time.Millisecond
+		config.Raft.CommitTimeout = 5 * time.Millisecond
+		config.Raft.ReconcileInterval = 50 * time.Millisecond
+		config.Retention.CheckInterval = 50 * time.Millisecond
+		config.Raft.BindAddr = ln.Addr().String()
+
+		// 一つ目のサーバは、クラスタをブートストラップしてリーダーになり、残りの二つのサーバをク
+		// ラスタに追加しています。この後、リーダーは他のサーバをそのクラスタに参加させる必要があり
+		// ます。
+		if i == 0 {
+			config.Raft.Bootstrap = true
+		}
+		l, err := log.NewDistributedLog(dataDir, config)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = l.Close()
+		})
+		if i != 0 {
+			err = logs[0].Join(discovery.Member{
+				Name:    fmt.Sprintf("%d", i),
+				RPCAddr: ln.Addr().String(),
+				Role:    discovery.RoleVoter,
+			})
+			require.NoError(t, err)
+		} else {
+			err = l.WaitForLeader(3 * time.Second)
+			require.NoError(t, err)
+		}
+		logs = append(logs, l)
+		addrs = append(addrs, ln.Addr().String())
+	}
+	return logs, addrs
+}
+
+// すべてのノードがパーティションのリーダーとして同じノードを認識するまで待って、そのノードの位置を返す。
+func partitionLeader(
+	t *testing.T,
+	logs []*log.DistributedLog,
+	addrs []string,
+	topic string,
+	partition uint32,
+) int {
+	t.Helper()
+	leader := -1
+	require.Eventually(t, func() bool {
+		leader = -1
+		var leaderAddr string
+		for _, l := range logs {
+			partitions, err := l.GetPartitions()
+			if err != nil {
+				return false
+			}
+			var addr string
+			for _, p := range partitions {
+				if p.Topic == topic && p.Id == partition {
+					addr = p.LeaderAddr
+				}
+			}
+			if addr == "" || (leaderAddr != "" && addr != leaderAddr) {
+				return false
+			}
+			leaderAddr = addr
+		}
+		for i, addr := range addrs {
+			if addr == leaderAddr {
+				leader = i
+			}
+		}
+		return leader != -1
+	}, 5*time.Second, 50*time.Millisecond)
+	return leader
+}
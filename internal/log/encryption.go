@@ -0,0 +1,131 @@
+package log
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 暗号化したpayloadは次の形式になっている。鍵IDはGCMの追加認証データにも使う。
+//
+//	| key id (4) | nonce (12) | ciphertext + tag |
+const keyIDWidth = 4
+
+// 鍵で復号できなかったことを表す。鍵ファイルの鍵が間違っている場合に起きるので、
+// 起動時のリカバリでレコードを切り詰めてしまわないように、errCorruptFrameとは区別する。
+var errDecryptFrame = errors.New("store: failed to decrypt record frame")
+
+// 暗号化されたフレームを読み出したが鍵が設定されていないことを表す。
+var errNoKeyring = errors.New("store: record frame is encrypted but no encryption key is configured")
+
+// Keyring はストアのレコードを暗号化する鍵の集まり。
+// 新しく追加するレコードは現在の鍵で暗号化し、それ以外の鍵は既存のレコードの復号にだけ使う。
+type Keyring struct {
+	current uint32
+	aeads   map[uint32]cipher.AEAD
+}
+
+// NewKeyring はIDごとの鍵からKeyringを作る。鍵の長さは16、24、32バイト(AES-128、192、256)のいずれか。
+func NewKeyring(current uint32, keys map[uint32][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("log: current encryption key %d not found", current)
+	}
+	k := &Keyring{
+		current: current,
+		aeads:   make(map[uint32]cipher.AEAD, len(keys)),
+	}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("log: encryption key %d: %w", id, err)
+		}
+		if k.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// LoadKeyring は鍵ファイルからKeyringを作る。
+// 鍵ファイルは1行に1つ「<鍵ID> <16進数の鍵>」を並べたもので、最後の行の鍵を現在の鍵として使う。
+// 鍵をローテーションするときは、古い鍵を残したまま新しい鍵を末尾に追加する。空行と#で始まる行は無視する。
+func LoadKeyring(path string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys := make(map[uint32][]byte)
+	var current uint32
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("log: %s:%d: want \"<key id> <hex key>\"", path, line)
+		}
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("log: %s:%d: invalid key id: %w", path, line, err)
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("log: %s:%d: invalid key: %w", path, line, err)
+		}
+		if _, ok := keys[uint32(id)]; ok {
+			return nil, fmt.Errorf("log: %s:%d: duplicate key id %d", path, line, id)
+		}
+		keys[uint32(id)] = key
+		current = uint32(id)
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("log: %s: no encryption keys", path)
+	}
+	return NewKeyring(current, keys)
+}
+
+// pを現在の鍵で暗号化する。
+func (k *Keyring) seal(p []byte) ([]byte, error) {
+	aead := k.aeads[k.current]
+	out := make([]byte, keyIDWidth+aead.NonceSize(), keyIDWidth+aead.NonceSize()+len(p)+aead.Overhead())
+	enc.PutUint32(out[:keyIDWidth], k.current)
+	if _, err := io.ReadFull(rand.Reader, out[keyIDWidth:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[keyIDWidth:], p, out[:keyIDWidth]), nil
+}
+
+// sealで暗号化したpを、暗号化に使われた鍵で復号する。
+func (k *Keyring) open(p []byte) ([]byte, error) {
+	if len(p) < keyIDWidth {
+		return nil, errCorruptFrame
+	}
+	id := enc.Uint32(p[:keyIDWidth])
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("log: encryption key %d not found", id)
+	}
+	if len(p) < keyIDWidth+aead.NonceSize() {
+		return nil, errCorruptFrame
+	}
+	nonce := p[keyIDWidth : keyIDWidth+aead.NonceSize()]
+	out, err := aead.Open(nil, nonce, p[keyIDWidth+aead.NonceSize():], p[:keyIDWidth])
+	if err != nil {
+		return nil, errDecryptFrame
+	}
+	return out, nil
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKeyring(t *testing.T) {
+	for scenario, tc := range map[string]struct {
+		file    string
+		current uint32
+		wantErr bool
+	}{
+		"last key is current": {
+			file: "# retired\n" +
+				"1 000102030405060708090a0b0c0d0e0f\n" +
+				"\n" +
+				"2 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f\n",
+			current: 2,
+		},
+		"invalid key length": {
+			file:    "1 0001\n",
+			wantErr: true,
+		},
+		"invalid hex": {
+			file:    "1 zz\n",
+			wantErr: true,
+		},
+		"duplicate key id": {
+			file: "1 000102030405060708090a0b0c0d0e0f\n" +
+				"1 000102030405060708090a0b0c0d0e0f\n",
+			wantErr: true,
+		},
+		"no keys": {
+			file:    "# empty\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			f, err := os.CreateTemp("", "keyring_test")
+			require.NoError(t, err)
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(tc.file)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			k, err := LoadKeyring(f.Name())
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.current, k.current)
+			require.Equal(t, 2, len(k.aeads))
+		})
+	}
+}
+
+// 鍵をローテーションしても古い鍵で暗号化したレコードを読み出せることと、
+// 鍵がなければ読み出せないことをテストする。
+func TestStoreEncryption(t *testing.T) {
+	f, err := os.CreateTemp("", "store_encryption_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	key1 := []byte("0123456789abcdef")
+	key2 := []byte("fedcba9876543210fedcba9876543210")
+	k1, err := NewKeyring(1, map[uint32][]byte{1: key1})
+	require.NoError(t, err)
+	k2, err := NewKeyring(2, map[uint32][]byte{1: key1, 2: key2})
+	require.NoError(t, err)
+
+	c := Config{}
+	c.Segment.Keyring = k1
+	s, err := newStore(f, c)
+	require.NoError(t, err)
+	_, pos1, err := s.Append(write)
+	require.NoError(t, err)
+	require.NoError(t, s.buf.Flush())
+
+	c.Segment.Keyring = k2
+	c.Segment.Compression = CompressionGzip
+	s, err = newStore(f, c)
+	require.NoError(t, err)
+	_, pos2, err := s.Append(write)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// 平文はディスクに書き込まれない
+	b, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.NotContains(t, string(b), string(write))
+
+	f, err = os.OpenFile(f.Name(), os.O_RDWR, 0600)
+	require.NoError(t, err)
+	s, err = newStore(f, c)
+	require.NoError(t, err)
+	for _, pos := range []uint64{pos1, pos2} {
+		read, _, err := s.Read(pos)
+		require.NoError(t, err)
+		require.Equal(t, write, read)
+	}
+
+	// 新しい鍵しか持っていなければ古いレコードは読めない
+	c.Segment.Keyring, err = NewKeyring(2, map[uint32][]byte{2: key2})
+	require.NoError(t, err)
+	s, err = newStore(f, c)
+	require.NoError(t, err)
+	_, _, err = s.Read(pos1)
+	require.Error(t, err)
+	require.NotEqual(t, errCorruptFrame, err)
+
+	// 同じIDで違う鍵
+	c.Segment.Keyring, err = NewKeyring(1, map[uint32][]byte{1: key2[:16]})
+	require.NoError(t, err)
+	s, err = newStore(f, c)
+	require.NoError(t, err)
+	_, _, err = s.Read(pos1)
+	require.Equal(t, errDecryptFrame, err)
+
+	// 鍵がなければ読めない
+	s, err = newStore(f, Config{})
+	require.NoError(t, err)
+	_, _, err = s.Read(pos1)
+	require.Equal(t, errNoKeyring, err)
+	require.NoError(t, s.Close())
+}
@@ -3,6 +3,7 @@ package log
 import (
 	"io"
 	"os"
+	"sort"
 	"syscall"
 )
 
@@ -77,6 +78,25 @@ func (i *index) Read(in int64) (out uint32, pos uint64, err error) {
 	return out, pos, nil
 }
 
+// 相対オフセットoffのエントリが指すストアの位置を返す。エントリがなければio.EOFを返す。
+// 圧縮したセグメントはエントリが間引かれているので、in番目のエントリがinを持っていなければ二分探索する。
+func (i *index) Find(off uint32) (uint64, error) {
+	if out, pos, err := i.Read(int64(off)); err == nil && out == off {
+		return pos, nil
+	}
+	n := int(i.size / entWidth)
+	j := sort.Search(n, func(j int) bool {
+		out, _, _ := i.Read(int64(j))
+		return out >= off
+	})
+	if j < n {
+		if out, pos, _ := i.Read(int64(j)); out == off {
+			return pos, nil
+		}
+	}
+	return 0, io.EOF
+}
+
 // 与えられたオフセットと位置をインデックスに追加する。
 
 func (i *index) Write(off uint32, pos uint64) error {
@@ -89,6 +109,38 @@ func (i *index) Write(off uint32, pos uint64) error {
 	return nil
 }
 
+// インデックスを空にする。エントリを書き直す前に呼び出す。
+// スナップショットがハードリンクしているファイルを書き換えないように、新しいファイルに置き換える。
+func (i *index) Reset() error {
+	f, err := replaceFile(i.file, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(int64(len(i.mmap))); err != nil {
+		f.Close()
+		return err
+	}
+	m, err := syscall.Mmap(
+		int(f.Fd()),
+		0,
+		len(i.mmap),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err = syscall.Munmap(i.mmap); err != nil {
+		return err
+	}
+	if err = i.file.Close(); err != nil {
+		return err
+	}
+	i.file, i.mmap, i.size = f, m, 0
+	return nil
+}
+
 // エントリを1個足したら、超えてしまうかという判定
 func (i *index) isMaxed() bool {
 	return uint64(len(i.mmap)) < i.size+entWidth
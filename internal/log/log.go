@@ -1,15 +1,22 @@
 package log
 
 import (
+	"context"
+	"errors"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	api "github.com/yurakawa/proglog/api/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Log struct {
@@ -22,8 +29,28 @@ type Log struct {
 	activeSegment *segment
 	// セグメントの集まり
 	segments []*segment
+
+	// SyncIntervalのとき、最後のfsyncから追加されたバイト数。
+	// Config.Segment.Sync.Bytesを超えたらsynccでバックグラウンドのゴルーチンにfsyncを依頼する。
+	unsynced uint64
+	syncc    chan struct{}
+
+	// レコードを追加するたびに閉じて作り直すチャネル。WaitForOffsetで追加を待つゴルーチンを起こす。
+	// ログを閉じたときにも閉じて、閉じたログで待っているゴルーチンを起こす。
+	appended chan struct{}
+	closed   bool
+
+	// バックグラウンドで動くゴルーチンを止めるためのチャネル
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	logger *zap.Logger
 }
 
+// 閉じたログに追加しようとしたときと、追加を待っている間にログが閉じられたときに返す。
+// パーティションのログはスナップショットからの復元で置き換えられると閉じられる。
+var errLogClosed = errors.New("log: closed")
+
 func NewLog(dir string, c Config) (*Log, error) {
 	// デフォルト値の設定
 	if c.Segment.MaxStoreBytes == 0 {
@@ -32,10 +59,28 @@ func NewLog(dir string, c Config) (*Log, error) {
 	if c.Segment.MaxIndexBytes == 0 {
 		c.Segment.MaxIndexBytes = 1024
 	}
+	if c.Segment.TimeIndexIntervalBytes == 0 {
+		c.Segment.TimeIndexIntervalBytes = 4096
+	}
+	if c.Segment.Sync.Mode == SyncInterval && c.Segment.Sync.Interval == 0 {
+		c.Segment.Sync.Interval = time.Second
+	}
+	if c.Retention.CheckInterval == 0 {
+		c.Retention.CheckInterval = time.Minute
+	}
+	if c.Compaction.TombstoneRetention == 0 {
+		c.Compaction.TombstoneRetention = 24 * time.Hour
+	}
+	if c.Compaction.CheckInterval == 0 {
+		c.Compaction.CheckInterval = time.Minute
+	}
 	// Logのインスタンスを作成して、 出力dirとコンフィグを設定する
 	l := &Log{
-		Dir:    dir,
-		Config: c,
+		Dir:      dir,
+		Config:   c,
+		syncc:    make(chan struct{}, 1),
+		appended: make(chan struct{}),
+		logger:   zap.L().Named("log"),
 	}
 	return l, l.setup()
 }
@@ -46,9 +91,17 @@ func (l *Log) setup() error {
 	if err != nil {
 		return err
 	}
+	// 圧縮の途中でクラッシュした場合、書きかけのセグメントが残っているので捨てる
+	if err = os.RemoveAll(filepath.Join(l.Dir, compactionDir)); err != nil {
+		return err
+	}
 	// ファイル名からベースオフセットの値を取得。
 	var baseOffsets []uint64
 	for _, file := range files {
+		// セグメントごとにストア、インデックス、タイムインデックスのファイルがあるので、ストアのファイルだけを見る
+		if path.Ext(file.Name()) != ".store" {
+			continue
+		}
 		offStr := strings.TrimSuffix(
 			file.Name(),
 			path.Ext(file.Name()),
@@ -63,11 +116,31 @@ func (l *Log) setup() error {
 	})
 	// 既存のセグメントがない場合、下の処理はスキップされる。
 	for i := 0; i < len(baseOffsets); i++ {
+		migrated, err := migrateLegacySegment(l.Dir, baseOffsets[i], l.Config)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			l.logger.Warn(
+				"migrated segment without checksums",
+				zap.String("dir", l.Dir),
+				zap.Uint64("base_offset", baseOffsets[i]),
+			)
+		}
 		if err = l.newSegment(baseOffsets[i]); err != nil {
 			return err
 		}
-		// baseOffsetsはインデックスとストアの２つの重複を含んでいるので重複しているものをスキップする
-		i++
+	}
+	// クラッシュした場合、インデックスがストアの末尾を超えたエントリを指していたり、
+	// インデックスエントリのない書き込み途中のレコードがストアに残っていたりする。
+	// アクティブセグメントは毎回、それ以外のセグメントは整合性が取れていない場合だけ、ストアを読み直して修復する。
+	for i, s := range l.segments {
+		if i != len(l.segments)-1 && s.isConsistent() {
+			continue
+		}
+		if err = l.recover(s); err != nil {
+			return err
+		}
 	}
 	// 既存のセグメントがない場合、newSegmentヘルパーメソッドを使って渡されたベースオフセット(InitialOffset)で最初のセグメントを作成する。
 	if l.segments == nil {
@@ -75,35 +148,144 @@ func (l *Log) setup() error {
 			return err
 		}
 	}
+	l.start()
 	return nil
 }
 
 // ログにレコードを追加する。
-// TODO: ログ全体でなくセグメントごとにロックを獲得する
+// Config.Segment.Sync.Modeで指定された永続性のレベルを満たしてから返る。
+// レコードにタイムスタンプがなければ追加した時刻を設定する。
 func (l *Log) Append(record *api.Record) (uint64, error) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	highestOffset, err := l.highestOffset()
+	offs, err := l.AppendBatch([]*api.Record{record})
 	if err != nil {
 		return 0, err
 	}
+	return offs[0], nil
+}
+
+// 複数のレコードをまとめてログに追加して、それぞれのオフセットを返す。
+// 追加している間は他のゴルーチンの追加を待たせるので、レコードは連続したオフセットに並ぶ。
+// 途中で失敗したときは1件も追加しないので、そのまま送り直しても重複しない。
+// SyncAlwaysでも最後にまとめて1回fsyncする。
+func (l *Log) AppendBatch(records []*api.Record) ([]uint64, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	offs, s, end, err := l.append(records)
+	if err != nil {
+		return nil, err
+	}
+	// ロックを外してからfsyncを待つので、待っている間に他のゴルーチンが追加したレコードも
+	// 同じfsyncでまとめてコミットされる(グループコミット)。
+	if l.Config.Segment.Sync.Mode == SyncAlways {
+		if err = s.store.Sync(end); err != nil {
+			return nil, err
+		}
+	}
+	return offs, nil
+}
+
+// アクティブセグメントにレコードを追加して、最後に追加したセグメントと追加後のストアのサイズを返す。
+// レコードはセグメントに収まる分ずつ1つのフレームにまとめて書き込むので、バッチ全体をまとめて圧縮できる。
+// 途中のレコードで失敗したら、それまでに追加したレコードも取り除いてから返すので、バッチは全部追加されるか何も追加されない。
+// TODO: ログ全体でなくセグメントごとにロックを獲得する
+func (l *Log) append(records []*api.Record) (offs []uint64, s *segment, end uint64, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, 0, errLogClosed
+	}
+
+	segments, mark := len(l.segments), l.activeSegment.mark()
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rerr := l.rollback(segments, mark); rerr != nil {
+			err = rerr
+		}
+	}()
+
+	var written uint64
+	offs = make([]uint64, 0, len(records))
+	for len(records) > 0 {
+		// アクティブセグメントが最大サイズ以上のときは、新しいセグメントを作成する。
+		if l.activeSegment.IsMaxed() {
+			if err = l.rotate(); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+		s = l.activeSegment
+		batch := records
+		if n := s.room(); n < len(batch) {
+			batch = batch[:n]
+		}
+		records = records[len(batch):]
 
-	// アクティブセグメントが最大サイズ以上のときは、新しいセグメントを作成する。
-	if l.activeSegment.IsMaxed() {
-		err = l.newSegment(highestOffset + 1)
+		// ロックを取ってから時刻を取るので、ログ内ではオフセットの順にタイムスタンプが並ぶ
+		now := timestamppb.Now()
+		for _, record := range batch {
+			if record.Timestamp == nil {
+				record.Timestamp = now
+			}
+		}
+
+		// アクティブセグメントにレコードを追加する。
+		begin := s.store.Size()
+		o, err := s.AppendBatch(batch)
 		if err != nil {
-			return 0, err
+			return nil, nil, 0, err
 		}
+		end = s.store.Size()
+		written += end - begin
+		offs = append(offs, o...)
 	}
 
-	// アクティブセグメントにレコードを追加する。
-	off, err := l.activeSegment.Append(record)
-	if err != nil {
-		return 0, err
+	// 追加を待っているゴルーチンをすべて起こす
+	close(l.appended)
+	l.appended = make(chan struct{})
+
+	if l.Config.Segment.Sync.Mode == SyncInterval && l.Config.Segment.Sync.Bytes > 0 {
+		if atomic.AddUint64(&l.unsynced, written) >= l.Config.Segment.Sync.Bytes {
+			select {
+			case l.syncc <- struct{}{}:
+			default:
+				// すでに依頼済み
+			}
+		}
+	}
+	return offs, s, end, nil
+}
+
+// 追加を始めたときのセグメントの数とアクティブセグメントの状態に戻す。追加の途中で作ったセグメントは削除する。
+func (l *Log) rollback(segments int, mark segmentMark) error {
+	for len(l.segments) > segments {
+		if err := l.segments[len(l.segments)-1].Remove(); err != nil {
+			return err
+		}
+		l.segments = l.segments[:len(l.segments)-1]
 	}
+	l.activeSegment = l.segments[len(l.segments)-1]
+	return l.activeSegment.rollback(mark)
+}
 
-	return off, err
+// アクティブセグメントを閉じて、次のオフセットから始まる新しいセグメントをアクティブにする。
+func (l *Log) rotate() error {
+	highestOffset, err := l.highestOffset()
+	if err != nil {
+		return err
+	}
+	if err = l.activeSegment.flushTimeIndex(); err != nil {
+		return err
+	}
+	// アクティブでなくなるセグメントはバックグラウンドのfsyncの対象から外れるので、ここで永続化しておく。
+	// SyncAlwaysでも、まとめて追加している途中なら最後のfsyncの対象から外れる。
+	if l.Config.Segment.Sync.Mode != SyncNever {
+		if err = l.activeSegment.store.Sync(l.activeSegment.store.Size()); err != nil {
+			return err
+		}
+	}
+	return l.newSegment(highestOffset + 1)
 }
 
 // 指定されたオフセットに保存されているレコードを読み出す。
@@ -123,6 +305,10 @@ func (l *Log) Read(off uint64) (*api.Record, error) {
 	}
 	// || s.nextOffset <= offはいらなそう
 	if s == nil || s.nextOffset <= off {
+		// 圧縮でセグメントの末尾のレコードが削除されていると、どのセグメントにも含まれないオフセットがある
+		if l.segments[0].baseOffset <= off && off < l.activeSegment.nextOffset {
+			return nil, api.ErrCompacted{Offset: off}
+		}
 		return nil, api.ErrOffsetOutOfRange{Offset: off}
 	}
 	// レコードを含むセグメントセグメントを見つけたら、そのセグメントのインデックスからインデックスエントリを取得して
@@ -132,9 +318,16 @@ func (l *Log) Read(off uint64) (*api.Record, error) {
 
 // セグメントをすべてクローズする
 func (l *Log) Close() error {
+	// バックグラウンドのゴルーチンはセグメントを触るので先に止める
+	l.stop()
 	// read/writeロックを取得する
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	// 追加を待っているゴルーチンを起こす
+	if !l.closed {
+		l.closed = true
+		close(l.appended)
+	}
 	for _, segment := range l.segments {
 		if err := segment.Close(); err != nil {
 			return err
@@ -156,6 +349,12 @@ func (l *Log) Reset() error {
 	if err := l.Remove(); err != nil {
 		return err
 	}
+	// Removeでディレクトリごと削除しているので作り直す
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return err
+	}
+	l.segments, l.activeSegment = nil, nil
+	l.closed, l.appended = false, make(chan struct{})
 	return l.setup()
 }
 
@@ -175,6 +374,83 @@ func (l *Log) HighestOffset() (uint64, error) {
 	return l.highestOffset()
 }
 
+// オフセットoffのレコードが追加されるまで待つ。すでに追加されていればすぐに返る。
+// ctxがキャンセルされたらctx.Err()を、ログが閉じられたらerrLogClosedを返す。
+func (l *Log) WaitForOffset(ctx context.Context, off uint64) error {
+	for {
+		l.mu.RLock()
+		next := l.activeSegment.nextOffset
+		appended, closed := l.appended, l.closed
+		l.mu.RUnlock()
+		if closed {
+			return errLogClosed
+		}
+		if off < next {
+			return nil
+		}
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// タイムスタンプがt以降の最初のレコードのオフセットを返す。
+// そのようなレコードがまだなければ、次に追加されるレコードのオフセットを返す。
+func (l *Log) OffsetForTime(t time.Time) (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	ts := t.UnixNano()
+	for _, s := range l.segments {
+		off, ok, err := s.OffsetForTime(ts)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return off, nil
+		}
+	}
+	return l.segments[len(l.segments)-1].nextOffset, nil
+}
+
+// OffsetRange はログに残っている最も古いオフセットと、次に追加されるレコードのオフセットを返す。
+// 同じロックの中で取得するので、2つのオフセットの間のレコードは読み出せる。
+func (l *Log) OffsetRange() (lowest, next uint64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.segments[0].baseOffset, l.activeSegment.nextOffset
+}
+
+// Describe はログのオフセットの範囲と、セグメントごとのオフセットとファイルのサイズを返す。
+// ストアのサイズにはまだファイルに書き出していないバッファの分も含む。
+func (l *Log) Describe() *api.NodeLog {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	highest, _ := l.highestOffset()
+	nl := &api.NodeLog{
+		LowestOffset:  l.segments[0].baseOffset,
+		HighestOffset: highest,
+		NextOffset:    l.activeSegment.nextOffset,
+	}
+	for _, s := range l.segments {
+		nl.Segments = append(nl.Segments, &api.SegmentInfo{
+			BaseOffset: s.baseOffset,
+			NextOffset: s.nextOffset,
+			StoreBytes: s.store.Size(),
+			IndexBytes: s.index.size,
+		})
+	}
+	return nl
+}
+
+// 次に追加されるレコードのオフセットを返す。
+func (l *Log) nextOffset() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.activeSegment.nextOffset
+}
+
 func (l *Log) highestOffset() (uint64, error) {
 	off := l.segments[len(l.segments)-1].nextOffset
 	if off == 0 {
@@ -203,6 +479,97 @@ func (l *Log) Truncate(lowest uint64) error {
 	return nil
 }
 
+// TruncateBefore はオフセットoffより前のレコードだけを持つセグメントを古い順に削除して、残っている最も古いオフセットを返す。
+// Truncateと違ってアクティブセグメントは削除しないので、offが次に追加されるオフセットより後でもログは空にならない。
+func (l *Log) TruncateBefore(off uint64) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.segments) > 1 && l.segments[0].nextOffset <= off {
+		s := l.segments[0]
+		if err := s.Remove(); err != nil {
+			return 0, err
+		}
+		l.segments = l.segments[1:]
+		l.logger.Info(
+			"removed segment by truncation",
+			zap.String("dir", l.Dir),
+			zap.Uint64("base_offset", s.baseOffset),
+			zap.Uint64("next_offset", s.nextOffset),
+		)
+	}
+	return l.segments[0].baseOffset, nil
+}
+
+// オフセットoff以降のレコードをすべて削除して、次にoffのレコードを追加するようにする。
+// offが最小のオフセット以下なら、ログを空にしてoffから始める。
+// 切り詰めたストアはその場で書き換えるので、リンクしたスナップショットを取るパーティションのログには使わない。
+func (l *Log) truncateFrom(off uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if off <= l.segments[0].baseOffset {
+		return l.resetSegments(off)
+	}
+	for len(l.segments) > 1 && l.segments[len(l.segments)-1].baseOffset >= off {
+		if err := l.segments[len(l.segments)-1].Remove(); err != nil {
+			return err
+		}
+		l.segments = l.segments[:len(l.segments)-1]
+	}
+	s := l.segments[len(l.segments)-1]
+	l.activeSegment = s
+	if off >= s.nextOffset {
+		return nil
+	}
+	_, pos, err := s.index.Read(int64(off - s.baseOffset))
+	if err != nil {
+		return err
+	}
+	// offがバッチの途中なら、フレームごと切り詰めてからoffより前のレコードを書き直す
+	p, attrs, err := s.store.Read(pos)
+	if err != nil {
+		return err
+	}
+	records, err := decodeRecords(p, attrs)
+	if err != nil {
+		return err
+	}
+	var keep []*api.Record
+	for _, record := range records {
+		if record.Offset < off {
+			keep = append(keep, record)
+		}
+	}
+	if err = s.store.Truncate(pos); err != nil {
+		return err
+	}
+	// インデックスとタイムインデックスを切り詰めたストアから作り直す
+	if _, err = s.recover(); err != nil {
+		return err
+	}
+	if len(keep) > 0 {
+		_, err = s.AppendBatch(keep)
+	}
+	return err
+}
+
+// ログを空にして、offから始める。
+func (l *Log) resetAt(off uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.resetSegments(off)
+}
+
+// すべてのセグメントを削除して、offから始まる空のセグメントを作る。l.muを取得してから呼び出す。
+func (l *Log) resetSegments(off uint64) error {
+	for _, s := range l.segments {
+		if err := s.Remove(); err != nil {
+			return err
+		}
+	}
+	l.segments = nil
+	return l.newSegment(off)
+}
+
 // ログ全体を読み込むためのio.Readerを返す。
 // 合意形成の連携を実装し、スナップショットをサポートし、ログの復旧をサポートする必要がある場合、
 // この機能が必要になる。
@@ -234,6 +601,136 @@ func (o *originReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// バックグラウンドで動くゴルーチンを開始する。Closeで停止する。
+func (l *Log) start() {
+	l.done = make(chan struct{})
+	if l.Config.Segment.Sync.Mode == SyncInterval {
+		l.wg.Add(1)
+		go l.syncLoop(l.done)
+	}
+	if (l.Config.Retention.MaxAge > 0 || l.Config.Retention.MaxBytes > 0) && !l.Config.Retention.Replicated {
+		l.wg.Add(1)
+		go l.retentionLoop(l.done)
+	}
+	if l.Config.Compaction.Enabled {
+		l.wg.Add(1)
+		go l.compactionLoop(l.done)
+	}
+}
+
+// バックグラウンドで動くゴルーチンを停止して、終了するのを待つ。
+func (l *Log) stop() {
+	if l.done == nil {
+		return
+	}
+	close(l.done)
+	l.wg.Wait()
+	l.done = nil
+}
+
+// SyncIntervalのとき、Intervalごとか、Bytes分書き込まれるたびにアクティブセグメントをfsyncする。
+func (l *Log) syncLoop(done <-chan struct{}) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.Config.Segment.Sync.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		case <-l.syncc:
+		}
+		atomic.StoreUint64(&l.unsynced, 0)
+		l.mu.RLock()
+		s := l.activeSegment
+		l.mu.RUnlock()
+		// fsyncの間はロックを保持しないので、その間も追加を続けられる
+		if err := s.store.Sync(s.store.Size()); err != nil {
+			l.logger.Error(
+				"failed to sync segment",
+				zap.String("dir", l.Dir),
+				zap.Uint64("base_offset", s.baseOffset),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// CheckIntervalごとに保持期間と保持サイズを超えたセグメントを削除する。
+func (l *Log) retentionLoop(done <-chan struct{}) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.Config.Retention.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := l.enforceRetention(); err != nil {
+			l.logger.Error(
+				"failed to enforce retention",
+				zap.String("dir", l.Dir),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// 保持期間か保持サイズを超えたセグメントを古い順に削除する。アクティブセグメントは削除しない。
+// 書き込みロックを取ってから削除するので、読み出し中のセグメントが削除されることはない。
+func (l *Log) enforceRetention() error {
+	_, err := l.TruncateBefore(l.RetentionOffset())
+	return err
+}
+
+// RetentionOffset は保持期間か保持サイズを超えたセグメントを古い順に数えて、残す最初のセグメントのベースオフセットを返す。
+// セグメントの古さは、ファイルの更新時刻ではなくセグメントで最も新しいレコードのタイムスタンプで判断する。
+// タイムスタンプは複製されたレコードに入っているので、同じレコードを持つレプリカでは同じ結果になる。
+// タイムスタンプのないレコードだけのセグメントは、保持期間では削除しない。アクティブセグメントは削除しない。
+func (l *Log) RetentionOffset() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var size uint64
+	for _, s := range l.segments {
+		size += s.store.Size()
+	}
+	now := time.Now()
+	for _, s := range l.segments[:len(l.segments)-1] {
+		expired := l.Config.Retention.MaxBytes > 0 && size > l.Config.Retention.MaxBytes
+		if !expired && l.Config.Retention.MaxAge > 0 && s.maxTimestamp != 0 {
+			expired = now.Sub(time.Unix(0, s.maxTimestamp)) > l.Config.Retention.MaxAge
+		}
+		if !expired {
+			// 新しいセグメントほど後ろに並んでいるので、これ以降のセグメントは削除しない
+			return s.baseOffset
+		}
+		size -= s.store.Size()
+	}
+	return l.activeSegment.baseOffset
+}
+
+// セグメントを修復し、修復した内容をログに記録する。
+func (l *Log) recover(s *segment) error {
+	indexSize, nextOffset := s.index.size, s.nextOffset
+	truncated, err := s.recover()
+	if err != nil {
+		return err
+	}
+	if truncated > 0 || s.index.size != indexSize || s.nextOffset != nextOffset {
+		l.logger.Warn(
+			"repaired segment",
+			zap.String("dir", l.Dir),
+			zap.Uint64("base_offset", s.baseOffset),
+			zap.Uint64("truncated_bytes", truncated),
+			zap.Uint64("index_entries_before", indexSize/entWidth),
+			zap.Uint64("index_entries_after", s.index.size/entWidth),
+			zap.Uint64("next_offset", s.nextOffset),
+		)
+	}
+	return nil
+}
+
 // 新しいセグメントを作成して、ログのセグメントのスライスに追加する
 func (l *Log) newSegment(off uint64) error {
 	s, err := newSegment(l.Dir, off, l.Config)
@@ -1,13 +1,23 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestLog(t *testing.T) {
@@ -17,6 +27,13 @@ func TestLog(t *testing.T) {
 		"init with existing segments":       testInitExisting,
 		"reader":                            testReader,
 		"truncate":                          testTruncate,
+		"corrupt record returns data loss":  testCorruptRecord,
+		"offset for time":                   testOffsetForTime,
+		"append batch":                      testAppendBatch,
+		"failed append batch rolls back":    testAppendBatchRollback,
+		"wait for offset":                   testWaitForOffset,
+		"describe":                          testDescribe,
+		"truncate before":                   testTruncateBefore,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			dir, err := os.MkdirTemp("", "store-test")
@@ -108,7 +125,7 @@ func testReader(t *testing.T, log *Log) {
 	require.NoError(t, err)
 
 	read := &api.Record{}
-	err = proto.Unmarshal(b[lenWidth:], read)
+	err = proto.Unmarshal(b[headerWidth:], read)
 	require.NoError(t, err)
 	require.Equal(t, append.Value, read.Value)
 	require.NoError(t, log.Close())
@@ -124,12 +141,766 @@ func testTruncate(t *testing.T, log *Log) {
 		require.NoError(t, err)
 	}
 
+	lowest, next := log.OffsetRange()
+	require.Equal(t, uint64(0), lowest)
+	require.Equal(t, uint64(3), next)
+
 	// 0を消せ！1は残る
 	err := log.Truncate(1)
 	require.NoError(t, err)
+	// セグメントごと削除するので、1を含む最初のセグメントも消える
+	lowest, next = log.OffsetRange()
+	require.Equal(t, uint64(2), lowest)
+	require.Equal(t, uint64(3), next)
 
 	// 消したやつがout of range errorを返す。
 	_, err = log.Read(0)
 	require.Error(t, err)
 	require.NoError(t, log.Close())
 }
+
+// Describeがセグメントごとのオフセットの範囲とファイルのサイズを返すことをテストする。
+func testDescribe(t *testing.T, log *Log) {
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	nl := log.Describe()
+	require.Equal(t, uint64(0), nl.LowestOffset)
+	require.Equal(t, uint64(2), nl.HighestOffset)
+	require.Equal(t, uint64(3), nl.NextOffset)
+	// インデックスには2つのエントリしか入らない
+	require.Equal(t, 2, len(nl.Segments))
+	require.Equal(t, uint64(0), nl.Segments[0].BaseOffset)
+	require.Equal(t, uint64(2), nl.Segments[0].NextOffset)
+	require.Equal(t, uint64(2*entWidth), nl.Segments[0].IndexBytes)
+	require.Equal(t, uint64(2), nl.Segments[1].BaseOffset)
+	require.Equal(t, uint64(3), nl.Segments[1].NextOffset)
+	require.Equal(t, uint64(entWidth), nl.Segments[1].IndexBytes)
+	require.Less(t, nl.Segments[1].StoreBytes, nl.Segments[0].StoreBytes)
+	require.NotZero(t, nl.Segments[1].StoreBytes)
+	require.NoError(t, log.Close())
+}
+
+// TruncateBeforeがoffより前のレコードだけを持つセグメントを削除して、アクティブセグメントは残すことをテストする。
+func testTruncateBefore(t *testing.T, log *Log) {
+	for i := 0; i < 5; i++ {
+		_, err := log.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	// セグメントは[0, 2), [2, 4), [4, 5)。3を含むセグメントは残る
+	lowest, err := log.TruncateBefore(3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), lowest)
+	_, err = log.Read(1)
+	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+
+	// 次に追加されるオフセットより後を指定しても、アクティブセグメントは削除しない
+	lowest, err = log.TruncateBefore(100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), lowest)
+	read, err := log.Read(4)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), read.Value)
+	off, err := log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), off)
+	require.NoError(t, log.Close())
+}
+
+// タイムスタンプからオフセットを探せて、ログを開き直しても同じ結果になることをテストする。
+func testOffsetForTime(t *testing.T, log *Log) {
+	base := time.Date(2022, 11, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, err := log.Append(&api.Record{
+			Value:     []byte("hello world"),
+			Timestamp: timestamppb.New(base.Add(time.Duration(i) * time.Second)),
+		})
+		require.NoError(t, err)
+	}
+	// 複数のセグメントにまたがっている
+	require.True(t, len(log.segments) > 1)
+
+	check := func(log *Log) {
+		for _, tc := range []struct {
+			t    time.Time
+			want uint64
+		}{
+			{t: base.Add(-time.Hour), want: 0},
+			{t: base, want: 0},
+			{t: base.Add(1500 * time.Millisecond), want: 2},
+			{t: base.Add(4 * time.Second), want: 4},
+			// まだ追加されていなければ次に追加されるオフセット
+			{t: base.Add(time.Hour), want: 5},
+		} {
+			off, err := log.OffsetForTime(tc.t)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, off, "t=%s", tc.t)
+		}
+	}
+	check(log)
+	require.NoError(t, log.Close())
+
+	n, err := NewLog(log.Dir, log.Config)
+	require.NoError(t, err)
+	check(n)
+	require.NoError(t, n.Close())
+}
+
+// レコードが追加されるまで待てることと、キャンセルできることをテストする。
+func testWaitForOffset(t *testing.T, log *Log) {
+	ctx := context.Background()
+	_, err := log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	// 追加済みならすぐに返る
+	require.NoError(t, log.WaitForOffset(ctx, 0))
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- log.WaitForOffset(ctx, 2)
+	}()
+	_, err = log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	select {
+	case err := <-errc:
+		t.Fatalf("returned before offset 2 was appended: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	_, err = log.Append(&api.Record{Value: []byte("hello world")})
+	require.NoError(t, err)
+	select {
+	case err := <-errc:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("not woken up by append")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, log.WaitForOffset(ctx, 3))
+	require.NoError(t, log.Close())
+}
+
+// まとめて追加したレコードがセグメントをまたいでも連続したオフセットに並ぶことをテストする。
+func testAppendBatch(t *testing.T, log *Log) {
+	var records []*api.Record
+	for i := 0; i < 5; i++ {
+		records = append(records, &api.Record{Value: []byte(fmt.Sprintf("message %d", i))})
+	}
+	offs, err := log.AppendBatch(records)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3, 4}, offs)
+	require.Equal(t, 3, len(log.segments))
+	for i, off := range offs {
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, records[i].Value, read.Value)
+	}
+
+	offs, err = log.AppendBatch(nil)
+	require.NoError(t, err)
+	require.Empty(t, offs)
+	require.NoError(t, log.Close())
+}
+
+// バッチの途中のレコードで失敗すると、それまでに追加したレコードも取り除かれることをテストする。
+func testAppendBatchRollback(t *testing.T, log *Log) {
+	first := &api.Record{Value: []byte("first")}
+	_, err := log.Append(first)
+	require.NoError(t, err)
+	// オフセット4から始まるセグメントのストアを作れないようにする
+	blocker := filepath.Join(log.Dir, "4.store")
+	require.NoError(t, os.Mkdir(blocker, 0755))
+	var records []*api.Record
+	for i := 0; i < 4; i++ {
+		records = append(records, &api.Record{Value: []byte(fmt.Sprintf("message %d", i))})
+	}
+	// オフセット1から3までは追加でき、途中でオフセット2から始まるセグメントも作られる
+	_, err = log.AppendBatch(records)
+	require.Error(t, err)
+	require.Equal(t, 1, len(log.segments))
+	_, err = os.Stat(filepath.Join(log.Dir, "2.store"))
+	require.True(t, os.IsNotExist(err))
+	_, err = log.Read(1)
+	require.Equal(t, api.ErrOffsetOutOfRange{Offset: 1}, err)
+
+	// やり直すと同じオフセットに追加される
+	require.NoError(t, os.Remove(blocker))
+	offs, err := log.AppendBatch(records)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 4}, offs)
+	require.NoError(t, log.Close())
+
+	// 開き直しても、取り除いたレコードの残りは見つからない
+	log, err = NewLog(log.Dir, log.Config)
+	require.NoError(t, err)
+	defer log.Close()
+	for i, off := range append([]uint64{0}, offs...) {
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		want := first.Value
+		if i > 0 {
+			want = records[i-1].Value
+		}
+		require.Equal(t, want, read.Value)
+	}
+	require.Equal(t, uint64(5), log.activeSegment.nextOffset)
+}
+
+// ストアのバイトが化けた場合、壊れたレコードを返さずにDataLossとして扱われるエラーを返すことをテストする。
+func testCorruptRecord(t *testing.T, log *Log) {
+	append := &api.Record{
+		Value: []byte("hello world"),
+	}
+	off, err := log.Append(append)
+	require.NoError(t, err)
+	// ストアのバッファをフラッシュさせる
+	_, err = log.Read(off)
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(filepath.Join(log.Dir, "0.store"), os.O_RDWR, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, headerWidth+1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	read, err := log.Read(off)
+	require.Nil(t, read)
+	require.Equal(t, api.ErrCorruptRecord{Offset: off}, err)
+	require.Equal(t, codes.DataLoss, status.Code(err))
+	require.NoError(t, log.Close())
+}
+
+// 書き込みの途中でクラッシュした状態を任意のバイト境界で再現して、ログが正常に再開できることをテストする。
+func TestLogRecovery(t *testing.T) {
+	dir, err := os.MkdirTemp("", "log-recovery-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = 1024
+	log, err := NewLog(dir, c)
+	require.NoError(t, err)
+	value := []byte("hello world")
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Close())
+
+	store, err := os.ReadFile(filepath.Join(dir, "0.store"))
+	require.NoError(t, err)
+	index, err := os.ReadFile(filepath.Join(dir, "0.index"))
+	require.NoError(t, err)
+	// 各レコードのフレームが終わる位置
+	var ends []int
+	for pos := 0; pos < len(store); {
+		pos += headerWidth + int(enc.Uint64(store[pos:pos+lenWidth]))
+		ends = append(ends, pos)
+	}
+	require.Equal(t, 3, len(ends))
+
+	// クラッシュするとインデックスはMaxIndexBytesまで拡張されたまま残る。
+	crashedIndex := make([]byte, c.Segment.MaxIndexBytes)
+	copy(crashedIndex, index)
+
+	reopen := func(t *testing.T, store, index []byte, want int) {
+		t.Helper()
+		dir, err := os.MkdirTemp("", "log-recovery-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0.store"), store, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0.index"), index, 0600))
+
+		log, err := NewLog(dir, c)
+		require.NoError(t, err)
+		for off := 0; off < want; off++ {
+			read, err := log.Read(uint64(off))
+			require.NoError(t, err)
+			require.Equal(t, value, read.Value)
+		}
+		_, err = log.Read(uint64(want))
+		require.Equal(t, api.ErrOffsetOutOfRange{Offset: uint64(want)}, err)
+
+		// 修復後のログに追加して、閉じて開き直しても整合性が取れていること
+		off, err := log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+		require.Equal(t, uint64(want), off)
+		require.NoError(t, log.Close())
+
+		log, err = NewLog(dir, c)
+		require.NoError(t, err)
+		off, err = log.HighestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(want), off)
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, value, read.Value)
+		require.NoError(t, log.Close())
+	}
+
+	// ストアへの書き込みがどのバイトで途切れても、完全に書き込まれたレコードまでは読み出せる。
+	for cut := 0; cut <= len(store); cut++ {
+		want := 0
+		for _, end := range ends {
+			if end <= cut {
+				want++
+			}
+		}
+		t.Run(fmt.Sprintf("store cut at %d", cut), func(t *testing.T) {
+			reopen(t, store[:cut], crashedIndex, want)
+		})
+	}
+
+	// ストアへの書き込みは完了したが、インデックスへの書き込みの前にクラッシュした場合
+	for entries := 0; entries <= len(ends); entries++ {
+		t.Run(fmt.Sprintf("index with %d entries", entries), func(t *testing.T) {
+			reopen(t, store, index[:entries*int(entWidth)], len(ends))
+		})
+	}
+
+	// 途中のレコードが壊れている場合は、後ろのレコードを切り詰めずに開くのをやめる
+	t.Run("corrupt record in the middle", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "log-recovery-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		corrupted := append([]byte(nil), store...)
+		corrupted[ends[0]+headerWidth+1] ^= 0xff
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0.store"), corrupted, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0.index"), crashedIndex, 0600))
+
+		_, err = NewLog(dir, c)
+		require.Equal(t, api.ErrCorruptRecord{Offset: 1}, err)
+		got, err := os.ReadFile(filepath.Join(dir, "0.store"))
+		require.NoError(t, err)
+		require.Equal(t, corrupted, got)
+	})
+}
+
+// チェックサムのないフレームで書かれたセグメントを開くと、レコードを失わずに今のフレームに書き直すことをテストする。
+func TestLogLegacySegment(t *testing.T) {
+	dir, err := os.MkdirTemp("", "log-legacy-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	value := []byte("hello world")
+	// | length (8) | Record | のフレームとインデックスを、セグメントごとに書き込む
+	writeSegment := func(baseOffset uint64, n int, tail []byte) {
+		var store, index []byte
+		for i := 0; i < n; i++ {
+			p, err := proto.Marshal(&api.Record{Value: value, Offset: baseOffset + uint64(i)})
+			require.NoError(t, err)
+			index = enc.AppendUint32(index, uint32(i))
+			index = enc.AppendUint64(index, uint64(len(store)))
+			store = enc.AppendUint64(store, uint64(len(p)))
+			store = append(store, p...)
+		}
+		store = append(store, tail...)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.store", baseOffset)), store, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.index", baseOffset)), index, 0600))
+	}
+	writeSegment(0, 2, nil)
+	// 書き込みの途中で止まったレコードは切り詰める
+	writeSegment(2, 1, []byte{0, 0, 0, 0, 0, 0, 0, 9, 1, 2})
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = 1024
+	log, err := NewLog(dir, c)
+	require.NoError(t, err)
+	for off := uint64(0); off < 3; off++ {
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, off, read.Offset)
+		require.Equal(t, value, read.Value)
+	}
+	off, err := log.Append(&api.Record{Value: value})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), off)
+	require.NoError(t, log.Close())
+
+	// 書き直したフレームはチェックサムで検証できる
+	store, err := os.ReadFile(filepath.Join(dir, "0.store"))
+	require.NoError(t, err)
+	_, _, _, err = readFrame(bytes.NewReader(store), nil)
+	require.NoError(t, err)
+
+	log, err = NewLog(dir, c)
+	require.NoError(t, err)
+	defer log.Close()
+	for off := uint64(0); off < 4; off++ {
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, off, read.Offset)
+	}
+}
+
+// どの永続化ポリシーでも追加したレコードを読み出せて、最終的にディスクに永続化されることをテストする。
+func TestLogSync(t *testing.T) {
+	for name, mode := range map[string]SyncMode{
+		"never":    SyncNever,
+		"always":   SyncAlways,
+		"interval": SyncInterval,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "log-sync-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.Sync.Mode = mode
+			c.Segment.Sync.Interval = 10 * time.Millisecond
+			c.Segment.Sync.Bytes = 64
+			log, err := NewLog(dir, c)
+			require.NoError(t, err)
+
+			append := &api.Record{
+				Value: []byte("hello world"),
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := log.Append(append)
+					require.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+			for off := uint64(0); off < 10; off++ {
+				read, err := log.Read(off)
+				require.NoError(t, err)
+				require.Equal(t, append.Value, read.Value)
+			}
+
+			synced := func() bool {
+				for _, s := range log.segments {
+					s.store.syncMu.Lock()
+					ok := s.store.synced == s.store.Size()
+					s.store.syncMu.Unlock()
+					if !ok {
+						return false
+					}
+				}
+				return true
+			}
+			switch mode {
+			case SyncAlways:
+				require.True(t, synced())
+			case SyncInterval:
+				require.Eventually(t, synced, time.Second, 10*time.Millisecond)
+			}
+			require.NoError(t, log.Close())
+		})
+	}
+}
+
+// 永続化ポリシーごとに、並行して追加したときのスループットを比較する。
+func BenchmarkLogAppend(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		mode SyncMode
+	}{
+		{"never", SyncNever},
+		{"interval", SyncInterval},
+		{"always", SyncAlways},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			dir, err := os.MkdirTemp("", "log-bench")
+			require.NoError(b, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.MaxStoreBytes = 64 << 20
+			c.Segment.MaxIndexBytes = 1 << 20
+			c.Segment.Sync.Mode = bm.mode
+			c.Segment.Sync.Interval = 10 * time.Millisecond
+			log, err := NewLog(dir, c)
+			require.NoError(b, err)
+			defer log.Close()
+
+			value := make([]byte, 256)
+			b.SetBytes(int64(len(value)))
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := log.Append(&api.Record{Value: value}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// 保持サイズや保持期間を超えた古いセグメントが削除され、アクティブセグメントは残ることをテストする。
+func TestLogRetention(t *testing.T) {
+	append := &api.Record{
+		Value: []byte("hello world"),
+	}
+	// timestampはi番目のレコードのタイムスタンプを返す。nilならログが追加した時刻を付ける
+	setup := func(t *testing.T, c Config, timestamp func(i int) time.Time) (*Log, func()) {
+		t.Helper()
+		dir, err := os.MkdirTemp("", "log-retention-test")
+		require.NoError(t, err)
+		// 1セグメントに2レコードずつ入るようにする
+		c.Segment.MaxIndexBytes = entWidth * 2
+		log, err := NewLog(dir, c)
+		require.NoError(t, err)
+		for i := 0; i < 6; i++ {
+			record := &api.Record{Value: append.Value}
+			if timestamp != nil {
+				record.Timestamp = timestamppb.New(timestamp(i))
+			}
+			_, err := log.Append(record)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, len(log.segments))
+		return log, func() {
+			require.NoError(t, log.Remove())
+		}
+	}
+
+	t.Run("max bytes", func(t *testing.T) {
+		c := Config{}
+		log, teardown := setup(t, c, nil)
+		defer teardown()
+		// 新しい2つのセグメント分だけ保持する
+		log.Config.Retention.MaxBytes = log.segments[1].store.Size() + log.segments[2].store.Size()
+
+		require.NoError(t, log.enforceRetention())
+		require.Equal(t, 2, len(log.segments))
+		off, err := log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), off)
+		_, err = log.Read(1)
+		require.Equal(t, api.ErrOffsetOutOfRange{Offset: 1}, err)
+
+		// どんなに小さくしてもアクティブセグメントは削除しない
+		log.Config.Retention.MaxBytes = 1
+		require.NoError(t, log.enforceRetention())
+		require.Equal(t, 1, len(log.segments))
+		read, err := log.Read(5)
+		require.NoError(t, err)
+		require.Equal(t, append.Value, read.Value)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		c := Config{}
+		now := time.Now()
+		// 最も古いセグメントのレコードだけ保持期間を過ぎている
+		log, teardown := setup(t, c, func(i int) time.Time {
+			if i < 2 {
+				return now.Add(-2 * time.Hour)
+			}
+			return now
+		})
+		defer teardown()
+		log.Config.Retention.MaxAge = 3 * time.Hour
+		require.Equal(t, uint64(0), log.RetentionOffset())
+
+		log.Config.Retention.MaxAge = time.Hour
+		require.Equal(t, uint64(2), log.RetentionOffset())
+		// ファイルの更新時刻では判断しない
+		old := now.Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(log.segments[1].store.Name(), old, old))
+		require.Equal(t, uint64(2), log.RetentionOffset())
+		require.NoError(t, log.enforceRetention())
+		require.Equal(t, 2, len(log.segments))
+		off, err := log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), off)
+	})
+
+	t.Run("background", func(t *testing.T) {
+		c := Config{}
+		c.Retention.MaxBytes = 1
+		c.Retention.CheckInterval = 10 * time.Millisecond
+		log, teardown := setup(t, c, nil)
+		defer teardown()
+		require.Eventually(t, func() bool {
+			off, err := log.LowestOffset()
+			require.NoError(t, err)
+			return off == 4
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("replicated", func(t *testing.T) {
+		c := Config{}
+		c.Retention.MaxBytes = 1
+		c.Retention.CheckInterval = 10 * time.Millisecond
+		c.Retention.Replicated = true
+		log, teardown := setup(t, c, nil)
+		defer teardown()
+		// 削除するオフセットは求めるが、自分では削除しない
+		require.Equal(t, uint64(4), log.RetentionOffset())
+		time.Sleep(50 * time.Millisecond)
+		off, err := log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), off)
+	})
+}
+
+// 圧縮方式を途中で変えても、既存のレコードと新しいレコードの両方を読み出せることと、
+// 圧縮したセグメントのスナップショットから別のログを復元できることをテストする。
+func TestLogCompression(t *testing.T) {
+	dir, err := os.MkdirTemp("", "log-compression-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	value := []byte(strings.Repeat(`{"message":"hello world"}`, 20))
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	var size uint64
+	for i, compression := range []Compression{CompressionGzip, CompressionSnappy, CompressionZstd} {
+		c.Segment.Compression = compression
+		log, err := NewLog(dir, c)
+		require.NoError(t, err)
+		for j := 0; j < 3; j++ {
+			off, err := log.Append(&api.Record{Value: value})
+			require.NoError(t, err)
+			require.Equal(t, uint64(i*3+j), off)
+		}
+		size = 0
+		for _, s := range log.segments {
+			size += s.store.Size()
+		}
+		require.NoError(t, log.Close())
+	}
+	require.Less(t, size, uint64(9*len(value)))
+
+	c.Segment.Compression = CompressionNone
+	log, err := NewLog(dir, c)
+	require.NoError(t, err)
+	defer log.Close()
+	for off := uint64(0); off < 9; off++ {
+		read, err := log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, value, read.Value)
+	}
+
+	// スナップショットはセグメントのファイルをそのまま並べたものになる
+	stagingDir, err := os.MkdirTemp("", "log-compression-staging-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(stagingDir)
+	snap, err := (&partitionFSM{topics: defaultTopicOnly(log), topic: DefaultTopic, dir: stagingDir}).Snapshot()
+	require.NoError(t, err)
+	b := persistSnapshot(t, snap)
+	restoreDir, err := os.MkdirTemp("", "log-compression-restore-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(restoreDir)
+	restored, err := NewTopics(restoreDir, c)
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, (&partitionFSM{topics: restored, topic: DefaultTopic}).Restore(io.NopCloser(bytes.NewReader(b))))
+	for off := uint64(0); off < 9; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, value, read.Value)
+	}
+
+	// まとめて追加したレコードは、セグメントに収まる分ずつ1つのフレームにして圧縮する
+	t.Run("batch", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "log-compression-batch-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		c := Config{}
+		c.Segment.MaxIndexBytes = entWidth * 4
+		c.Segment.Compression = CompressionZstd
+		log, err := NewLog(dir, c)
+		require.NoError(t, err)
+		_, err = log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+		single := log.activeSegment.store.Size()
+		require.NoError(t, log.truncateFrom(0))
+
+		var records []*api.Record
+		for i := 0; i < 6; i++ {
+			records = append(records, &api.Record{Value: value})
+		}
+		offs, err := log.AppendBatch(records)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, offs)
+		require.Equal(t, 2, len(log.segments))
+		require.Less(t, log.segments[0].store.Size(), 2*single)
+
+		// バッチの途中から切り詰めても、それより前のレコードは残る
+		require.NoError(t, log.truncateFrom(5))
+		require.NoError(t, log.Close())
+		log, err = NewLog(dir, c)
+		require.NoError(t, err)
+		defer log.Close()
+		for off := uint64(0); off < 5; off++ {
+			read, err := log.Read(off)
+			require.NoError(t, err)
+			require.Equal(t, off, read.Offset)
+			require.Equal(t, value, read.Value)
+		}
+		off, err := log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), off)
+	})
+}
+
+// 暗号化したログのスナップショットに平文が含まれないことと、同じ鍵を持つログに復元できることをテストする。
+func TestLogEncryption(t *testing.T) {
+	dir, err := os.MkdirTemp("", "log-encryption-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyring, err := NewKeyring(1, map[uint32][]byte{1: []byte("0123456789abcdef")})
+	require.NoError(t, err)
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	c.Segment.Keyring = keyring
+	log, err := NewLog(dir, c)
+	require.NoError(t, err)
+	defer log.Close()
+	value := []byte("secret message")
+	for i := 0; i < 3; i++ {
+		_, err := log.Append(&api.Record{Value: value})
+		require.NoError(t, err)
+	}
+
+	stagingDir, err := os.MkdirTemp("", "log-encryption-staging-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(stagingDir)
+	snap, err := (&partitionFSM{topics: defaultTopicOnly(log), topic: DefaultTopic, dir: stagingDir}).Snapshot()
+	require.NoError(t, err)
+	b := persistSnapshot(t, snap)
+	require.NotContains(t, string(b), string(value))
+
+	restoreDir, err := os.MkdirTemp("", "log-encryption-restore-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(restoreDir)
+	restored, err := NewTopics(restoreDir, c)
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, (&partitionFSM{topics: restored, topic: DefaultTopic}).Restore(io.NopCloser(bytes.NewReader(b))))
+	for off := uint64(0); off < 3; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, value, read.Value)
+	}
+}
+
+// lをデフォルトのトピックのログとして持つTopicsを返す。
+func defaultTopicOnly(l *Log) *Topics {
+	return &Topics{
+		Dir:    filepath.Dir(l.Dir),
+		Config: l.Config,
+		topics: map[string]*topic{
+			DefaultTopic: {
+				config: &api.TopicConfig{Partitions: 1},
+				logs:   []*Log{l},
+				offsets: []*committedOffsets{{
+					path:    filepath.Join(l.Dir, committedOffsetsFile),
+					offsets: make(map[string]uint64),
+				}},
+				producers: []*producerStates{{
+					path:      filepath.Join(l.Dir, producerStatesFile),
+					producers: make(map[uint64]*api.ProducerState),
+				}},
+			},
+		},
+	}
+}
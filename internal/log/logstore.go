@@ -0,0 +1,181 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/raft"
+	api "github.com/yurakawa/proglog/api/v1"
+)
+
+// 論理的な最初のインデックスを保存するファイル。Raftのログのディレクトリに置く
+const firstIndexFile = "first_index"
+
+var _ raft.LogStore = (*logStore)(nil)
+
+// logStore はRaftのログエントリをインデックスと同じオフセットに保存するLogStore。
+// DeleteRangeで先頭から削除したエントリは、それを含むセグメントがまるごと不要になるまでファイルに残るので、
+// 最初のインデックスをセグメントとは別に持って、それより前のエントリは読み出せないようにする。
+type logStore struct {
+	*Log
+	mu sync.RWMutex
+	// 最初のインデックス。最小のオフセットの方が大きければ、最小のオフセットから始まる
+	first uint64
+	path  string
+}
+
+func newLogStore(dir string, c Config) (*logStore, error) {
+	log, err := NewLog(dir, c)
+	if err != nil {
+		return nil, err
+	}
+	l := &logStore{Log: log, path: filepath.Join(dir, firstIndexFile)}
+	b, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(b) != int(lenWidth) {
+		return nil, fmt.Errorf("invalid first index file: %s", l.path)
+	}
+	l.first = enc.Uint64(b)
+	return l, nil
+}
+
+// エントリがなければ0を返す。
+func (l *logStore) FirstIndex() (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	first, last := l.bounds()
+	if first > last {
+		return 0, nil
+	}
+	return first, nil
+}
+
+// エントリがなければ0を返す。
+func (l *logStore) LastIndex() (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	first, last := l.bounds()
+	if first > last {
+		return 0, nil
+	}
+	return last, nil
+}
+
+// 最初と最後のインデックスを返す。エントリがなければfirstがlastより大きくなる。l.muを取得してから呼び出す。
+func (l *logStore) bounds() (first, last uint64) {
+	first, _ = l.LowestOffset()
+	if l.first > first {
+		first = l.first
+	}
+	return first, l.nextOffset() - 1
+}
+
+func (l *logStore) GetLog(index uint64, out *raft.Log) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	// 削除したエントリは、Raftがスナップショットを送るのでErrLogNotFoundで知らせる
+	if first, last := l.bounds(); index < first || index > last {
+		return raft.ErrLogNotFound
+	}
+	in, err := l.Read(index)
+	if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+		return raft.ErrLogNotFound
+	} else if err != nil {
+		return err
+	}
+	out.Data = in.Value
+	out.Index = in.Offset
+	out.Type = raft.LogType(in.Type)
+	out.Term = in.Term
+	return nil
+}
+
+func (l *logStore) StoreLog(record *raft.Log) error {
+	return l.StoreLogs([]*raft.Log{record})
+}
+
+func (l *logStore) StoreLogs(records []*raft.Log) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, record := range records {
+		first, last := l.bounds()
+		switch {
+		case record.Index == last+1 && record.Index >= first:
+		case first <= last && first <= record.Index && record.Index <= last:
+			// 衝突したエントリを上書きする
+			if err := l.truncateFrom(record.Index); err != nil {
+				return err
+			}
+		default:
+			// 空のログか、スナップショットを受け取った後にその続きから送られてきたエントリ。
+			// それより前のエントリはスナップショットに含まれるので、捨ててrecord.Indexから始める
+			if err := l.resetAt(record.Index); err != nil {
+				return err
+			}
+			if err := l.resetFirst(record.Index); err != nil {
+				return err
+			}
+		}
+		if _, err := l.Append(&api.Record{
+			Value: record.Data,
+			Term:  record.Term,
+			Type:  uint32(record.Type),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// minからmaxまでのエントリを削除する。Raftはスナップショットを取った後に先頭から、
+// リーダーのログと衝突したときに末尾から削除するので、途中だけを削除することはできない。
+func (l *logStore) DeleteRange(min, max uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first, last := l.bounds()
+	if first > last || max < first || min > last {
+		return nil
+	}
+	switch {
+	case min <= first && max >= last:
+		// すべて削除したら、次はmax+1から始める
+		if err := l.resetAt(max + 1); err != nil {
+			return err
+		}
+		return l.resetFirst(max + 1)
+	case min <= first:
+		if err := l.resetFirst(max + 1); err != nil {
+			return err
+		}
+		// すべてのエントリを削除したセグメントだけを削除する
+		return l.Truncate(max)
+	case max >= last:
+		return l.truncateFrom(min)
+	default:
+		return fmt.Errorf("cannot delete entries in the middle of the log: %d-%d", min, max)
+	}
+}
+
+// 最初のインデックスを変えてファイルに書き込む。書きかけのファイルが残らないように、一時ファイルに書き込んでから置き換える。
+// l.muを取得してから呼び出す。
+func (l *logStore) resetFirst(first uint64) error {
+	b := make([]byte, lenWidth)
+	enc.PutUint64(b, first)
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	l.first = first
+	return nil
+}
@@ -0,0 +1,295 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	raftbench "github.com/hashicorp/raft/bench"
+	"github.com/stretchr/testify/require"
+)
+
+// Raftがスナップショットや衝突したエントリの上書きで行う操作の後も、LogStoreの契約を満たすことをテストする。
+func TestLogStore(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, l *logStore){
+		"empty store has no indexes":         testLogStoreEmpty,
+		"store and get logs":                 testLogStoreStoreLogs,
+		"delete prefix after snapshot":       testLogStoreDeletePrefix,
+		"delete suffix on conflict":          testLogStoreDeleteSuffix,
+		"overwrite conflicting entries":      testLogStoreOverwrite,
+		"delete all entries":                 testLogStoreDeleteAll,
+		"store after gap from snapshot":      testLogStoreGap,
+		"cannot delete middle of log":        testLogStoreDeleteMiddle,
+		"first index survives reopen":        testLogStoreReopen,
+		"delete prefix in middle of segment": testLogStoreDeletePrefixInSegment,
+		"delete before first index reopened": testLogStoreDeleteBeforeReopenedFirst,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "log-store-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			l, err := newLogStore(dir, logStoreConfig())
+			require.NoError(t, err)
+			defer l.Close()
+			fn(t, l)
+		})
+	}
+}
+
+// raft-boltdbのBoltStoreのテスト(bolt_store_test.go)のFirstIndex、LastIndex、GetLog、SetLog、SetLogs、DeleteRangeを
+// 移植したもの。BoltStoreと同じ結果になることをテストする。
+func TestLogStoreBoltStoreCases(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// 追加するエントリ。1件ならStoreLogで、複数ならStoreLogsで追加する
+		logs []*raft.Log
+		// 0でなければ追加した後にDeleteRangeで削除する
+		deleteMin, deleteMax uint64
+		first, last          uint64
+		found, notFound      []uint64
+	}{
+		{name: "FirstIndex of empty store", notFound: []uint64{1}},
+		{name: "FirstIndex", logs: boltLogs(1, 3), first: 1, last: 3},
+		{name: "LastIndex", logs: boltLogs(1, 3), first: 1, last: 3, found: []uint64{3}},
+		{name: "GetLog", logs: boltLogs(1, 3), first: 1, last: 3, found: []uint64{2}, notFound: []uint64{4}},
+		{name: "SetLog", logs: boltLogs(1, 1), first: 1, last: 1, found: []uint64{1}},
+		{name: "SetLogs", logs: boltLogs(1, 2), first: 1, last: 2, found: []uint64{1, 2}},
+		{
+			name: "DeleteRange", logs: boltLogs(1, 3), deleteMin: 1, deleteMax: 2,
+			first: 3, last: 3, found: []uint64{3}, notFound: []uint64{1, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "log-store-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			l, err := newLogStore(dir, logStoreConfig())
+			require.NoError(t, err)
+			defer l.Close()
+
+			if len(tc.logs) == 1 {
+				require.NoError(t, l.StoreLog(tc.logs[0]))
+			} else if len(tc.logs) > 1 {
+				require.NoError(t, l.StoreLogs(tc.logs))
+			}
+			if tc.deleteMin != 0 {
+				require.NoError(t, l.DeleteRange(tc.deleteMin, tc.deleteMax))
+			}
+			requireIndexes(t, l, tc.first, tc.last)
+			for _, index := range tc.found {
+				out := &raft.Log{}
+				require.NoError(t, l.GetLog(index, out))
+				require.Equal(t, tc.logs[index-1], out)
+			}
+			for _, index := range tc.notFound {
+				require.Equal(t, raft.ErrLogNotFound, l.GetLog(index, &raft.Log{}))
+			}
+		})
+	}
+}
+
+// raft-boltdbのテストと同じく、インデックスとデータだけのエントリを作る
+func boltLogs(from, to uint64) []*raft.Log {
+	var logs []*raft.Log
+	for index := from; index <= to; index++ {
+		logs = append(logs, &raft.Log{Index: index, Data: []byte(fmt.Sprintf("log%d", index))})
+	}
+	return logs
+}
+
+// Raftのグループと同じく1から始めて、セグメントを3エントリごとに分ける
+func logStoreConfig() Config {
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 3
+	c.Segment.InitialOffset = 1
+	return c
+}
+
+func testLogStoreEmpty(t *testing.T, l *logStore) {
+	first, err := l.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), first)
+	last, err := l.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), last)
+	require.Equal(t, raft.ErrLogNotFound, l.GetLog(1, &raft.Log{}))
+}
+
+func testLogStoreStoreLogs(t *testing.T, l *logStore) {
+	require.NoError(t, l.StoreLog(raftLog(1, 1)))
+	require.NoError(t, l.StoreLogs([]*raft.Log{raftLog(2, 1), raftLog(3, 2)}))
+	requireIndexes(t, l, 1, 3)
+	for index := uint64(1); index <= 3; index++ {
+		requireLog(t, l, index)
+	}
+	require.Equal(t, raft.ErrLogNotFound, l.GetLog(4, &raft.Log{}))
+}
+
+func testLogStoreDeletePrefix(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 10)
+	require.NoError(t, l.DeleteRange(1, 4))
+	requireIndexes(t, l, 5, 10)
+	// 削除したエントリが同じセグメントに残っていても読み出せない
+	for index := uint64(1); index <= 4; index++ {
+		require.Equal(t, raft.ErrLogNotFound, l.GetLog(index, &raft.Log{}))
+	}
+	for index := uint64(5); index <= 10; index++ {
+		requireLog(t, l, index)
+	}
+	// すべてのエントリを削除したセグメントはファイルも消える
+	lowest, err := l.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), lowest)
+	storeRaftLogs(t, l, 11, 11)
+	requireIndexes(t, l, 5, 11)
+}
+
+func testLogStoreDeleteSuffix(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 10)
+	require.NoError(t, l.DeleteRange(5, 10))
+	requireIndexes(t, l, 1, 4)
+	require.Equal(t, raft.ErrLogNotFound, l.GetLog(5, &raft.Log{}))
+	// 削除した後のエントリの続きに追加できる
+	require.NoError(t, l.StoreLog(&raft.Log{Index: 5, Term: 3, Data: []byte("new")}))
+	requireIndexes(t, l, 1, 5)
+	out := &raft.Log{}
+	require.NoError(t, l.GetLog(5, out))
+	require.Equal(t, uint64(3), out.Term)
+	require.Equal(t, []byte("new"), out.Data)
+}
+
+func testLogStoreOverwrite(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 5)
+	require.NoError(t, l.StoreLogs([]*raft.Log{
+		{Index: 3, Term: 2, Data: []byte("three")},
+		{Index: 4, Term: 2, Data: []byte("four")},
+	}))
+	requireIndexes(t, l, 1, 4)
+	out := &raft.Log{}
+	require.NoError(t, l.GetLog(3, out))
+	require.Equal(t, []byte("three"), out.Data)
+	requireLog(t, l, 2)
+}
+
+func testLogStoreDeleteAll(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 5)
+	require.NoError(t, l.DeleteRange(1, 5))
+	requireIndexes(t, l, 0, 0)
+	storeRaftLogs(t, l, 6, 7)
+	requireIndexes(t, l, 6, 7)
+}
+
+func testLogStoreGap(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 3)
+	// スナップショットを受け取ったフォロワーには、スナップショットの続きから送られてくる
+	storeRaftLogs(t, l, 20, 21)
+	requireIndexes(t, l, 20, 21)
+	require.Equal(t, raft.ErrLogNotFound, l.GetLog(3, &raft.Log{}))
+	requireLog(t, l, 20)
+}
+
+func testLogStoreDeleteMiddle(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 5)
+	require.Error(t, l.DeleteRange(2, 3))
+	requireIndexes(t, l, 1, 5)
+}
+
+func testLogStoreReopen(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 5)
+	require.NoError(t, l.DeleteRange(1, 2))
+	require.NoError(t, l.Close())
+	reopened, err := newLogStore(l.Dir, logStoreConfig())
+	require.NoError(t, err)
+	defer reopened.Close()
+	requireIndexes(t, reopened, 3, 5)
+	require.Equal(t, raft.ErrLogNotFound, reopened.GetLog(2, &raft.Log{}))
+}
+
+func testLogStoreDeletePrefixInSegment(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 2)
+	require.NoError(t, l.DeleteRange(1, 1))
+	requireIndexes(t, l, 2, 2)
+	require.NoError(t, l.DeleteRange(2, 2))
+	requireIndexes(t, l, 0, 0)
+	storeRaftLogs(t, l, 3, 3)
+	requireIndexes(t, l, 3, 3)
+}
+
+func testLogStoreDeleteBeforeReopenedFirst(t *testing.T, l *logStore) {
+	storeRaftLogs(t, l, 1, 10)
+	// 衝突したエントリを末尾から削除した後に、スナップショットを取って先頭から削除する
+	require.NoError(t, l.DeleteRange(8, 10))
+	require.NoError(t, l.DeleteRange(1, 4))
+	require.NoError(t, l.Close())
+	l, err := newLogStore(l.Dir, logStoreConfig())
+	require.NoError(t, err)
+	requireIndexes(t, l, 5, 7)
+	// 保存した最初のインデックスより前から削除する
+	require.NoError(t, l.DeleteRange(1, 6))
+	requireIndexes(t, l, 7, 7)
+	require.Equal(t, raft.ErrLogNotFound, l.GetLog(6, &raft.Log{}))
+	require.NoError(t, l.Close())
+	l, err = newLogStore(l.Dir, logStoreConfig())
+	require.NoError(t, err)
+	defer l.Close()
+	requireIndexes(t, l, 7, 7)
+	requireLog(t, l, 7)
+	storeRaftLogs(t, l, 8, 8)
+	requireIndexes(t, l, 7, 8)
+}
+
+func raftLog(index, term uint64) *raft.Log {
+	return &raft.Log{Index: index, Term: term, Type: raft.LogCommand, Data: []byte("data")}
+}
+
+func storeRaftLogs(t *testing.T, l *logStore, from, to uint64) {
+	t.Helper()
+	var logs []*raft.Log
+	for index := from; index <= to; index++ {
+		logs = append(logs, raftLog(index, 1))
+	}
+	require.NoError(t, l.StoreLogs(logs))
+}
+
+func requireIndexes(t *testing.T, l *logStore, first, last uint64) {
+	t.Helper()
+	got, err := l.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, first, got)
+	got, err = l.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, last, got)
+}
+
+func requireLog(t *testing.T, l *logStore, index uint64) {
+	t.Helper()
+	out := &raft.Log{}
+	require.NoError(t, l.GetLog(index, out))
+	require.Equal(t, index, out.Index)
+	require.Equal(t, raft.LogCommand, out.Type)
+	require.Equal(t, []byte("data"), out.Data)
+}
+
+func BenchmarkLogStore(b *testing.B) {
+	for name, fn := range map[string]func(b *testing.B, store raft.LogStore){
+		"FirstIndex":  raftbench.FirstIndex,
+		"LastIndex":   raftbench.LastIndex,
+		"GetLog":      raftbench.GetLog,
+		"StoreLog":    raftbench.StoreLog,
+		"StoreLogs":   raftbench.StoreLogs,
+		"DeleteRange": raftbench.DeleteRange,
+	} {
+		b.Run(name, func(b *testing.B) {
+			dir, err := os.MkdirTemp("", "log-store-bench")
+			require.NoError(b, err)
+			defer os.RemoveAll(dir)
+			c := Config{}
+			c.Segment.InitialOffset = 1
+			l, err := newLogStore(dir, c)
+			require.NoError(b, err)
+			defer l.Close()
+			fn(b, l)
+		})
+	}
+}
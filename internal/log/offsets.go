@@ -0,0 +1,94 @@
+package log
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// コミットされたオフセットを保存するファイル。パーティションのディレクトリに置く
+const committedOffsetsFile = "committed_offsets"
+
+// committedOffsets はパーティションにコンシューマーグループがコミットしたオフセットを保持する。
+// コミットのたびにファイルに書き込むので、再起動しても残る。
+type committedOffsets struct {
+	mu      sync.RWMutex
+	path    string
+	offsets map[string]uint64
+}
+
+// dirのファイルからコミットされたオフセットを読み出す。ファイルがなければ空で始める。
+func openCommittedOffsets(dir string) (*committedOffsets, error) {
+	o := &committedOffsets{
+		path:    filepath.Join(dir, committedOffsetsFile),
+		offsets: make(map[string]uint64),
+	}
+	b, err := os.ReadFile(o.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var committed api.CommittedOffsets
+	if err = proto.Unmarshal(b, &committed); err != nil {
+		return nil, err
+	}
+	for group, off := range committed.Offsets {
+		o.offsets[group] = off
+	}
+	return o, nil
+}
+
+func (o *committedOffsets) commit(group string, offset uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.offsets[group] = offset
+	return o.write()
+}
+
+func (o *committedOffsets) get(group string) (uint64, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	off, ok := o.offsets[group]
+	return off, ok
+}
+
+// スナップショットに含めるために、すべてのグループのオフセットのコピーを返す。
+func (o *committedOffsets) all() *api.CommittedOffsets {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	committed := &api.CommittedOffsets{Offsets: make(map[string]uint64, len(o.offsets))}
+	for group, off := range o.offsets {
+		committed.Offsets[group] = off
+	}
+	return committed
+}
+
+// スナップショットから復元するときに、すべてのグループのオフセットを置き換える。
+func (o *committedOffsets) reset(committed *api.CommittedOffsets) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.offsets = make(map[string]uint64, len(committed.GetOffsets()))
+	for group, off := range committed.GetOffsets() {
+		o.offsets[group] = off
+	}
+	return o.write()
+}
+
+// 書きかけのファイルが残らないように、一時ファイルに書き込んでから置き換える。o.muを取得してから呼び出す。
+func (o *committedOffsets) write() error {
+	b, err := proto.Marshal(&api.CommittedOffsets{Offsets: o.offsets})
+	if err != nil {
+		return err
+	}
+	tmp := o.path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
@@ -0,0 +1,856 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+	api "github.com/yurakawa/proglog/api/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// パーティションのグループをメタデータのグループの構成に合わせる間隔のデフォルト
+const defaultReconcileInterval = time.Second
+
+// パーティションのログで保持期間と保持サイズを超えたセグメントを探す間隔のデフォルト。Logと同じにする
+const defaultRetentionCheckInterval = time.Minute
+
+// リーダーでなくなったエントリを送り直すまでに待つ時間。新しいリーダーが選ばれるのを待つ
+const leadershipLostRetryDelay = 100 * time.Millisecond
+
+// raftGroup は1つのRaftのグループと、そのグループが使うストアをまとめたもの。
+type raftGroup struct {
+	raft        *raft.Raft
+	raftLog     *logStore
+	stableStore *raftboltdb.BoltStore
+
+	// リーダーとしてハートビートを送れていないノード。Raftのオブザーバーで追跡する
+	mu               sync.Mutex
+	unhealthy        map[raft.ServerID]bool
+	leaderChanged    time.Time
+	heartbeatTimeout time.Duration
+	observer         *raft.Observer
+	observations     chan raft.Observation
+}
+
+// dirにグループのRaftのログとスナップショットを置いてRaftを起動する。
+// 既存の状態がなく、bootstrapが空でなければ、そのサーバでクラスタをブートストラップする。
+func newRaftGroup(
+	dir string,
+	fsm raft.FSM,
+	streamLayer *StreamLayer,
+	c Config,
+	bootstrap []raft.Server,
+) (*raftGroup, error) {
+	g := &raftGroup{}
+	logDir := filepath.Join(dir, "log")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, err
+	}
+	logConfig := c
+	logConfig.Segment.InitialOffset = 1
+	// Raftのログエントリの削除はRaftがDeleteRangeで行うので、保持期間で勝手に削除してはいけない
+	logConfig.Retention.MaxAge = 0
+	logConfig.Retention.MaxBytes = 0
+	// 圧縮するとオフセットが飛び飛びになり、Raftのログとして使えない
+	logConfig.Compaction.Enabled = false
+	var err error
+	g.raftLog, err = newLogStore(logDir, logConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	g.stableStore, err = raftboltdb.NewBoltStore(
+		filepath.Join(dir, "stable"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	retain := c.Raft.SnapshotRetain
+	if retain == 0 {
+		retain = 1
+	}
+	snapshotStore, err := newSnapshotStore(dir, retain)
+	if err != nil {
+		return nil, err
+	}
+	maxPool := 5
+	timeout := 10 * time.Second
+	transport := raft.NewNetworkTransport(
+		streamLayer,
+		maxPool,
+		timeout,
+		os.Stderr,
+	)
+
+	config := raft.DefaultConfig()
+	config.LocalID = c.Raft.LocalID
+	if c.Raft.HeartbeatTimeout != 0 {
+		config.HeartbeatTimeout = c.Raft.HeartbeatTimeout
+	}
+	if c.Raft.ElectionTimeout != 0 {
+		config.ElectionTimeout = c.Raft.ElectionTimeout
+	}
+	if c.Raft.LeaderLeaseTimeout != 0 {
+		config.LeaderLeaseTimeout = c.Raft.LeaderLeaseTimeout
+	}
+	if c.Raft.CommitTimeout != 0 {
+		config.CommitTimeout = c.Raft.CommitTimeout
+	}
+	if c.Raft.SnapshotThreshold != 0 {
+		config.SnapshotThreshold = c.Raft.SnapshotThreshold
+	}
+	if c.Raft.SnapshotInterval != 0 {
+		config.SnapshotInterval = c.Raft.SnapshotInterval
+	}
+	if c.Raft.TrailingLogs != 0 {
+		config.TrailingLogs = c.Raft.TrailingLogs
+	}
+
+	g.raft, err = raft.NewRaft(
+		config,
+		fsm,
+		g.raftLog,
+		g.stableStore,
+		snapshotStore,
+		transport,
+	)
+	if err != nil {
+		return nil, err
+	}
+	g.unhealthy = make(map[raft.ServerID]bool)
+	g.heartbeatTimeout = config.HeartbeatTimeout
+	g.observations = make(chan raft.Observation, 16)
+	g.observer = raft.NewObserver(g.observations, true, func(o *raft.Observation) bool {
+		switch o.Data.(type) {
+		case raft.LeaderObservation, raft.FailedHeartbeatObservation, raft.ResumedHeartbeatObservation:
+			return true
+		}
+		return false
+	})
+	g.raft.RegisterObserver(g.observer)
+	go func() {
+		for o := range g.observations {
+			g.observe(o)
+		}
+	}()
+	hasState, err := raft.HasExistingState(
+		g.raftLog,
+		g.stableStore,
+		snapshotStore,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(bootstrap) > 0 && !hasState {
+		err = g.raft.BootstrapCluster(raft.Configuration{
+			Servers: bootstrap,
+		}).Error()
+	}
+	return g, err
+}
+
+func (g *raftGroup) apply(reqType RequestType, req proto.Message) (
+	interface{},
+	error,
+) {
+	var buf bytes.Buffer
+	_, err := buf.Write([]byte{byte(reqType)})
+	if err != nil {
+		return nil, err
+	}
+	// 同様にproto.Mershalでrequestをバイト列に変換してbufに書き込む
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	_, err = buf.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Second
+	// ここでraftにバイト列を渡している
+	// リーダーのログにレコードを追加する?
+	// future object pattern
+	future := g.raft.Apply(buf.Bytes(), timeout)
+	// Error()でresが届くまでblockする。
+	if err := future.Error(); err != nil {
+		// ErrNotLeaderならエントリはログに追加されていないので、クライアントはリーダーに送り直せる
+		if err == raft.ErrNotLeader {
+			return nil, g.errNotLeader()
+		}
+		// エントリが適用されたかはわからないが、新しいリーダーに送り直せる
+		if err == raft.ErrLeadershipLost {
+			return nil, api.ErrUnavailable{
+				Reason:     err.Error(),
+				LeaderAddr: string(g.raft.Leader()),
+				RetryDelay: leadershipLostRetryDelay,
+			}
+		}
+		return nil, err
+	}
+
+	res := future.Response()
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+	return res, nil
+}
+
+// 現在のリーダーのアドレスを持つapi.ErrNotLeaderを返す。RaftとgRPCは同じアドレスで待ち受けているので、
+// Raftのアドレスをそのままリーダーのアドレスとして使える。
+func (g *raftGroup) errNotLeader() error {
+	return api.ErrNotLeader{LeaderAddr: string(g.raft.Leader())}
+}
+
+// グループのRaftの状態をraft.Stats()から取り出して返す。
+func (g *raftGroup) status() *api.RaftStatus {
+	stats := g.raft.Stats()
+	stat := func(key string) uint64 {
+		// 数値でない値は0にする
+		n, _ := strconv.ParseUint(stats[key], 10, 64)
+		return n
+	}
+	status := &api.RaftStatus{
+		State:             stats["state"],
+		Term:              stat("term"),
+		LastLogIndex:      stat("last_log_index"),
+		CommitIndex:       stat("commit_index"),
+		AppliedIndex:      stat("applied_index"),
+		LastSnapshotIndex: stat("last_snapshot_index"),
+		LastSnapshotTerm:  stat("last_snapshot_term"),
+		LeaderAddr:        string(g.raft.Leader()),
+	}
+	// リーダーは自分と通信しないので、最後に通信した時刻を持たない
+	if last := g.raft.LastContact(); g.raft.State() != raft.Leader && !last.IsZero() {
+		status.LastContact = timestamppb.New(last)
+	}
+	return status
+}
+
+// リーダーのハートビートの成否から、ノードへの複製が健全かを記録する。
+func (g *raftGroup) observe(o raft.Observation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch data := o.Data.(type) {
+	case raft.LeaderObservation:
+		// リーダーが変わるとハートビートも送り直すので、それまでの失敗は関係ない
+		g.unhealthy = make(map[raft.ServerID]bool)
+		g.leaderChanged = time.Now()
+	case raft.FailedHeartbeatObservation:
+		g.unhealthy[data.PeerID] = true
+	case raft.ResumedHeartbeatObservation:
+		delete(g.unhealthy, data.PeerID)
+	}
+}
+
+// このノードがリーダーのとき、idのノードにハートビートを送れているかを返す。
+// リーダーになった直後は、まだハートビートの失敗がわからないので、どのノードも健全とみなさない。
+func (g *raftGroup) healthy(id raft.ServerID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Since(g.leaderChanged) >= g.heartbeatTimeout && !g.unhealthy[id]
+}
+
+func (g *raftGroup) close() error {
+	// Raftいんすたんすをしゃっとだうん。トランスポートとストリームレイヤも閉じる
+	f := g.raft.Shutdown()
+	if err := f.Error(); err != nil {
+		return err
+	}
+	// 登録を解除すればオブザーバーに送られることはないので、チャネルを閉じてゴルーチンを止める
+	g.raft.DeregisterObserver(g.observer)
+	close(g.observations)
+	if err := g.stableStore.Close(); err != nil {
+		return err
+	}
+	// RaftのログストアをClose
+	return g.raftLog.Log.Close()
+}
+
+// パーティションのグループのID。ストリームレイヤでグループを識別するのに使う。
+// トピック名に'/'は使えないので、ほかのトピックのパーティションと重ならない。
+func groupID(topic string, partition uint32) string {
+	return fmt.Sprintf("%s/%d", topic, partition)
+}
+
+func (l *DistributedLog) partitionsDir() string {
+	return filepath.Join(l.dataDir, "raft", "partitions")
+}
+
+// 既存のトピックのパーティションのグループを起動する。パーティションを導入する前のデータや
+// 初めて起動したときは、ブートストラップするノードがこのノードだけでグループを始める。
+func (l *DistributedLog) setupPartitions() error {
+	_, err := os.Stat(l.partitionsDir())
+	fresh := errors.Is(err, os.ErrNotExist)
+	if err != nil && !fresh {
+		return err
+	}
+	if err = os.MkdirAll(l.partitionsDir(), 0755); err != nil {
+		return err
+	}
+	var bootstrap []raft.Server
+	if l.config.Raft.Bootstrap && fresh {
+		bootstrap = []raft.Server{{
+			ID:      l.config.Raft.LocalID,
+			Address: raft.ServerAddress(l.config.Raft.BindAddr),
+		}}
+	}
+	topics, err := l.topics.ListTopics()
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		for p := uint32(0); p < topic.Config.Partitions; p++ {
+			if err = l.startGroup(topic.Name, p, bootstrap); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// トピックのパーティションのグループを返す。
+func (l *DistributedLog) group(topic string, partition uint32) (*raftGroup, error) {
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	l.mu.RLock()
+	g, ok := l.groups[groupID(topic, partition)]
+	l.mu.RUnlock()
+	if ok {
+		return g, nil
+	}
+	if _, err := l.topics.Log(topic, partition); err != nil {
+		return nil, err
+	}
+	return nil, api.ErrPartitionNotFound{Topic: topic, Partition: partition}
+}
+
+func (l *DistributedLog) startTopic(topic *api.Topic, servers []*api.Server) error {
+	var bootstrap []raft.Server
+	for _, server := range servers {
+		bootstrap = append(bootstrap, raft.Server{
+			ID:      raft.ServerID(server.Id),
+			Address: raft.ServerAddress(server.RpcAddr),
+		})
+	}
+	// このノードを含まない構成でブートストラップすると、別のクラスタができてしまう
+	self := false
+	for _, server := range bootstrap {
+		if server.ID == l.config.Raft.LocalID {
+			self = true
+		}
+	}
+	if !self {
+		bootstrap = nil
+	}
+	for p := uint32(0); p < topic.Config.Partitions; p++ {
+		if err := l.startGroup(topic.Name, p, bootstrap); err != nil {
+			return err
+		}
+	}
+	l.triggerReconcile()
+	return nil
+}
+
+func (l *DistributedLog) startGroup(topic string, partition uint32, bootstrap []raft.Server) error {
+	id := groupID(topic, partition)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.groups[id]; ok {
+		return nil
+	}
+	streamLayer, err := l.config.Raft.StreamLayer.Group(id)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(l.partitionsDir(), topic, strconv.FormatUint(uint64(partition), 10))
+	// 前回のプロセスが保存し終える前に止まったスナップショットのハードリンクを捨てる
+	stagingDir := filepath.Join(dir, snapshotStagingDir)
+	if err = os.RemoveAll(stagingDir); err != nil {
+		streamLayer.Close()
+		return err
+	}
+	g, err := newRaftGroup(
+		dir,
+		&partitionFSM{topics: l.topics, topic: topic, partition: partition, dir: stagingDir},
+		streamLayer,
+		l.config,
+		bootstrap,
+	)
+	if err != nil {
+		streamLayer.Close()
+		return err
+	}
+	l.groups[id] = g
+	return nil
+}
+
+// トピックのパーティションのグループを止めて、そのRaftの状態を削除する。
+func (l *DistributedLog) stopTopic(name string) error {
+	prefix := name + "/"
+	var groups []*raftGroup
+	l.mu.Lock()
+	for id, g := range l.groups {
+		if strings.HasPrefix(id, prefix) {
+			groups = append(groups, g)
+			delete(l.groups, id)
+		}
+	}
+	l.mu.Unlock()
+	for _, g := range groups {
+		if err := g.close(); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(filepath.Join(l.partitionsDir(), name))
+}
+
+// match が真を返すトピックのパーティションのグループで、すべてリーダーが選ばれているか返す。
+func (l *DistributedLog) hasLeaders(match func(topic string) bool) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for id, g := range l.groups {
+		if !match(id[:strings.LastIndex(id, "/")]) {
+			continue
+		}
+		if g.raft.Leader() == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// トピックのすべてのパーティションでリーダーが選ばれるまで、最大timeoutだけ待つ。
+func (l *DistributedLog) waitForTopic(name string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if l.hasLeaders(func(topic string) bool { return topic == name }) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (l *DistributedLog) triggerReconcile() {
+	select {
+	case l.reconcilec <- struct{}{}:
+	default:
+	}
+}
+
+// 定期的に、またはメンバーが変わったときに、パーティションのグループをメタデータのグループに合わせる。
+// 保持期間と保持サイズを超えたセグメントの削除も、Retention.CheckIntervalごとにここで行う。
+func (l *DistributedLog) reconcileLoop() {
+	defer close(l.donec)
+	interval := l.config.Raft.ReconcileInterval
+	if interval == 0 {
+		interval = defaultReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	retentionInterval := l.config.Retention.CheckInterval
+	if retentionInterval == 0 {
+		retentionInterval = defaultRetentionCheckInterval
+	}
+	retention := time.NewTicker(retentionInterval)
+	defer retention.Stop()
+	for {
+		select {
+		case <-l.closec:
+			return
+		case <-ticker.C:
+		case <-l.reconcilec:
+		case <-retention.C:
+			l.enforceRetention()
+			continue
+		}
+		l.reconcile()
+	}
+}
+
+// ノードで動いているパーティションのグループ
+type partitionGroup struct {
+	topic string
+	id    uint32
+	group *raftGroup
+}
+
+// このノードがリーダーのパーティションのグループを返す。
+func (l *DistributedLog) leaderPartitions() []partitionGroup {
+	var partitions []partitionGroup
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for id, g := range l.groups {
+		if g.raft.State() != raft.Leader {
+			continue
+		}
+		i := strings.LastIndex(id, "/")
+		p, err := strconv.ParseUint(id[i+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		partitions = append(partitions, partitionGroup{topic: id[:i], id: uint32(p), group: g})
+	}
+	return partitions
+}
+
+// このノードがリーダーのパーティションについて、保持期間か保持サイズを超えたセグメントの削除をグループで複製する。
+// 削除するオフセットはリーダーのログで決めるので、フォロワーのログでも同じセグメントが削除される。
+func (l *DistributedLog) enforceRetention() {
+	for _, p := range l.leaderPartitions() {
+		off, err := l.topics.RetentionOffset(p.topic, p.id)
+		if err != nil {
+			continue
+		}
+		lowest, _, err := l.topics.OffsetRange(p.topic, p.id)
+		if err != nil || off <= lowest {
+			continue
+		}
+		if _, err = p.group.apply(
+			TruncateBeforeRequestType,
+			&api.TruncateBeforeRequest{Topic: p.topic, Partition: p.id, Offset: off},
+		); err != nil {
+			// 次の機会にやり直す
+			l.logger.Debug(
+				"failed to enforce retention",
+				zap.String("group", groupID(p.topic, p.id)),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// このノードがリーダーのパーティションのグループについて、メンバーと投票権をメタデータのグループに合わせ、
+// リーダーを優先するノードに移す。メタデータのグループで投票しないサーバは、パーティションのグループでも投票しない。
+func (l *DistributedLog) reconcile() {
+	servers, err := l.servers()
+	if err != nil || len(votersOf(servers)) == 0 {
+		return
+	}
+	for _, p := range l.leaderPartitions() {
+		if err := l.reconcileGroup(p.topic, p.id, p.group, servers); err != nil {
+			// 次の機会にやり直す
+			l.logger.Debug(
+				"failed to reconcile partition",
+				zap.String("group", groupID(p.topic, p.id)),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (l *DistributedLog) reconcileGroup(topic string, partition uint32, g *raftGroup, servers []raft.Server) error {
+	future := g.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return err
+	}
+	members := make(map[raft.ServerID]raft.Server)
+	for _, server := range future.Configuration().Servers {
+		members[server.ID] = server
+	}
+	want := make(map[raft.ServerID]bool)
+	for _, server := range servers {
+		want[server.ID] = true
+		member, ok := members[server.ID]
+		if ok && member.Address == server.Address && member.Suffrage == server.Suffrage {
+			continue
+		}
+		var f raft.IndexFuture
+		switch {
+		case server.Suffrage == raft.Voter:
+			f = g.raft.AddVoter(server.ID, server.Address, 0, 0)
+		case ok && member.Address == server.Address:
+			// AddNonvoterでは投票者のままなので、投票権を外す
+			f = g.raft.DemoteVoter(server.ID, 0, 0)
+		default:
+			f = g.raft.AddNonvoter(server.ID, server.Address, 0, 0)
+		}
+		if err := f.Error(); err != nil {
+			return err
+		}
+	}
+	for id := range members {
+		if want[id] {
+			continue
+		}
+		if err := g.raft.RemoveServer(id, 0, 0).Error(); err != nil {
+			return err
+		}
+	}
+	// リーダーを移すのは、前回までにグループに投票者として追加されて複製が追いついているはずのノードだけにする。
+	// 止めようとしているノードは除いて選ぶので、そのノードからリーダーを移しても戻さない
+	candidates := l.leaderCandidates(servers)
+	if len(candidates) == 0 {
+		return nil
+	}
+	preferred := candidates[preferredLeader(topic, partition, len(candidates))]
+	if member, ok := members[preferred.ID]; !ok || member.Suffrage != raft.Voter || preferred.ID == l.config.Raft.LocalID {
+		return nil
+	}
+	// 応答しないノードに移そうとすると、Raftはリーダーを移している途中のままになり、
+	// リーダーでなくなるまで書き込みを受け付けない。ハートビートを送れていないノードには移さない
+	if !g.healthy(preferred.ID) {
+		return nil
+	}
+	return g.raft.LeadershipTransferToServer(preferred.ID, preferred.Address).Error()
+}
+
+// パーティションのリーダーにするノードの、IDの順に並べた投票者の中での位置を返す。
+// トピックごとに始まりをずらして、パーティションのリーダーがノードに均等に分散するようにする。
+func preferredLeader(topic string, partition uint32, voters int) int {
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	return int((h.Sum32() + partition) % uint32(voters))
+}
+
+var _ raft.FSM = (*partitionFSM)(nil)
+
+// partitionFSM はパーティションのグループのFSMで、パーティションのログにレコードを追加する。
+type partitionFSM struct {
+	topics    *Topics
+	topic     string
+	partition uint32
+	// スナップショットを取るときにセグメントのファイルをハードリンクするディレクトリ
+	dir string
+}
+
+func (f *partitionFSM) Apply(record *raft.Log) interface{} {
+	buf := record.Data
+	reqType := RequestType(buf[0])
+	switch reqType {
+	case AppendRequestType:
+		return f.applyAppend(buf[1:])
+	case AppendBatchRequestType:
+		return f.applyAppendBatch(buf[1:])
+	case CommitOffsetRequestType:
+		return f.applyCommitOffset(buf[1:])
+	case TruncateBeforeRequestType:
+		return f.applyTruncateBefore(buf[1:])
+	}
+	return nil
+}
+
+// セグメントの境界はノードごとに違うことがあるので、削除するオフセットだけを複製して、それぞれのノードで削除できるセグメントを削除する。
+func (f *partitionFSM) applyTruncateBefore(b []byte) interface{} {
+	var req api.TruncateBeforeRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		return err
+	}
+	lowest, err := f.topics.TruncateBefore(f.topic, f.partition, req.Offset)
+	if err != nil {
+		return err
+	}
+	return &api.TruncateBeforeResponse{LowestOffset: lowest}
+}
+
+func (f *partitionFSM) applyCommitOffset(b []byte) interface{} {
+	var req api.CommitOffsetRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		return err
+	}
+	if err := f.topics.CommitOffset(f.topic, f.partition, req.Group, req.Offset); err != nil {
+		return err
+	}
+	return &api.CommitOffsetResponse{}
+}
+
+func (f *partitionFSM) applyAppend(b []byte) interface{} {
+	var req api.ProduceRequest
+	err := proto.Unmarshal(b, &req)
+	if err != nil {
+		return err
+	}
+	if req.ProducerId != 0 {
+		offsets, err := f.topics.AppendIdempotent(
+			f.topic, f.partition, req.ProducerId, req.Sequence, []*api.Record{req.Record},
+		)
+		if err != nil {
+			return err
+		}
+		return &api.ProduceResponse{Offset: offsets[0], Partition: f.partition}
+	}
+	offset, err := f.topics.Append(f.topic, f.partition, req.Record)
+	if err != nil {
+		return err
+	}
+	return &api.ProduceResponse{Offset: offset, Partition: f.partition}
+}
+
+// バッチのレコードはひとつのエントリに入っているので、一部のレコードだけが複製されることはない。
+func (f *partitionFSM) applyAppendBatch(b []byte) interface{} {
+	var req api.ProduceBatchRequest
+	err := proto.Unmarshal(b, &req)
+	if err != nil {
+		return err
+	}
+	var offsets []uint64
+	if req.ProducerId != 0 {
+		offsets, err = f.topics.AppendIdempotent(f.topic, f.partition, req.ProducerId, req.Sequence, req.Records)
+	} else {
+		offsets, err = f.topics.AppendBatch(f.topic, f.partition, req.Records)
+	}
+	if err != nil {
+		return err
+	}
+	return &api.ProduceBatchResponse{Offsets: offsets, Partition: f.partition}
+}
+
+// パーティションのスナップショットは、先頭のpartitionSnapshotMagicに続けて、SnapshotManifestと、
+// 目録に並べたセグメントのストアとインデックスとタイムインデックスのファイルを、目録のサイズまでそのまま並べたもの。
+//
+//	| manifest size (8) | SnapshotManifest | segment files |
+//
+// スナップショットストアには目録だけを保存して、セグメントのファイルはハードリンクで保持する(snapshot.goを参照)。
+//
+// セグメントのファイルを含める前のスナップショットは先頭がproducersSnapshotMagicで、レコードを1件ずつ追加し直す
+//
+//	| offsets size (8) | CommittedOffsets | producers size (8) | ProducerStates | lowest offset (8) | store frames |
+//
+// で、冪等なプロデューサーを導入する前のスナップショットは先頭がoffsetsSnapshotMagicで、プロデューサーの状態がない。
+// コンシューマーグループを導入する前のスナップショットは、マジックとオフセットのない
+//
+//	| lowest offset (8) | store frames |
+//
+// で、最小のオフセットがマジックと同じ値になることはない。
+const (
+	partitionSnapshotMagic = "proglog\x07"
+	producersSnapshotMagic = "proglog\x05"
+	offsetsSnapshotMagic   = "proglog\x04"
+)
+
+func (f *partitionFSM) Snapshot() (raft.FSMSnapshot, error) {
+	l, producers, err := f.topics.producerStates(f.topic, f.partition)
+	if err != nil {
+		return nil, err
+	}
+	o, err := f.topics.committedOffsets(f.topic, f.partition)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(f.dir, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(f.dir, "")
+	if err != nil {
+		return nil, err
+	}
+	// SnapshotはApplyと同時に呼ばれないので、目録のオフセットとプロデューサーの状態は食い違わない
+	manifest, err := l.linkSegments(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	manifest.CommittedOffsets = o.all()
+	manifest.ProducerStates = producers.all()
+	return &partitionSnapshot{dir: dir, manifest: manifest}, nil
+}
+
+func (f *partitionFSM) Restore(r io.ReadCloser) error {
+	h := make([]byte, lenWidth)
+	var lowest uint64
+	committed := &api.CommittedOffsets{}
+	states := &api.ProducerStates{}
+	if _, err := io.ReadFull(r, h); err == nil {
+		switch magic := string(h); magic {
+		case partitionSnapshotMagic:
+			return f.restoreSegments(r)
+		case producersSnapshotMagic, offsetsSnapshotMagic:
+			if err = readSnapshotMessage(r, committed); err != nil {
+				return err
+			}
+			if magic == producersSnapshotMagic {
+				if err = readSnapshotMessage(r, states); err != nil {
+					return err
+				}
+			}
+			if _, err = io.ReadFull(r, h); err != nil {
+				return err
+			}
+		}
+		lowest = enc.Uint64(h)
+	} else if err != io.EOF {
+		return err
+	}
+	l, err := f.topics.installPartition(f.topic, f.partition, lowest, nil)
+	if err != nil {
+		return err
+	}
+	if err = f.resetStates(committed, states); err != nil {
+		return err
+	}
+	return restoreRecords(l, r)
+}
+
+// 目録に並べたセグメントのファイルをパーティションのディレクトリに書き込んで、ログを置き換える。
+func (f *partitionFSM) restoreSegments(r io.Reader) error {
+	manifest := &api.SnapshotManifest{}
+	if err := readSnapshotMessage(r, manifest); err != nil {
+		return err
+	}
+	l, err := f.topics.installPartition(f.topic, f.partition, manifest.LowestOffset, func(dir string) error {
+		return installSegments(dir, manifest, r)
+	})
+	if err != nil {
+		return err
+	}
+	if next := l.activeSegment.nextOffset; next != manifest.NextOffset {
+		return fmt.Errorf("restored log ends at %d, snapshot ends at %d", next, manifest.NextOffset)
+	}
+	return f.resetStates(manifest.CommittedOffsets, manifest.ProducerStates)
+}
+
+// コミットされたオフセットとプロデューサーの状態をスナップショットのものに置き換える。
+func (f *partitionFSM) resetStates(committed *api.CommittedOffsets, states *api.ProducerStates) error {
+	o, err := f.topics.committedOffsets(f.topic, f.partition)
+	if err != nil {
+		return err
+	}
+	if err = o.reset(committed); err != nil {
+		return err
+	}
+	_, producers, err := f.topics.producerStates(f.topic, f.partition)
+	if err != nil {
+		return err
+	}
+	return producers.reset(states)
+}
+
+// スナップショットにmを長さを付けて書き込む。
+func writeSnapshotMessage(buf *bytes.Buffer, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	h := make([]byte, lenWidth)
+	enc.PutUint64(h, uint64(len(b)))
+	buf.Write(h)
+	buf.Write(b)
+	return nil
+}
+
+// writeSnapshotMessageで書き込んだメッセージをmに読み出す。
+func readSnapshotMessage(r io.Reader, m proto.Message) error {
+	h := make([]byte, lenWidth)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return err
+	}
+	b := make([]byte, enc.Uint64(h))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
@@ -0,0 +1,233 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// プロデューサーの状態を保存するファイル。パーティションのディレクトリに置く
+	producerStatesFile = "producer_states"
+	// プロデューサーごとに覚えておく直近のバッチの数。結果を待たずに送ったバッチをこの数まで再送できる
+	maxProducerBatches = 5
+	// ファイルのエントリがこの数とプロデューサーの数の2倍を超えたら、1エントリに書き直す
+	compactProducerEntries = 1024
+)
+
+// producerStates はパーティションに追加したプロデューサーごとの直近のバッチを保持して、
+// 再送されたバッチを重複して追加しないようにする。追加のたびにファイルに追記するので、再起動しても残る。
+//
+// ファイルには、プロデューサーの状態が変わるたびに、そのプロデューサーの状態だけを次の形式で追記してfsyncする。
+// 同じプロデューサーの状態は後に追記したものが有効になる。
+//
+//	| length (8) | crc32c (4) | ProducerStates |
+//
+// 追記している途中で止まって壊れた末尾のエントリは、開くときに切り詰める。
+// エントリが増えすぎたら、すべてのプロデューサーの状態を1エントリにして、ファイルごと置き換える。
+type producerStates struct {
+	mu        sync.Mutex
+	path      string
+	producers map[uint64]*api.ProducerState
+	// ファイルのエントリの数
+	entries int
+}
+
+// dirのファイルからプロデューサーの状態を読み出す。ファイルがなければ空で始める。
+func openProducerStates(dir string) (*producerStates, error) {
+	p := &producerStates{
+		path:      filepath.Join(dir, producerStatesFile),
+		producers: make(map[uint64]*api.ProducerState),
+	}
+	f, err := os.OpenFile(p.path, os.O_RDWR, 0644)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var size int64
+	for {
+		b, err := readProducerEntry(r)
+		if err == io.EOF {
+			return p, nil
+		} else if err == errCorruptFrame {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var states api.ProducerStates
+		if err = proto.Unmarshal(b, &states); err != nil {
+			break
+		}
+		for id, state := range states.Producers {
+			p.producers[id] = state
+		}
+		p.entries++
+		size += int64(lenWidth + crcWidth + len(b))
+	}
+	// 壊れたエントリから後ろを切り詰める
+	if err = f.Truncate(size); err != nil {
+		return nil, err
+	}
+	if err = f.Sync(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// rから1エントリを読み出す。エントリの境界でrが終わればio.EOFを、壊れていればerrCorruptFrameを返す。
+func readProducerEntry(r io.Reader) ([]byte, error) {
+	h := make([]byte, lenWidth+crcWidth)
+	if _, err := io.ReadFull(r, h); err == io.ErrUnexpectedEOF {
+		return nil, errCorruptFrame
+	} else if err != nil {
+		return nil, err
+	}
+	// 長さ自体が壊れていると巨大なバッファを確保してしまうので、読めた分だけ確保する
+	var b bytes.Buffer
+	size := enc.Uint64(h[:lenWidth])
+	n, err := io.CopyN(&b, r, int64(size))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if uint64(n) != size || crc32.Checksum(b.Bytes(), crcTable) != enc.Uint32(h[lenWidth:]) {
+		return nil, errCorruptFrame
+	}
+	return b.Bytes(), nil
+}
+
+// producerIDのsequenceから始まるrecordsをaddで追加して、そのオフセットを返す。
+// 直近のバッチと同じ番号から始まるものは追加せずに、そのバッチのオフセットを返す。
+// 初めて追加するプロデューサーは任意の番号から始められる。
+func (p *producerStates) append(
+	producerID, sequence uint64,
+	records []*api.Record,
+	add func([]*api.Record) ([]uint64, error),
+) ([]uint64, error) {
+	// 確認してから追加するまでに、同じプロデューサーのバッチが割り込まないようにする
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.producers[producerID]
+	if ok {
+		for _, batch := range state.Batches {
+			if batch.Sequence == sequence {
+				return batch.Offsets, nil
+			}
+		}
+		last := state.Batches[len(state.Batches)-1]
+		if next := last.Sequence + uint64(len(last.Offsets)); sequence != next {
+			return nil, api.ErrOutOfOrderSequence{
+				ProducerID: producerID,
+				Sequence:   sequence,
+				Expected:   next,
+			}
+		}
+	} else {
+		state = &api.ProducerState{}
+	}
+	offsets, err := add(records)
+	if err != nil {
+		return nil, err
+	}
+	p.producers[producerID] = state
+	state.Batches = append(state.Batches, &api.ProducerBatch{Sequence: sequence, Offsets: offsets})
+	if len(state.Batches) > maxProducerBatches {
+		state.Batches = state.Batches[len(state.Batches)-maxProducerBatches:]
+	}
+	// 書き込めなくてもレコードは追加したので、メモリの状態は残して、再送されたバッチにはこのオフセットを返す
+	if err = p.persist(producerID, state); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// スナップショットに含めるために、すべてのプロデューサーの状態のコピーを返す。
+func (p *producerStates) all() *api.ProducerStates {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := &api.ProducerStates{Producers: make(map[uint64]*api.ProducerState, len(p.producers))}
+	for id, state := range p.producers {
+		states.Producers[id] = proto.Clone(state).(*api.ProducerState)
+	}
+	return states
+}
+
+// スナップショットから復元するときに、すべてのプロデューサーの状態を置き換える。
+func (p *producerStates) reset(states *api.ProducerStates) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.producers = make(map[uint64]*api.ProducerState, len(states.GetProducers()))
+	for id, state := range states.GetProducers() {
+		p.producers[id] = state
+	}
+	return p.rewrite()
+}
+
+// producerIDの状態をファイルに追記する。エントリが増えすぎたらファイルを書き直す。p.muを取得してから呼び出す。
+func (p *producerStates) persist(producerID uint64, state *api.ProducerState) error {
+	if p.entries >= compactProducerEntries && p.entries >= 2*len(p.producers) {
+		return p.rewrite()
+	}
+	b, err := marshalProducerEntry(&api.ProducerStates{Producers: map[uint64]*api.ProducerState{producerID: state}})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	p.entries++
+	return f.Close()
+}
+
+// すべてのプロデューサーの状態を1エントリにしてファイルを置き換える。
+// 書きかけのファイルが残らないように、一時ファイルに書き込んでfsyncしてから置き換える。p.muを取得してから呼び出す。
+func (p *producerStates) rewrite() error {
+	b, err := marshalProducerEntry(&api.ProducerStates{Producers: p.producers})
+	if err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err = syncFile(tmp); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, p.path); err != nil {
+		return err
+	}
+	p.entries = 1
+	// 置き換えたこともディスクに残す
+	return syncFile(filepath.Dir(p.path))
+}
+
+func marshalProducerEntry(states *api.ProducerStates) ([]byte, error) {
+	b, err := proto.Marshal(states)
+	if err != nil {
+		return nil, err
+	}
+	entry := make([]byte, lenWidth+crcWidth, lenWidth+crcWidth+len(b))
+	enc.PutUint64(entry[:lenWidth], uint64(len(b)))
+	enc.PutUint32(entry[lenWidth:], crc32.Checksum(b, crcTable))
+	return append(entry, b...), nil
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
+)
+
+// プロデューサーの状態をファイルに追記して、開き直すと復元されることをテストする。
+func TestProducerStates(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, dir string, p *producerStates){
+		"reopen":                      testProducerStatesReopen,
+		"torn tail is truncated":      testProducerStatesTornTail,
+		"entries are compacted":       testProducerStatesCompact,
+		"persist error has no offset": testProducerStatesPersistError,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "producer-states-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			p, err := openProducerStates(dir)
+			require.NoError(t, err)
+			fn(t, dir, p)
+		})
+	}
+}
+
+// 追加するレコードの数だけ、nextから順にオフセットを割り当てる
+func addRecords(next *uint64) func([]*api.Record) ([]uint64, error) {
+	return func(records []*api.Record) ([]uint64, error) {
+		var offsets []uint64
+		for range records {
+			offsets = append(offsets, *next)
+			*next++
+		}
+		return offsets, nil
+	}
+}
+
+func testProducerStatesReopen(t *testing.T, dir string, p *producerStates) {
+	var next uint64
+	records := []*api.Record{{Value: []byte("hello world")}}
+	for sequence := uint64(0); sequence < 3; sequence++ {
+		_, err := p.append(1, sequence, records, addRecords(&next))
+		require.NoError(t, err)
+	}
+	_, err := p.append(2, 0, records, addRecords(&next))
+	require.NoError(t, err)
+
+	p, err = openProducerStates(dir)
+	require.NoError(t, err)
+	require.Equal(t, 4, p.entries)
+	offs, err := p.append(1, 2, records, addRecords(&next))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2}, offs)
+	offs, err = p.append(2, 0, records, addRecords(&next))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, offs)
+}
+
+func testProducerStatesTornTail(t *testing.T, dir string, p *producerStates) {
+	var next uint64
+	records := []*api.Record{{Value: []byte("hello world")}}
+	_, err := p.append(1, 0, records, addRecords(&next))
+	require.NoError(t, err)
+	info, err := os.Stat(p.path)
+	require.NoError(t, err)
+	// 追記している途中で止まった
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 9, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	p, err = openProducerStates(dir)
+	require.NoError(t, err)
+	truncated, err := os.Stat(p.path)
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), truncated.Size())
+	offs, err := p.append(1, 0, records, addRecords(&next))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, offs)
+	// 切り詰めた後に追記したものも読み出せる
+	_, err = p.append(1, 1, records, addRecords(&next))
+	require.NoError(t, err)
+	p, err = openProducerStates(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, p.entries)
+}
+
+func testProducerStatesCompact(t *testing.T, dir string, p *producerStates) {
+	var next uint64
+	records := []*api.Record{{Value: []byte("hello world")}}
+	for sequence := uint64(0); sequence <= compactProducerEntries; sequence++ {
+		_, err := p.append(1, sequence, records, addRecords(&next))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, p.entries)
+
+	p, err := openProducerStates(dir)
+	require.NoError(t, err)
+	offs, err := p.append(1, compactProducerEntries, records, addRecords(&next))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{compactProducerEntries}, offs)
+	_, err = p.append(1, compactProducerEntries-maxProducerBatches, records, addRecords(&next))
+	require.IsType(t, api.ErrOutOfOrderSequence{}, err)
+}
+
+func testProducerStatesPersistError(t *testing.T, dir string, p *producerStates) {
+	var next uint64
+	records := []*api.Record{{Value: []byte("hello world")}}
+	p.path = filepath.Join(dir, "missing", producerStatesFile)
+	offs, err := p.append(1, 0, records, addRecords(&next))
+	require.Error(t, err)
+	require.Nil(t, offs)
+	// レコードは追加したので、再送されたバッチは追加せずにそのオフセットを返す
+	offs, err = p.append(1, 0, records, addRecords(&next))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, offs)
+	require.Equal(t, uint64(1), next)
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -12,8 +14,16 @@ import (
 type segment struct {
 	store                  *store
 	index                  *index
+	timeIndex              *timeIndex
 	baseOffset, nextOffset uint64
 	config                 Config
+
+	// セグメント内のレコードのタイムスタンプの最大値(UnixNano)と、それを持つレコードの相対オフセット。
+	// タイムインデックスにまだ書き込んでいなければセグメントを閉じるときに書き込む。
+	maxTimestamp       int64
+	maxTimestampOffset uint32
+	// 最後にタイムインデックスのエントリを書き込んだときのストアの位置
+	timeIndexPos uint64
 }
 
 // アクティブセグメントが最大サイズに達したときなど、あら棚セグメントを追加する必要がある時に呼ばれる。
@@ -30,7 +40,7 @@ func newSegment(dir string, baseOffset uint64, c Config) (*segment, error) {
 	if err != nil {
 		return nil, err
 	}
-	if s.store, err = newStore(storeFile); err != nil {
+	if s.store, err = newStore(storeFile, c); err != nil {
 		return nil, err
 	}
 	indexFile, err := os.OpenFile( // indexファイルを開く
@@ -44,6 +54,23 @@ func newSegment(dir string, baseOffset uint64, c Config) (*segment, error) {
 	if s.index, err = newIndex(indexFile, c); err != nil {
 		return nil, err
 	}
+	timeIndexFile, err := os.OpenFile( // timeindexファイルを開く
+		filepath.Join(dir, fmt.Sprintf("%d%s", baseOffset, ".timeindex")),
+		os.O_RDWR|os.O_CREATE|os.O_APPEND,
+		0600,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if s.timeIndex, err = newTimeIndex(timeIndexFile); err != nil {
+		return nil, err
+	}
+	// セグメントを閉じるときに最大のタイムスタンプを書き込んでいるので、最後のエントリが最大値になる。
+	// アクティブセグメントはクラッシュしている可能性があるが、起動時のリカバリで作り直される。
+	if ts, off, err := s.timeIndex.Last(); err == nil {
+		s.maxTimestamp, s.maxTimestampOffset = ts, off
+		s.timeIndexPos = s.store.size
+	}
 	// セグメントの次のオフセットを設定して、次に追加されるレコードのための準備をする。
 	if off, _, err := s.index.Read(-1); err != nil { // -1は最後のエントリを表す。最後のエントリを読み出して、そのオフセットを取得する。
 		// index空のパターン。セグメントに追加される次のレコードが最初のレコードとなり、そのオフセットはセグメントのベースオフセットになる
@@ -60,45 +87,452 @@ func newSegment(dir string, baseOffset uint64, c Config) (*segment, error) {
 func (s *segment) Append(record *api.Record) (offset uint64, err error) {
 	cur := s.nextOffset
 	record.Offset = cur
+	if err = s.write(record); err != nil {
+		return 0, err
+	}
+	return cur, nil
+}
+
+// 複数のレコードを1つのフレームにまとめてセグメントに書き込み、それぞれのオフセットを返す。
+// 圧縮はフレームごとに行うので、似たレコードが並ぶバッチはまとめて圧縮したほうがよく縮む。
+// バッチのレコードのインデックスのエントリは、どれもバッチのフレームの位置を指す。
+func (s *segment) AppendBatch(records []*api.Record) ([]uint64, error) {
+	if len(records) == 1 {
+		off, err := s.Append(records[0])
+		if err != nil {
+			return nil, err
+		}
+		return []uint64{off}, nil
+	}
+	var b []byte
+	offs := make([]uint64, 0, len(records))
+	for _, record := range records {
+		record.Offset = s.nextOffset + uint64(len(offs))
+		p, err := proto.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		b = enc.AppendUint64(b, uint64(len(p)))
+		b = append(b, p...)
+		offs = append(offs, record.Offset)
+	}
+	_, pos, err := s.store.AppendBatch(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err = s.index.Write(uint32(record.Offset-s.baseOffset), pos); err != nil {
+			return nil, err
+		}
+		if err = s.indexTime(record, pos); err != nil {
+			return nil, err
+		}
+	}
+	s.nextOffset = offs[len(offs)-1] + 1
+	return offs, nil
+}
+
+// インデックスにあと何件のエントリを書き込めるかを返す。バッチを1つのセグメントに収まるように分けるために使う。
+func (s *segment) room() int {
+	if s.IsMaxed() {
+		return 0
+	}
+	return int((uint64(len(s.index.mmap)) - s.index.size) / entWidth)
+}
+
+// フレームのpayloadに含まれるレコードを返す。バッチのフレームでなければ1件だけ返す。
+func decodeRecords(p []byte, attrs byte) ([]*api.Record, error) {
+	if attrs&attrBatch == 0 {
+		record := &api.Record{}
+		if err := proto.Unmarshal(p, record); err != nil {
+			return nil, err
+		}
+		return []*api.Record{record}, nil
+	}
+	var records []*api.Record
+	for len(p) > 0 {
+		if len(p) < lenWidth {
+			return nil, errCorruptFrame
+		}
+		size := enc.Uint64(p[:lenWidth])
+		if size > uint64(len(p)-lenWidth) {
+			return nil, errCorruptFrame
+		}
+		record := &api.Record{}
+		if err := proto.Unmarshal(p[lenWidth:lenWidth+size], record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		p = p[lenWidth+size:]
+	}
+	return records, nil
+}
+
+// レコードをそのオフセットのままセグメントに書き込む。
+// 圧縮でレコードを間引いたセグメントを作り直すときは、オフセットが飛び飛びになる。
+func (s *segment) write(record *api.Record) error {
 	p, err := proto.Marshal(record)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	// データをストアに追加
-	// TODO: インデックスエントリ追加に失敗した場合storeで追加したレコードはゴミとしての残る。
+	// インデックスエントリ追加に失敗した場合storeで追加したレコードはゴミとして残るが、次回起動時のリカバリで切り詰められる。
 	_, pos, err := s.store.Append(p)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	// インデックスエントリを追加
 	if err = s.index.Write(
 		// インデックスのオフセットは、ベースオフセットからの相対的なものなので
-		// セグメントのnextOffsetからbaseOffset(どちらも絶対オフセット)を引いて、セグメント内のエントリの相対オフセットを求める。
-		uint32(s.nextOffset-uint64(s.baseOffset)),
+		// レコードのオフセットからbaseOffset(どちらも絶対オフセット)を引いて、セグメント内のエントリの相対オフセットを求める。
+		uint32(record.Offset-s.baseOffset),
 		pos,
 	); err != nil {
-		return 0, err
+		return err
 	}
-	// netxOffsetに1を加算し、将来のAppendメソッドの呼び出しに備える
-	s.nextOffset++
-	return cur, nil
+	if err = s.indexTime(record, pos); err != nil {
+		return err
+	}
+	// netxOffsetを更新し、将来のAppendメソッドの呼び出しに備える
+	s.nextOffset = record.Offset + 1
+	return nil
 }
 
 // 指定されたオフセットのレコードを返す。
+// セグメントの範囲内でも圧縮で削除済みのオフセットならapi.ErrCompactedを返す。
 func (s *segment) Read(off uint64) (*api.Record, error) {
 	// 絶対オフセットを相対オフセットに変換しインデックスエントリの内容を取得する
-	_, pos, err := s.index.Read(int64(off - s.baseOffset))
+	pos, err := s.index.Find(uint32(off - s.baseOffset))
+	if err == io.EOF && s.baseOffset <= off && off < s.nextOffset {
+		return nil, api.ErrCompacted{Offset: off}
+	}
 	if err != nil {
 		return nil, err
 	}
 	// インデックスエントリから位置を取得するとセグメントはストア内のレコードの位置から適切な量のデータを読み出せる。
-	p, err := s.store.Read(pos)
+	p, attrs, err := s.store.Read(pos)
+	if err != nil {
+		if err == errCorruptFrame {
+			return nil, api.ErrCorruptRecord{Offset: off}
+		}
+		return nil, err
+	}
+	records, err := decodeRecords(p, attrs)
 	if err != nil {
+		if err == errCorruptFrame {
+			return nil, api.ErrCorruptRecord{Offset: off}
+		}
 		return nil, err
 	}
+	// バッチのフレームなら、その中からオフセットが一致するレコードを探す
+	for _, record := range records {
+		if record.Offset == off {
+			return record, nil
+		}
+	}
+	return nil, api.ErrCorruptRecord{Offset: off}
+}
+
+// セグメント内のレコードをオフセットの順にfnに渡す。圧縮で削除済みのオフセットは飛ばす。
+func (s *segment) each(fn func(record *api.Record) error) error {
+	var last uint64
+	for i := int64(0); ; i++ {
+		_, pos, err := s.index.Read(i)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// バッチのレコードは同じフレームを指すので、フレームごとに1回だけ読む
+		if i > 0 && pos == last {
+			continue
+		}
+		last = pos
+		p, attrs, err := s.store.Read(pos)
+		if err != nil {
+			return err
+		}
+		records, err := decodeRecords(p, attrs)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err = fn(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// レコードのタイムスタンプで最大値を更新し、前回のエントリからTimeIndexIntervalBytes以上
+// ストアに書き込まれていればタイムインデックスにエントリを追加する。
+func (s *segment) indexTime(record *api.Record, pos uint64) error {
+	if record.Timestamp == nil {
+		return nil
+	}
+	ts := record.Timestamp.AsTime().UnixNano()
+	if ts <= s.maxTimestamp {
+		return nil
+	}
+	s.maxTimestamp = ts
+	s.maxTimestampOffset = uint32(record.Offset - s.baseOffset)
+	if len(s.timeIndex.entries) > 0 && pos-s.timeIndexPos < s.config.Segment.TimeIndexIntervalBytes {
+		return nil
+	}
+	s.timeIndexPos = pos
+	return s.timeIndex.Write(s.maxTimestamp, s.maxTimestampOffset)
+}
+
+// まだタイムインデックスに書き込んでいない最大のタイムスタンプを書き込む。
+// セグメントを閉じるときと、アクティブセグメントでなくなるときに呼び出す。
+func (s *segment) flushTimeIndex() error {
+	if s.maxTimestamp == 0 {
+		return nil
+	}
+	if ts, _, err := s.timeIndex.Last(); err == nil && ts >= s.maxTimestamp {
+		return nil
+	}
+	s.timeIndexPos = s.store.Size()
+	return s.timeIndex.Write(s.maxTimestamp, s.maxTimestampOffset)
+}
+
+// タイムスタンプがts(UnixNano)以上の最初のレコードのオフセットを返す。
+// セグメント内にそのようなレコードがなければfalseを返す。
+func (s *segment) OffsetForTime(ts int64) (uint64, bool, error) {
+	if s.maxTimestamp < ts {
+		return 0, false, nil
+	}
+	for off := s.baseOffset + uint64(s.timeIndex.Lookup(ts)); off < s.nextOffset; off++ {
+		record, err := s.Read(off)
+		if _, ok := err.(api.ErrCompacted); ok {
+			continue
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if record.Timestamp != nil && record.Timestamp.AsTime().UnixNano() >= ts {
+			return off, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// ストアとインデックスの整合性が取れているかを確認する。
+// インデックスの最後のエントリが指すレコードが壊れておらず、ちょうどストアの末尾で終わっていれば整合性が取れているとみなす。
+func (s *segment) isConsistent() bool {
+	if s.index.size%entWidth != 0 {
+		return false
+	}
+	last, pos, err := s.index.Read(-1)
+	if err != nil {
+		// インデックスが空ならストアも空でなければならない
+		return s.store.size == 0
+	}
+	// クラッシュするとインデックスファイルはMaxIndexBytesまでゼロ埋めされたまま残るので、
+	// 最後の2つのエントリが昇順になっているかも確認する。
+	if n := s.index.size / entWidth; n >= 2 {
+		prev, prevPos, err := s.index.Read(int64(n - 2))
+		// バッチのレコードは同じ位置を指す
+		if err != nil || prev >= last || prevPos > pos {
+			return false
+		}
+	}
+	r := io.NewSectionReader(s.store, int64(pos), int64(s.store.size-pos))
+	p, attrs, n, err := readFrame(r, s.config.Segment.Keyring)
+	if err != nil || pos+n != s.store.size {
+		return false
+	}
+	// バッチのインデックスのエントリを書き込んでいる途中でクラッシュしていないか
+	records, err := decodeRecords(p, attrs)
+	return err == nil && records[len(records)-1].Offset == s.baseOffset+uint64(last)
+}
+
+// ストアを先頭から読み直して、壊れていない最後のレコードまでインデックスを作り直し、
+// それ以降の書き込み途中のゴミをストアから切り詰める。切り詰めたバイト数を返す。
+// 切り詰めるのは書き込み途中で止まった末尾だけで、壊れたレコードの後ろに壊れていないフレームが残っている場合や、
+// チェックサムは合うのにレコードとして読めない場合は、後ろのレコードを失わないようにapi.ErrCorruptRecordを返す。
+func (s *segment) recover() (truncated uint64, err error) {
+	if err = s.index.Reset(); err != nil {
+		return 0, err
+	}
+	if err = s.timeIndex.Reset(); err != nil {
+		return 0, err
+	}
+	s.maxTimestamp, s.maxTimestampOffset, s.timeIndexPos = 0, 0, 0
+	s.nextOffset = s.baseOffset
+	var pos uint64
+	r := io.NewSectionReader(s.store, 0, int64(s.store.size))
+	for pos < s.store.size {
+		p, attrs, n, err := readFrame(r, s.config.Segment.Keyring)
+		if err == io.EOF {
+			break
+		}
+		if err == errCorruptFrame {
+			torn, err := s.tornTail(pos)
+			if err != nil {
+				return 0, err
+			}
+			if !torn {
+				return 0, api.ErrCorruptRecord{Offset: s.nextOffset}
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		records, err := decodeRecords(p, attrs)
+		if err != nil || len(records) == 0 {
+			return 0, api.ErrCorruptRecord{Offset: s.nextOffset}
+		}
+		for _, record := range records {
+			if record.Offset < s.nextOffset {
+				return 0, api.ErrCorruptRecord{Offset: s.nextOffset}
+			}
+			if err = s.index.Write(uint32(record.Offset-s.baseOffset), pos); err != nil {
+				return 0, err
+			}
+			if err = s.indexTime(record, pos); err != nil {
+				return 0, err
+			}
+			s.nextOffset = record.Offset + 1
+		}
+		pos += n
+	}
+	truncated = s.store.size - pos
+	if truncated > 0 {
+		if err = s.store.Truncate(pos); err != nil {
+			return 0, err
+		}
+	}
+	return truncated, s.flushTimeIndex()
+}
+
+// ストアのposより後ろが書き込み途中で止まったフレームだけかを返す。
+// posより後ろのどこかから壊れていないフレームが読めれば、途中のレコードが壊れているとみなす。
+func (s *segment) tornTail(pos uint64) (bool, error) {
+	b := make([]byte, s.store.size-pos)
+	if _, err := s.store.ReadAt(b, int64(pos)); err != nil {
+		return false, err
+	}
+	for i := 1; i+headerWidth-attrWidth <= len(b); i++ {
+		h := b[i:]
+		width := headerWidth
+		switch h[lenWidth+crcWidth] {
+		case frameVersion1:
+			width = headerWidth - attrWidth
+		case frameVersion2:
+		default:
+			continue
+		}
+		size := enc.Uint64(h[:lenWidth])
+		if uint64(width) > uint64(len(h)) || size > uint64(len(h)-width) {
+			continue
+		}
+		if frameChecksum(h[lenWidth+crcWidth:width], h[width:uint64(width)+size]) == enc.Uint32(h[lenWidth:lenWidth+crcWidth]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ストアがチェックサムのないフレーム(| length (8) | Record |)で書かれていれば、今のフレームに書き直す。
+// フレームにチェックサムを入れる前のログを開いたときに、壊れたレコードとして切り詰めないようにする。
+// インデックスとタイムインデックスは削除して、開いたときのリカバリで作り直す。書き直したらtrueを返す。
+func migrateLegacySegment(dir string, baseOffset uint64, c Config) (bool, error) {
+	name := filepath.Join(dir, segmentFileName(baseOffset, ".store"))
+	b, err := os.ReadFile(name)
+	if err != nil || !isLegacyStore(b, baseOffset) {
+		return false, err
+	}
+	tmp, err := os.OpenFile(name+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return false, err
+	}
+	s, err := newStore(tmp, c)
+	if err != nil {
+		tmp.Close()
+		return false, err
+	}
+	// 途中で途切れたレコードは書き直さない
+	for pos := uint64(0); pos+lenWidth <= uint64(len(b)); {
+		size := enc.Uint64(b[pos : pos+lenWidth])
+		if size > uint64(len(b))-pos-lenWidth {
+			break
+		}
+		if _, _, err = s.Append(b[pos+lenWidth : pos+lenWidth+size]); err != nil {
+			s.Close()
+			return false, err
+		}
+		pos += lenWidth + size
+	}
+	if err = s.Close(); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return false, err
+	}
+	for _, ext := range []string{".index", ".timeindex"} {
+		if err = os.Remove(filepath.Join(dir, segmentFileName(baseOffset, ext))); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return true, syncFile(dir)
+}
+
+// bがチェックサムのないフレームで書かれたストアかを返す。先頭が今のフレームとして読めず、
+// チェックサムのないフレームとして読むとbaseOffsetのレコードになっていれば、そうだとみなす。
+func isLegacyStore(b []byte, baseOffset uint64) bool {
+	if len(b) < lenWidth {
+		return false
+	}
+	if _, _, _, err := readFrame(bytes.NewReader(b), nil); err == nil || err == errNoKeyring {
+		return false
+	}
+	size := enc.Uint64(b[:lenWidth])
+	if size > uint64(len(b))-lenWidth {
+		return false
+	}
 	record := &api.Record{}
-	err = proto.Unmarshal(p, record)
-	return record, err
+	if err := proto.Unmarshal(b[lenWidth:lenWidth+size], record); err != nil {
+		return false
+	}
+	return record.Offset == baseOffset
+}
+
+// segmentMark はセグメントに追加する前の状態で、追加に失敗したときにrollbackで戻す。
+type segmentMark struct {
+	storeSize, indexSize, timeIndexPos, nextOffset uint64
+	timeEntries                                    int
+	maxTimestamp                                   int64
+	maxTimestampOffset                             uint32
+}
+
+func (s *segment) mark() segmentMark {
+	return segmentMark{
+		storeSize:          s.store.Size(),
+		indexSize:          s.index.size,
+		timeIndexPos:       s.timeIndexPos,
+		nextOffset:         s.nextOffset,
+		timeEntries:        len(s.timeIndex.entries),
+		maxTimestamp:       s.maxTimestamp,
+		maxTimestampOffset: s.maxTimestampOffset,
+	}
+}
+
+// markを取った後に追加したレコードを取り除く。ストアは追加した分だけ切り詰めるので、
+// markより前に取ったスナップショットがリンクしている部分は変わらない。
+func (s *segment) rollback(m segmentMark) error {
+	if err := s.store.Truncate(m.storeSize); err != nil {
+		return err
+	}
+	if err := s.timeIndex.truncate(m.timeEntries); err != nil {
+		return err
+	}
+	s.index.size = m.indexSize
+	s.timeIndexPos, s.nextOffset = m.timeIndexPos, m.nextOffset
+	s.maxTimestamp, s.maxTimestampOffset = m.maxTimestamp, m.maxTimestampOffset
+	return nil
 }
 
 // ストアまたはインデックスへの書き込みが一杯になったかどうかでセグメントが最大サイズに達したか判断する
@@ -121,10 +555,19 @@ func (s *segment) Remove() error {
 	if err := os.Remove(s.store.Name()); err != nil {
 		return err
 	}
+	if err := os.Remove(s.timeIndex.Name()); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (s *segment) Close() error {
+	if err := s.flushTimeIndex(); err != nil {
+		return err
+	}
+	if err := s.timeIndex.Close(); err != nil {
+		return err
+	}
 	if err := s.index.Close(); err != nil {
 		return err
 	}
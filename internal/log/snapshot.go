@@ -0,0 +1,366 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/raft"
+	api "github.com/yurakawa/proglog/api/v1"
+)
+
+// スナップショットのセグメントのファイルを参照する形式の先頭。スナップショットストアのファイルにだけ書き込み、
+// Openで目録とセグメントのファイルを並べたpartitionSnapshotMagicの形式に展開する。
+const referencedSnapshotMagic = "proglog\x08"
+
+// スナップショットを取るときにセグメントのファイルをハードリンクするディレクトリ。Raftのグループのディレクトリに置く
+const snapshotStagingDir = "snapshot-staging"
+
+// スナップショットに含めるセグメントのファイルをdirにハードリンクして、目録を返す。
+// セグメントのファイルは追記するか、新しいファイルに置き換えるだけなので(replaceFileを参照)、
+// リンクしたファイルの目録のサイズまでは変わらない。
+// ログ全体をコピーしないので、セグメントの数に比例する時間で終わる。
+func (l *Log) linkSegments(dir string) (*api.SnapshotManifest, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	manifest := &api.SnapshotManifest{
+		LowestOffset: l.segments[0].baseOffset,
+		NextOffset:   l.activeSegment.nextOffset,
+	}
+	for _, s := range l.segments {
+		// バッファに残っているレコードもリンクしたファイルから読めるようにする
+		storeSize, err := s.store.Flush()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{s.store.Name(), s.index.Name(), s.timeIndex.Name()} {
+			if err := linkFile(name, filepath.Join(dir, filepath.Base(name))); err != nil {
+				return nil, err
+			}
+		}
+		manifest.Segments = append(manifest.Segments, &api.SnapshotSegment{
+			BaseOffset:    s.baseOffset,
+			StoreSize:     storeSize,
+			IndexSize:     s.index.size,
+			TimeIndexSize: uint64(len(s.timeIndex.entries)) * timeEntWidth,
+		})
+	}
+	return manifest, nil
+}
+
+// ハードリンクを作る。別のファイルシステムなどでリンクできなければコピーする。
+func linkFile(oldname, newname string) error {
+	if err := os.Link(oldname, newname); err == nil {
+		return nil
+	}
+	src, err := os.Open(oldname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(newname)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// fと同じ名前の空の新しいファイルを作ってflagで開く。fのファイルはその場で書き換えずに置き換えるので、
+// スナップショットがハードリンクしているファイルは変わらない。fは呼び出し元で閉じる。
+func replaceFile(f *os.File, flag int) (*os.File, error) {
+	tmp := f.Name() + ".tmp"
+	if err := os.WriteFile(tmp, nil, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, f.Name()); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(f.Name(), flag, 0600)
+}
+
+// 目録のセグメントのファイルの名前と、含めるサイズ
+func segmentFiles(s *api.SnapshotSegment) []struct {
+	name string
+	size uint64
+} {
+	return []struct {
+		name string
+		size uint64
+	}{
+		{segmentFileName(s.BaseOffset, ".store"), s.StoreSize},
+		{segmentFileName(s.BaseOffset, ".index"), s.IndexSize},
+		{segmentFileName(s.BaseOffset, ".timeindex"), s.TimeIndexSize},
+	}
+}
+
+func segmentFileName(baseOffset uint64, ext string) string {
+	return fmt.Sprintf("%d%s", baseOffset, ext)
+}
+
+// rから目録のセグメントのファイルを読み出して、dirにそのまま書き込む。
+// レコードを1件ずつ追加し直さないので、インデックスを作り直す必要もない。
+func installSegments(dir string, manifest *api.SnapshotManifest, r io.Reader) error {
+	for _, s := range manifest.Segments {
+		for _, file := range segmentFiles(s) {
+			f, err := os.OpenFile(filepath.Join(dir, file.name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err = io.CopyN(f, r, int64(file.size)); err != nil {
+				f.Close()
+				return err
+			}
+			if err = f.Sync(); err != nil {
+				f.Close()
+				return err
+			}
+			if err = f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var _ raft.FSMSnapshot = (*partitionSnapshot)(nil)
+
+// partitionSnapshot はセグメントのファイルをハードリンクしたディレクトリと、その目録を持つスナップショット。
+type partitionSnapshot struct {
+	dir      string
+	manifest *api.SnapshotManifest
+}
+
+// snapshotStoreのシンクには目録だけを書き込み、セグメントのファイルはハードリンクのままスナップショットと一緒に保存する。
+// それ以外のシンクには目録に続けてセグメントのファイルを書き込む。
+func (s *partitionSnapshot) Persist(sink raft.SnapshotSink) error {
+	var err error
+	if ss, ok := sink.(*snapshotSink); ok {
+		err = s.persistReferenced(ss)
+	} else {
+		err = s.persistInline(sink)
+	}
+	if err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *partitionSnapshot) persistReferenced(sink *snapshotSink) error {
+	// スナップショットが保存されたときには、参照するファイルも永続化されていなければならない
+	for _, segment := range s.manifest.Segments {
+		for _, file := range segmentFiles(segment) {
+			if err := syncFile(filepath.Join(s.dir, file.name)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(sink.dir), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(s.dir, sink.dir); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(referencedSnapshotMagic)
+	if err := writeSnapshotMessage(&buf, s.manifest); err != nil {
+		return err
+	}
+	_, err := io.Copy(sink, &buf)
+	return err
+}
+
+func (s *partitionSnapshot) persistInline(sink raft.SnapshotSink) error {
+	r, closer, _, err := expandSnapshot(s.dir, s.manifest)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	_, err = io.Copy(sink, r)
+	return err
+}
+
+// Persistでシンクに移していなければ、ハードリンクを削除する。
+func (s *partitionSnapshot) Release() {
+	_ = os.RemoveAll(s.dir)
+}
+
+func syncFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dirのセグメントのファイルを目録のサイズまで並べた、partitionSnapshotMagicの形式のスナップショットを返す。
+// ファイルはすべて開いてから返すので、その後にdirが削除されても読み出せる。
+func expandSnapshot(dir string, manifest *api.SnapshotManifest) (io.Reader, func(), int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString(partitionSnapshotMagic)
+	if err := writeSnapshotMessage(&buf, manifest); err != nil {
+		return nil, nil, 0, err
+	}
+	size := int64(buf.Len())
+	readers := []io.Reader{&buf}
+	var files []*os.File
+	closer := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for _, segment := range manifest.Segments {
+		for _, file := range segmentFiles(segment) {
+			f, err := os.Open(filepath.Join(dir, file.name))
+			if err != nil {
+				closer()
+				return nil, nil, 0, err
+			}
+			files = append(files, f)
+			readers = append(readers, io.NewSectionReader(f, 0, int64(file.size)))
+			size += int64(file.size)
+		}
+	}
+	return io.MultiReader(readers...), closer, size, nil
+}
+
+var _ raft.SnapshotStore = (*snapshotStore)(nil)
+
+// snapshotStore はパーティションのスナップショットのセグメントのファイルを、ハードリンクのまま保存するスナップショットストア。
+// スナップショットの本体は目録だけなので、ログが大きくなってもスナップショットのためにディスクを使わない。
+// スナップショットの数と、スナップショットを取る間隔はConfig.Raftで設定する。
+type snapshotStore struct {
+	*raft.FileSnapshotStore
+	// スナップショットごとのセグメントのファイルを、スナップショットのIDのディレクトリに置く
+	segmentsDir string
+}
+
+func newSnapshotStore(dir string, retain int) (*snapshotStore, error) {
+	store, err := raft.NewFileSnapshotStore(dir, retain, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	s := &snapshotStore{
+		FileSnapshotStore: store,
+		segmentsDir:       filepath.Join(dir, "snapshot-segments"),
+	}
+	// 保存が終わる前に止まったスナップショットのファイルを消す
+	return s, s.prune()
+}
+
+func (s *snapshotStore) Create(
+	version raft.SnapshotVersion,
+	index, term uint64,
+	configuration raft.Configuration,
+	configurationIndex uint64,
+	trans raft.Transport,
+) (raft.SnapshotSink, error) {
+	sink, err := s.FileSnapshotStore.Create(version, index, term, configuration, configurationIndex, trans)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotSink{
+		SnapshotSink: sink,
+		store:        s,
+		dir:          filepath.Join(s.segmentsDir, sink.ID()),
+	}, nil
+}
+
+// Open は参照する形式のスナップショットを、セグメントのファイルを含めた形式に展開して返す。
+// ほかのノードに送るときも、このノードで復元するときも展開したものを使う。
+func (s *snapshotStore) Open(id string) (*raft.SnapshotMeta, io.ReadCloser, error) {
+	meta, rc, err := s.FileSnapshotStore.Open(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	magic := make([]byte, len(referencedSnapshotMagic))
+	n, err := io.ReadFull(rc, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		rc.Close()
+		return nil, nil, err
+	}
+	if string(magic[:n]) != referencedSnapshotMagic {
+		return meta, &readCloser{
+			Reader: io.MultiReader(bytes.NewReader(magic[:n]), rc),
+			close:  rc.Close,
+		}, nil
+	}
+	defer rc.Close()
+	manifest := &api.SnapshotManifest{}
+	if err = readSnapshotMessage(rc, manifest); err != nil {
+		return nil, nil, err
+	}
+	r, closer, size, err := expandSnapshot(filepath.Join(s.segmentsDir, id), manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta.Size = size
+	return meta, &readCloser{Reader: r, close: func() error { closer(); return nil }}, nil
+}
+
+// 保持しているスナップショットのどれにも属さないセグメントのファイルを削除する。
+func (s *snapshotStore) prune() error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		keep[snapshot.ID] = true
+	}
+	entries, err := os.ReadDir(s.segmentsDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if keep[entry.Name()] {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(s.segmentsDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotSink はスナップショットのセグメントのファイルを置くディレクトリを持つシンク。
+type snapshotSink struct {
+	raft.SnapshotSink
+	store *snapshotStore
+	dir   string
+}
+
+// 保存したら、保持する数を超えて削除されたスナップショットのセグメントのファイルも削除する。
+func (s *snapshotSink) Close() error {
+	if err := s.SnapshotSink.Close(); err != nil {
+		_ = os.RemoveAll(s.dir)
+		return err
+	}
+	return s.store.prune()
+}
+
+func (s *snapshotSink) Cancel() error {
+	_ = os.RemoveAll(s.dir)
+	return s.SnapshotSink.Cancel()
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r *readCloser) Close() error {
+	return r.close()
+}
@@ -0,0 +1,210 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// スナップショットストアにはセグメントのファイルを含めずに目録だけを保存することと、
+// 開いたスナップショットからスナップショットを取ったときのログを復元できることをテストする。
+func TestSnapshotStore(t *testing.T) {
+	for scenario, configure := range map[string]func(t *testing.T, c *Config){
+		"plain": func(t *testing.T, c *Config) {},
+		"compressed and encrypted": func(t *testing.T, c *Config) {
+			k, err := NewKeyring(1, map[uint32][]byte{1: []byte("0123456789abcdef")})
+			require.NoError(t, err)
+			c.Segment.Keyring = k
+			c.Segment.Compression = CompressionGzip
+		},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "snapshot-store-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.MaxIndexBytes = entWidth * 3
+			configure(t, &c)
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "topics"), 0755))
+			topics, err := NewTopics(filepath.Join(dir, "topics"), c)
+			require.NoError(t, err)
+			defer topics.Close()
+			for i := 0; i < 10; i++ {
+				_, err = topics.Append(DefaultTopic, 0, &api.Record{Value: []byte("hello world")})
+				require.NoError(t, err)
+			}
+			require.NoError(t, topics.CommitOffset(DefaultTopic, 0, "billing", 7))
+
+			store, err := newSnapshotStore(filepath.Join(dir, "raft"), 1)
+			require.NoError(t, err)
+			f := &partitionFSM{topics: topics, topic: DefaultTopic, dir: filepath.Join(dir, "raft", snapshotStagingDir)}
+			first := persistToStore(t, store, f, 1)
+			require.DirExists(t, filepath.Join(store.segmentsDir, first))
+			snapshots, err := store.List()
+			require.NoError(t, err)
+			require.Equal(t, 1, len(snapshots))
+			// スナップショットの本体は目録だけ
+			l, err := topics.Log(DefaultTopic, 0)
+			require.NoError(t, err)
+			records, err := io.ReadAll(l.Reader())
+			require.NoError(t, err)
+			require.Less(t, snapshots[0].Size, int64(len(records)))
+			entries, err := os.ReadDir(f.dir)
+			require.NoError(t, err)
+			require.Equal(t, 0, len(entries))
+
+			// スナップショットを取った後に追加したレコードは含まれない
+			for i := 0; i < 2; i++ {
+				_, err = topics.Append(DefaultTopic, 0, &api.Record{Value: []byte("later")})
+				require.NoError(t, err)
+			}
+			meta, rc, err := store.Open(first)
+			require.NoError(t, err)
+			b, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			require.Equal(t, meta.Size, int64(len(b)))
+
+			restoreDir, err := os.MkdirTemp("", "snapshot-store-restore-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(restoreDir)
+			restored, err := NewTopics(restoreDir, c)
+			require.NoError(t, err)
+			defer restored.Close()
+			_, err = restored.Append(DefaultTopic, 0, &api.Record{Value: []byte("stale")})
+			require.NoError(t, err)
+			require.NoError(t, (&partitionFSM{topics: restored, topic: DefaultTopic}).Restore(io.NopCloser(bytes.NewReader(b))))
+			for off := uint64(0); off < 10; off++ {
+				read, err := restored.Read(DefaultTopic, 0, off)
+				require.NoError(t, err)
+				require.Equal(t, []byte("hello world"), read.Value)
+			}
+			_, err = restored.Read(DefaultTopic, 0, 10)
+			require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+			off, err := restored.FetchCommittedOffset(DefaultTopic, 0, "billing")
+			require.NoError(t, err)
+			require.Equal(t, uint64(7), off)
+			// 復元したログにそのまま追加できる
+			off, err = restored.Append(DefaultTopic, 0, &api.Record{Value: []byte("next")})
+			require.NoError(t, err)
+			require.Equal(t, uint64(10), off)
+
+			// 保持する数を超えたスナップショットのセグメントのファイルは削除される
+			second := persistToStore(t, store, f, 2)
+			require.NoDirExists(t, filepath.Join(store.segmentsDir, first))
+			require.DirExists(t, filepath.Join(store.segmentsDir, second))
+
+			// 保存し終える前に止まったスナップショットのファイルは開き直したときに削除される
+			stray := filepath.Join(store.segmentsDir, "stray")
+			require.NoError(t, os.MkdirAll(stray, 0755))
+			store, err = newSnapshotStore(filepath.Join(dir, "raft"), 1)
+			require.NoError(t, err)
+			require.NoDirExists(t, stray)
+			require.DirExists(t, filepath.Join(store.segmentsDir, second))
+		})
+	}
+}
+
+// スナップショットを取った後にログを開き直して、アクティブセグメントのインデックスが作り直されても、
+// スナップショットがリンクしているファイルは変わらずに、スナップショットから復元できることをテストする。
+func TestSnapshotAfterReopen(t *testing.T) {
+	dir, err := os.MkdirTemp("", "snapshot-reopen-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// レコードごとにタイムインデックスのエントリを書き込む
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 10
+	c.Segment.TimeIndexIntervalBytes = 1
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "topics"), 0755))
+	topics, err := NewTopics(filepath.Join(dir, "topics"), c)
+	require.NoError(t, err)
+	base := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = topics.Append(DefaultTopic, 0, &api.Record{
+			Value:     []byte("hello world"),
+			Timestamp: timestamppb.New(base.Add(time.Duration(i) * time.Second)),
+		})
+		require.NoError(t, err)
+	}
+	store, err := newSnapshotStore(filepath.Join(dir, "raft"), 1)
+	require.NoError(t, err)
+	f := &partitionFSM{topics: topics, topic: DefaultTopic, dir: filepath.Join(dir, "raft", snapshotStagingDir)}
+	id := persistToStore(t, store, f, 1)
+	open := func() []byte {
+		_, rc, err := store.Open(id)
+		require.NoError(t, err)
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		return b
+	}
+	before := open()
+
+	// 開き直すとアクティブセグメントのインデックスを作り直す。
+	// 間隔を変えたので、タイムインデックスのエントリは最初と最後のレコードの分だけになる
+	require.NoError(t, topics.Close())
+	c.Segment.TimeIndexIntervalBytes = 0
+	topics, err = NewTopics(filepath.Join(dir, "topics"), c)
+	require.NoError(t, err)
+	defer topics.Close()
+	_, err = topics.Append(DefaultTopic, 0, &api.Record{Value: []byte("later")})
+	require.NoError(t, err)
+	require.Equal(t, before, open())
+
+	restoreDir, err := os.MkdirTemp("", "snapshot-reopen-restore-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(restoreDir)
+	restored, err := NewTopics(restoreDir, c)
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, (&partitionFSM{topics: restored, topic: DefaultTopic}).Restore(io.NopCloser(bytes.NewReader(before))))
+	for off := uint64(0); off < 3; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello world"), read.Value)
+	}
+	_, err = restored.Read(DefaultTopic, 0, 3)
+	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+	off, err := restored.OffsetForTime(DefaultTopic, 0, base.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), off)
+}
+
+// fのスナップショットをインデックスindexのスナップショットとしてstoreに保存して、そのIDを返す。
+func persistToStore(t *testing.T, store raft.SnapshotStore, f raft.FSM, index uint64) string {
+	t.Helper()
+	snap, err := f.Snapshot()
+	require.NoError(t, err)
+	return persist(t, store, snap, index)
+}
+
+func persist(t *testing.T, store raft.SnapshotStore, snap raft.FSMSnapshot, index uint64) string {
+	t.Helper()
+	defer snap.Release()
+	sink, err := store.Create(raft.SnapshotVersionMax, index, 1, raft.Configuration{}, 1, nil)
+	require.NoError(t, err)
+	require.NoError(t, snap.Persist(sink))
+	return sink.ID()
+}
+
+// スナップショットストアを使わずにsnapを保存したときの内容を返す。
+func persistSnapshot(t *testing.T, snap raft.FSMSnapshot) []byte {
+	t.Helper()
+	store := raft.NewInmemSnapshotStore()
+	_, rc, err := store.Open(persist(t, store, snap, 1))
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return b
+}
@@ -2,71 +2,203 @@ package log
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
 	"os"
 	"sync"
 )
 
 var (
 	enc = binary.BigEndian
+	// CRC32C(Castagnoli)はハードウェア支援が効くのでレコードごとに計算しても安い
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
 )
 
+// ストアに書き込まれる1レコード分のフレームは次の形式になっている。
+//
+//	| length (8) | crc32c (4) | version (1) | attributes (1) | payload (length) |
+//
+// lengthはpayloadのバイト数、crc32cはversion、attributes、payloadに対するチェックサム。
+// attributesの下位3ビットはpayloadの圧縮方式(Compression)を、attrEncryptedのビットは
+// payloadを圧縮してから暗号化していることを表す。attrBatchのビットが立っていれば、payloadは
+// 1レコード分ではなく、まとめて追加したレコードを | length (8) | Record | の形で並べたバッチで、バッチ全体を1回で圧縮する。
+// attributesのないバージョン1のフレーム(ヘッダが13バイト)も読み出せる。
 const (
-	lenWidth = 8
+	lenWidth     = 8
+	crcWidth     = 4
+	versionWidth = 1
+	attrWidth    = 1
+	headerWidth  = lenWidth + crcWidth + versionWidth + attrWidth // 14 bytes
+
+	frameVersion1 byte = 1
+	frameVersion2 byte = 2
+
+	attrCompressionMask byte = 0x07
+	attrEncrypted       byte = 0x08
+	attrBatch           byte = 0x10
 )
 
+// ディスク上のフレームが壊れている(チェックサム不一致、途中で途切れている、未知のバージョン)ことを表す。
+// オフセットを知っているセグメントがapi.ErrCorruptRecordに変換して呼び出し元に返す。
+var errCorruptFrame = errors.New("store: corrupt record frame")
+
 type store struct {
 	*os.File
 	mu   sync.Mutex
 	buf  *bufio.Writer
 	size uint64
+
+	// 追加するレコードの圧縮方式
+	compression Compression
+	// nilでなければレコードを暗号化する
+	keyring *Keyring
+
+	// グループコミットのための状態。syncedまではディスクに永続化済み。
+	// syncingがtrueの間は他のゴルーチンがfsyncしているので、終わるのをsyncCondで待つ。
+	syncMu   sync.Mutex
+	syncCond *sync.Cond
+	syncing  bool
+	synced   uint64
 }
 
-func newStore(f *os.File) (*store, error) {
+func newStore(f *os.File, c Config) (*store, error) {
 	fi, err := os.Stat(f.Name())
 	if err != nil {
 		return nil, err
 	}
 	size := uint64(fi.Size())
-	return &store{
-		File: f,
-		size: size,
-		buf:  bufio.NewWriter(f),
-	}, nil
+	s := &store{
+		File:        f,
+		size:        size,
+		buf:         bufio.NewWriter(f),
+		synced:      size,
+		compression: c.Segment.Compression,
+		keyring:     c.Segment.Keyring,
+	}
+	s.syncCond = sync.NewCond(&s.syncMu)
+	return s, nil
 }
 
 func (s *store) Append(p []byte) (n uint64, pos uint64, err error) { // n: 何バイトと書き込んだか、pos:書き込む前のサイズ。
+	return s.append(0, p)
+}
+
+// | length (8) | Record | を並べたバッチを1つのフレームとして追加する。
+func (s *store) AppendBatch(p []byte) (n uint64, pos uint64, err error) {
+	return s.append(attrBatch, p)
+}
+
+func (s *store) append(attrs byte, p []byte) (n uint64, pos uint64, err error) {
+	// 圧縮と暗号化はロックを取る前に済ませておく
+	if s.compression != CompressionNone {
+		c, err := compress(s.compression, p)
+		if err != nil {
+			return 0, 0, err
+		}
+		// 圧縮しても小さくならなければ圧縮せずに書き込む
+		if len(c) < len(p) {
+			p, attrs = c, attrs|byte(s.compression)
+		}
+	}
+	if s.keyring != nil {
+		if p, err = s.keyring.seal(p); err != nil {
+			return 0, 0, err
+		}
+		attrs |= attrEncrypted
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	pos = s.size
-	if err := binary.Write(s.buf, enc, uint64(len(p))); err != nil {
+	if _, err := s.buf.Write(frameHeader(attrs, p)); err != nil {
 		return 0, 0, err
 	}
 	w, err := s.buf.Write(p)
 	if err != nil {
 		return 0, 0, err
 	}
-	w += lenWidth
+	w += headerWidth
 	s.size += uint64(w)
 	return uint64(w), pos, nil
 }
 
-func (s *store) Read(pos uint64) ([]byte, error) {
+// 指定された位置のフレームを読み出し、チェックサムを検証してpayloadとattributesを返す。
+func (s *store) Read(pos uint64) ([]byte, byte, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if err := s.buf.Flush(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	size := make([]byte, lenWidth)
-	if _, err := s.File.ReadAt(size, int64(pos)); err != nil {
-		return nil, err
+	if pos >= s.size {
+		return nil, 0, io.EOF
 	}
-	b := make([]byte, enc.Uint64(size))
-	if _, err := s.File.ReadAt(b, int64(pos+lenWidth)); err != nil {
-		return nil, err
+	// ファイルの末尾を超えて読もうとした場合はフレームが途中で途切れているとみなす。
+	r := io.NewSectionReader(s.File, int64(pos), int64(s.size-pos))
+	p, attrs, _, err := readFrame(r, s.keyring)
+	return p, attrs, err
+}
+
+// ストアの現在のサイズ(バッファに残っているものも含む)を返す。
+func (s *store) Size() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// バッファに残っているレコードをファイルに書き出して、そのサイズを返す。fsyncはしない。
+func (s *store) Flush() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.buf.Flush(); err != nil {
+		return 0, err
 	}
-	return b, nil
+	return s.size, nil
+}
 
+// ストアの先頭からuptoバイトまでがディスクに永続化されるまで待つ。
+// fsync中に追加されたレコードは次のfsyncでまとめて永続化されるので、
+// 同時に呼び出した複数のゴルーチンが1回のfsyncを共有できる。
+func (s *store) Sync(upto uint64) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	for s.synced < upto {
+		if s.syncing {
+			// 他のゴルーチンがfsyncしているので、それが終わるのを待ってから再確認する
+			s.syncCond.Wait()
+			continue
+		}
+		s.syncing = true
+		s.syncMu.Unlock()
+		size, err := s.flushAndSync()
+		s.syncMu.Lock()
+		s.syncing = false
+		if err == nil && size > s.synced {
+			s.synced = size
+		}
+		s.syncCond.Broadcast()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// バッファをファイルに書き出してからfsyncし、永続化できたサイズを返す。
+// fsyncの間はs.muを保持しないので、その間も他のゴルーチンは追加を続けられる。
+func (s *store) flushAndSync() (uint64, error) {
+	s.mu.Lock()
+	if err := s.buf.Flush(); err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	size := s.size
+	s.mu.Unlock()
+	if err := s.File.Sync(); err != nil {
+		return 0, err
+	}
+	return size, nil
 }
 
 func (s *store) ReadAt(p []byte, off int64) (int, error) {
@@ -78,6 +210,25 @@ func (s *store) ReadAt(p []byte, off int64) (int, error) {
 	return s.File.ReadAt(p, off)
 }
 
+// 指定したサイズまでストアを切り詰める。クラッシュで途中まで書き込まれたレコードを捨てるために使う。
+func (s *store) Truncate(size uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if err := s.File.Truncate(int64(size)); err != nil {
+		return err
+	}
+	s.size = size
+	s.syncMu.Lock()
+	if s.synced > size {
+		s.synced = size
+	}
+	s.syncMu.Unlock()
+	return nil
+}
+
 func (s *store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -85,5 +236,79 @@ func (s *store) Close() error {
 	if err != nil {
 		return err
 	}
+	if err := s.File.Sync(); err != nil {
+		return err
+	}
 	return s.File.Close()
 }
+
+// attributesとpayloadに対するフレームヘッダを作る。
+func frameHeader(attrs byte, p []byte) []byte {
+	h := make([]byte, headerWidth)
+	enc.PutUint64(h[:lenWidth], uint64(len(p)))
+	h[lenWidth+crcWidth] = frameVersion2
+	h[lenWidth+crcWidth+versionWidth] = attrs
+	enc.PutUint32(h[lenWidth:lenWidth+crcWidth], frameChecksum(h[lenWidth+crcWidth:], p))
+	return h
+}
+
+// hはversionとattributes(バージョン1ならversionのみ)
+func frameChecksum(h []byte, p []byte) uint32 {
+	crc := crc32.Update(0, crcTable, h)
+	return crc32.Update(crc, crcTable, p)
+}
+
+// rから1フレームを読み出して、復号して展開したpayloadとattributes、ディスク上のフレームのバイト数を返す。
+// フレームの境界でrが終わった場合はio.EOFを、フレームが壊れている場合はerrCorruptFrameを返す。
+// 暗号化されたフレームはkの鍵で復号する。
+// ストアからの読み出しとスナップショットからの復元の両方で使う。
+func readFrame(r io.Reader, k *Keyring) ([]byte, byte, uint64, error) {
+	h := make([]byte, headerWidth)
+	if _, err := io.ReadFull(r, h[:headerWidth-attrWidth]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, 0, errCorruptFrame
+		}
+		return nil, 0, 0, err
+	}
+	switch h[lenWidth+crcWidth] {
+	case frameVersion1:
+		h = h[:headerWidth-attrWidth]
+	case frameVersion2:
+		if _, err := io.ReadFull(r, h[headerWidth-attrWidth:]); err != nil {
+			return nil, 0, 0, errCorruptFrame
+		}
+	default:
+		return nil, 0, 0, errCorruptFrame
+	}
+	size := enc.Uint64(h[:lenWidth])
+	// 長さ自体が壊れていると巨大なバッファを確保してしまうので、読めた分だけ確保する。
+	var b bytes.Buffer
+	n, err := io.CopyN(&b, r, int64(size))
+	if err != nil && err != io.EOF {
+		return nil, 0, 0, err
+	}
+	if uint64(n) != size {
+		return nil, 0, 0, errCorruptFrame
+	}
+	p := b.Bytes()
+	if frameChecksum(h[lenWidth+crcWidth:], p) != enc.Uint32(h[lenWidth:lenWidth+crcWidth]) {
+		return nil, 0, 0, errCorruptFrame
+	}
+	width := uint64(len(h)) + size
+	var attrs byte
+	if len(h) == headerWidth {
+		attrs = h[headerWidth-attrWidth]
+		if attrs&attrEncrypted != 0 {
+			if k == nil {
+				return nil, 0, 0, errNoKeyring
+			}
+			if p, err = k.open(p); err != nil {
+				return nil, 0, 0, err
+			}
+		}
+		if p, err = decompress(Compression(attrs&attrCompressionMask), p); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return p, attrs, width, nil
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"bytes"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -9,7 +11,7 @@ import (
 
 var (
 	write = []byte("hello world")
-	width = uint64(len(write)) + lenWidth
+	width = uint64(len(write)) + headerWidth
 )
 
 func TestStoreAppendRead(t *testing.T) {
@@ -17,14 +19,14 @@ func TestStoreAppendRead(t *testing.T) {
 	require.NoError(t, err)
 	defer os.Remove(f.Name())
 
-	s, err := newStore(f)
+	s, err := newStore(f, Config{})
 	require.NoError(t, err)
 
 	testAppend(t, s)
 	testRead(t, s)
 	testReadAt(t, s)
 
-	s, err = newStore(f)
+	s, err = newStore(f, Config{})
 	require.NoError(t, err)
 	testRead(t, s)
 }
@@ -42,7 +44,7 @@ func testRead(t *testing.T, s *store) {
 	t.Helper()
 	var pos uint64
 	for i := uint64(1); i < 4; i++ {
-		read, err := s.Read(pos)
+		read, _, err := s.Read(pos)
 		require.NoError(t, err)
 		require.Equal(t, write, read)
 		pos += width
@@ -52,12 +54,12 @@ func testRead(t *testing.T, s *store) {
 func testReadAt(t *testing.T, s *store) {
 	t.Helper()
 	for i, off := uint64(1), int64(0); i < 4; i++ {
-		b := make([]byte, lenWidth)
+		b := make([]byte, headerWidth)
 		n, err := s.ReadAt(b, off)
 		require.NoError(t, err)
-		require.Equal(t, lenWidth, n)
+		require.Equal(t, headerWidth, n)
 		off += int64(n)
-		size := enc.Uint64(b)
+		size := enc.Uint64(b[:lenWidth])
 		b = make([]byte, size)
 		n, err = s.ReadAt(b, off)
 		require.NoError(t, err)
@@ -67,11 +69,135 @@ func testReadAt(t *testing.T, s *store) {
 	}
 }
 
+// ディスク上のバイトが化けたり、書き込みが途中で途切れたりした場合に、
+// 壊れたデータを返さずにエラーを返すことをテストする。
+func TestStoreCorruption(t *testing.T) {
+	f, err := os.CreateTemp("", "store_corruption_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	s, err := newStore(f, Config{})
+	require.NoError(t, err)
+	testAppend(t, s)
+	require.NoError(t, s.Close())
+
+	// 2件目のpayloadの1バイトを反転させる
+	f, err = os.OpenFile(f.Name(), os.O_RDWR, 0600)
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, int64(width+headerWidth))
+	require.NoError(t, err)
+	b[0] ^= 0xff
+	_, err = f.WriteAt(b, int64(width+headerWidth))
+	require.NoError(t, err)
+
+	s, err = newStore(f, Config{})
+	require.NoError(t, err)
+	read, _, err := s.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, write, read)
+	_, _, err = s.Read(width)
+	require.Equal(t, errCorruptFrame, err)
+
+	// 3件目の途中で途切れた場合
+	require.NoError(t, f.Truncate(int64(width*3-1)))
+	s, err = newStore(f, Config{})
+	require.NoError(t, err)
+	_, _, err = s.Read(width * 2)
+	require.Equal(t, errCorruptFrame, err)
+	require.NoError(t, s.Close())
+}
+
+// 圧縮方式ごとに追加したレコードを読み出せることと、圧縮方式を変えたりバージョン1のフレームが
+// 混ざっていたりしても読み出せることをテストする。
+func TestStoreCompression(t *testing.T) {
+	f, err := os.CreateTemp("", "store_compression_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	// バージョン1のフレームを直接書き込んでおく
+	h := make([]byte, headerWidth-attrWidth)
+	enc.PutUint64(h[:lenWidth], uint64(len(write)))
+	h[lenWidth+crcWidth] = frameVersion1
+	enc.PutUint32(h[lenWidth:lenWidth+crcWidth], frameChecksum([]byte{frameVersion1}, write))
+	_, err = f.Write(append(h, write...))
+	require.NoError(t, err)
+
+	compressible := bytes.Repeat([]byte("hello world "), 100)
+	var positions []uint64
+	for _, compression := range []Compression{
+		CompressionNone,
+		CompressionGzip,
+		CompressionSnappy,
+		CompressionZstd,
+	} {
+		c := Config{}
+		c.Segment.Compression = compression
+		s, err := newStore(f, c)
+		require.NoError(t, err)
+		n, pos, err := s.Append(compressible)
+		require.NoError(t, err)
+		if compression == CompressionNone {
+			require.Equal(t, uint64(len(compressible))+headerWidth, n)
+		} else {
+			require.Less(t, n, uint64(len(compressible)))
+		}
+		positions = append(positions, pos)
+		// 圧縮しても小さくならないものは圧縮せずに書き込む
+		n, _, err = s.Append(write)
+		require.NoError(t, err)
+		require.Equal(t, width, n)
+		require.NoError(t, s.buf.Flush())
+	}
+
+	s, err := newStore(f, Config{})
+	require.NoError(t, err)
+	read, _, err := s.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, write, read)
+	for _, pos := range positions {
+		read, _, err := s.Read(pos)
+		require.NoError(t, err)
+		require.Equal(t, compressible, read)
+	}
+	require.NoError(t, s.Close())
+}
+
+// 複数のゴルーチンが同時に追加してSyncを呼び出しても、全員の書き込みが永続化されてから返ることをテストする。
+func TestStoreSync(t *testing.T) {
+	f, err := os.CreateTemp("", "store_sync_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	s, err := newStore(f, Config{})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, pos, err := s.Append(write)
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(pos+n))
+			s.syncMu.Lock()
+			defer s.syncMu.Unlock()
+			require.True(t, s.synced >= pos+n)
+		}()
+	}
+	wg.Wait()
+
+	// バッファに残っていたものもすべてファイルに書き出されている
+	_, size, err := openFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(width*10), size)
+	require.NoError(t, s.Close())
+}
+
 func TestStoreClose(t *testing.T) {
 	f, err := os.CreateTemp("", "store_close_test")
 	require.NoError(t, err)
 	defer os.Remove(f.Name())
-	s, err := newStore(f)
+	s, err := newStore(f, Config{})
 	require.NoError(t, err)
 	_, _, err = s.Append(write)
 	require.NoError(t, err)
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	tsWidth      uint64 = 8                  // 8 bytes
+	timeEntWidth        = tsWidth + offWidth // 12 bytes
+)
+
+// タイムインデックスはセグメントごとの疎なインデックスで、タイムスタンプからオフセットを探すために使う。
+// エントリ(ts, off)は「相対オフセットoff以前のレコードのタイムスタンプはすべてts以下である」ことを表す。
+// タイムスタンプの最大値が更新されたときにだけエントリを追加するので、時計が戻ってもエントリは単調増加する。
+type timeIndex struct {
+	file    *os.File
+	entries []timeEntry
+}
+
+type timeEntry struct {
+	ts  int64  // UnixNano
+	off uint32 // セグメントのベースオフセットからの相対オフセット
+}
+
+func newTimeIndex(f *os.File) (*timeIndex, error) {
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	t := &timeIndex{file: f}
+	for pos := uint64(0); pos+timeEntWidth <= uint64(len(b)); pos += timeEntWidth {
+		t.entries = append(t.entries, timeEntry{
+			ts:  int64(enc.Uint64(b[pos : pos+tsWidth])),
+			off: enc.Uint32(b[pos+tsWidth : pos+timeEntWidth]),
+		})
+	}
+	// 書き込み途中でクラッシュした中途半端なエントリは捨てる
+	if size := int64(len(t.entries)) * int64(timeEntWidth); size != int64(len(b)) {
+		if err = f.Truncate(size); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// エントリを追加する。
+func (t *timeIndex) Write(ts int64, off uint32) error {
+	b := make([]byte, timeEntWidth)
+	enc.PutUint64(b[:tsWidth], uint64(ts))
+	enc.PutUint32(b[tsWidth:], off)
+	if _, err := t.file.Write(b); err != nil {
+		return err
+	}
+	t.entries = append(t.entries, timeEntry{ts: ts, off: off})
+	return nil
+}
+
+// 最後のエントリを返す。エントリがなければio.EOFを返す。
+func (t *timeIndex) Last() (ts int64, off uint32, err error) {
+	if len(t.entries) == 0 {
+		return 0, 0, io.EOF
+	}
+	e := t.entries[len(t.entries)-1]
+	return e.ts, e.off, nil
+}
+
+// タイムスタンプがts以上のレコードを探し始める相対オフセットを返す。
+// tsより小さいエントリのうち最後のものの次のオフセットより前には、ts以上のレコードはない。
+func (t *timeIndex) Lookup(ts int64) uint32 {
+	i := sort.Search(len(t.entries), func(i int) bool {
+		return t.entries[i].ts >= ts
+	})
+	if i == 0 {
+		return 0
+	}
+	return t.entries[i-1].off + 1
+}
+
+// 先頭からn個のエントリだけを残す。
+func (t *timeIndex) truncate(n int) error {
+	if n >= len(t.entries) {
+		return nil
+	}
+	if err := t.file.Truncate(int64(n) * int64(timeEntWidth)); err != nil {
+		return err
+	}
+	t.entries = t.entries[:n]
+	return nil
+}
+
+// エントリをすべて削除する。エントリを書き直す前に呼び出す。
+// スナップショットがハードリンクしているファイルを書き換えないように、新しいファイルに置き換える。
+func (t *timeIndex) Reset() error {
+	f, err := replaceFile(t.file, os.O_RDWR|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	if err = t.file.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	t.file, t.entries = f, nil
+	return nil
+}
+
+func (t *timeIndex) Close() error {
+	if err := t.file.Sync(); err != nil {
+		return err
+	}
+	return t.file.Close()
+}
+
+func (t *timeIndex) Name() string {
+	return t.file.Name()
+}
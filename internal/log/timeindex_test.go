@@ -0,0 +1,60 @@
+package log
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeIndex(t *testing.T) {
+	f, err := os.CreateTemp(os.TempDir(), "timeindex_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	idx, err := newTimeIndex(f)
+	require.NoError(t, err)
+	_, _, err = idx.Last()
+	require.Error(t, err)
+	require.Equal(t, f.Name(), idx.Name())
+	// 空のときは先頭から探す
+	require.Equal(t, uint32(0), idx.Lookup(100))
+
+	// 相対オフセット0-1のレコードのタイムスタンプは10以下、2-4は20以下、5は30以下
+	require.NoError(t, idx.Write(10, 1))
+	require.NoError(t, idx.Write(20, 4))
+	require.NoError(t, idx.Write(30, 5))
+
+	for _, tc := range []struct {
+		ts   int64
+		want uint32
+	}{
+		{ts: 5, want: 0},
+		{ts: 10, want: 0},
+		{ts: 11, want: 2},
+		{ts: 20, want: 2},
+		{ts: 25, want: 5},
+		{ts: 31, want: 6},
+	} {
+		require.Equal(t, tc.want, idx.Lookup(tc.ts), "ts=%d", tc.ts)
+	}
+	require.NoError(t, idx.Close())
+
+	// 書き込み途中でクラッシュした中途半端なエントリは捨てて、既存のファイルから状態を構築する
+	f, err = os.OpenFile(f.Name(), os.O_RDWR|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{1, 2, 3})
+	require.NoError(t, err)
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+	idx, err = newTimeIndex(f)
+	require.NoError(t, err)
+	ts, off, err := idx.Last()
+	require.NoError(t, err)
+	require.Equal(t, int64(30), ts)
+	require.Equal(t, uint32(5), off)
+	fi, err := os.Stat(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(timeEntWidth*3), fi.Size())
+	require.NoError(t, idx.Close())
+}
@@ -0,0 +1,673 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultTopic はトピックを指定しないリクエストが使うトピック。削除できない。
+const DefaultTopic = "default"
+
+// トピックの設定を保存するファイル。トピックのディレクトリに置く
+const topicConfigFile = "topic.config"
+
+// 最後に払い出したプロデューサーのIDを保存するファイル。Dirに置く
+const producerIDFile = "producer_id"
+
+// 1つのトピックに作れるパーティションの上限。パーティションごとにRaftのグループを動かすので、増やしすぎないようにする
+const maxPartitions = 256
+
+// トピック名はそのままディレクトリ名に使う
+var topicNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// Topics はトピックの一覧と、トピックのパーティションごとのログを管理する。
+// パーティションのログは<Dir>/<トピック名>/<パーティション番号>に置く。
+// トピックとパーティションを指定する以外は、レコードの追加と読み出しはLogと同じように使える。
+type Topics struct {
+	Dir    string
+	Config Config
+
+	mu     sync.RWMutex
+	topics map[string]*topic
+
+	// キーのないレコードを追加するパーティションを順番に選ぶためのカウンター
+	next uint32
+
+	// 最後に払い出したプロデューサーのID。muで守る
+	lastProducerID uint64
+}
+
+type topic struct {
+	config *api.TopicConfig
+	logs   []*Log
+	// パーティションごとのコンシューマーグループがコミットしたオフセット
+	offsets []*committedOffsets
+	// パーティションごとのプロデューサーの状態
+	producers []*producerStates
+}
+
+// NewTopics はdirにあるトピックを開く。デフォルトのトピックがなければ作成する。
+func NewTopics(dir string, c Config) (*Topics, error) {
+	t := &Topics{
+		Dir:    dir,
+		Config: c,
+	}
+	return t, t.setup()
+}
+
+func (t *Topics) setup() error {
+	if err := t.migrate(); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(filepath.Join(t.Dir, producerIDFile))
+	if err == nil && len(b) == lenWidth {
+		t.lastProducerID = enc.Uint64(b)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	entries, err := os.ReadDir(t.Dir)
+	if err != nil {
+		return err
+	}
+	t.topics = make(map[string]*topic)
+	for _, entry := range entries {
+		if !entry.IsDir() || validateTopic(entry.Name()) != nil {
+			continue
+		}
+		config, err := readTopicConfig(filepath.Join(t.Dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err = t.open(entry.Name(), config); err != nil {
+			return err
+		}
+	}
+	if _, ok := t.topics[DefaultTopic]; !ok {
+		return t.create(DefaultTopic, normalizeTopicConfig(nil), 0)
+	}
+	return nil
+}
+
+// 古いディレクトリの構成を今の構成に移す。
+// トピックを導入する前はdirに直接セグメントを置いていたので、デフォルトのトピックのディレクトリに移す。
+// パーティションを導入する前はトピックのディレクトリに直接セグメントを置いていたので、パーティション0のディレクトリに移す。
+func (t *Topics) migrate() error {
+	moved, err := moveFiles(t.Dir, filepath.Join(t.Dir, DefaultTopic), func(name string) bool {
+		return name == producerIDFile
+	})
+	if err != nil {
+		return err
+	}
+	if moved {
+		// 圧縮の途中で残ったディレクトリをトピックと間違えないように消す
+		if err = os.RemoveAll(filepath.Join(t.Dir, compactionDir)); err != nil {
+			return err
+		}
+	}
+	entries, err := os.ReadDir(t.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || validateTopic(entry.Name()) != nil {
+			continue
+		}
+		dir := filepath.Join(t.Dir, entry.Name())
+		moved, err = moveFiles(dir, filepath.Join(dir, "0"), func(name string) bool {
+			return name == topicConfigFile
+		})
+		if err != nil {
+			return err
+		}
+		if moved {
+			if err = os.RemoveAll(filepath.Join(dir, compactionDir)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fromにあるファイルを、skipが真を返すものを除いてtoに移す。ディレクトリは移さない。
+func moveFiles(from, to string, skip func(string) bool) (bool, error) {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return false, err
+	}
+	var moved bool
+	for _, entry := range entries {
+		if entry.IsDir() || (skip != nil && skip(entry.Name())) {
+			continue
+		}
+		if err = os.MkdirAll(to, 0755); err != nil {
+			return false, err
+		}
+		if err = os.Rename(
+			filepath.Join(from, entry.Name()),
+			filepath.Join(to, entry.Name()),
+		); err != nil {
+			return false, err
+		}
+		moved = true
+	}
+	return moved, nil
+}
+
+// CreateTopic はトピックとそのパーティションのログを作成する。すでにあればapi.ErrTopicExistsを返す。
+func (t *Topics) CreateTopic(name string, config *api.TopicConfig) (*api.Topic, error) {
+	if err := validateTopic(name); err != nil {
+		return nil, err
+	}
+	if err := validateTopicConfig(name, config); err != nil {
+		return nil, err
+	}
+	config = normalizeTopicConfig(config)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.topics[name]; ok {
+		return nil, api.ErrTopicExists{Topic: name}
+	}
+	if err := t.create(name, config, 0); err != nil {
+		return nil, err
+	}
+	return &api.Topic{Name: name, Config: config}, nil
+}
+
+// DeleteTopic はトピックとそのログを削除する。デフォルトのトピックは削除できない。
+func (t *Topics) DeleteTopic(name string) error {
+	if name == DefaultTopic {
+		return api.ErrInvalidTopic{Topic: name, Reason: "the default topic can't be deleted"}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return api.ErrTopicNotFound{Topic: name}
+	}
+	delete(t.topics, name)
+	for _, l := range tp.logs {
+		if err := l.Close(); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(filepath.Join(t.Dir, name))
+}
+
+// ListTopics はトピックを名前の順に返す。
+func (t *Topics) ListTopics() ([]*api.Topic, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	topics := make([]*api.Topic, 0, len(t.topics))
+	for name, tp := range t.topics {
+		topics = append(topics, &api.Topic{Name: name, Config: tp.config})
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		return topics[i].Name < topics[j].Name
+	})
+	return topics, nil
+}
+
+// Partitions はトピックのパーティションの数を返す。
+func (t *Topics) Partitions(name string) (uint32, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return 0, api.ErrTopicNotFound{Topic: name}
+	}
+	return uint32(len(tp.logs)), nil
+}
+
+// Partition はkeyを持つレコードを追加するパーティションを返す。
+// キーがなければパーティションを順番に選ぶ。
+func (t *Topics) Partition(name string, key []byte) (uint32, error) {
+	n, err := t.Partitions(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(key) > 0 {
+		return api.PartitionForKey(key, n), nil
+	}
+	return atomic.AddUint32(&t.next, 1) % n, nil
+}
+
+// Log はトピックのパーティションのログを返す。nameが空ならデフォルトのトピックのログを返す。
+func (t *Topics) Log(name string, partition uint32) (*Log, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return nil, api.ErrTopicNotFound{Topic: name}
+	}
+	if partition >= uint32(len(tp.logs)) {
+		return nil, api.ErrPartitionNotFound{Topic: name, Partition: partition}
+	}
+	return tp.logs[partition], nil
+}
+
+// パーティションのコミットされたオフセットを返す。
+func (t *Topics) committedOffsets(name string, partition uint32) (*committedOffsets, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return nil, api.ErrTopicNotFound{Topic: name}
+	}
+	if partition >= uint32(len(tp.offsets)) {
+		return nil, api.ErrPartitionNotFound{Topic: name, Partition: partition}
+	}
+	return tp.offsets[partition], nil
+}
+
+// CommitOffset はコンシューマーグループがパーティションから次に読み出すオフセットを保存する。
+func (t *Topics) CommitOffset(topic string, partition uint32, group string, offset uint64) error {
+	if group == "" {
+		return api.ErrInvalidGroup{Group: group}
+	}
+	o, err := t.committedOffsets(topic, partition)
+	if err != nil {
+		return err
+	}
+	return o.commit(group, offset)
+}
+
+// FetchCommittedOffset はコンシューマーグループがパーティションにコミットしたオフセットを返す。
+// まだコミットしていなければapi.ErrNoCommittedOffsetを返す。
+func (t *Topics) FetchCommittedOffset(topic string, partition uint32, group string) (uint64, error) {
+	if group == "" {
+		return 0, api.ErrInvalidGroup{Group: group}
+	}
+	o, err := t.committedOffsets(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	off, ok := o.get(group)
+	if !ok {
+		if topic == "" {
+			topic = DefaultTopic
+		}
+		return 0, api.ErrNoCommittedOffset{Group: group, Topic: topic, Partition: partition}
+	}
+	return off, nil
+}
+
+// InitProducer は冪等なプロデューサーの新しいIDを払い出す。IDは1から順に振り、再起動しても同じIDは払い出さない。
+func (t *Topics) InitProducer() (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.writeLastProducerID(t.lastProducerID + 1); err != nil {
+		return 0, err
+	}
+	t.lastProducerID++
+	return t.lastProducerID, nil
+}
+
+// スナップショットに含める、最後に払い出したプロデューサーのIDを返す。
+func (t *Topics) producerID() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lastProducerID
+}
+
+// スナップショットから復元した、最後に払い出したプロデューサーのIDに置き換える。
+func (t *Topics) resetProducerID(id uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.writeLastProducerID(id); err != nil {
+		return err
+	}
+	t.lastProducerID = id
+	return nil
+}
+
+// 書きかけのファイルが残らないように、一時ファイルに書き込んでから置き換える。t.muを取得してから呼び出す。
+func (t *Topics) writeLastProducerID(id uint64) error {
+	b := make([]byte, lenWidth)
+	enc.PutUint64(b, id)
+	tmp := filepath.Join(t.Dir, producerIDFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.Dir, producerIDFile))
+}
+
+// AppendIdempotent はproducerIDのsequenceから始まるrecordsをパーティションに追加する。
+// 再送されたバッチは追加せずに、最初に追加したときのオフセットを返す。
+func (t *Topics) AppendIdempotent(
+	topic string,
+	partition uint32,
+	producerID, sequence uint64,
+	records []*api.Record,
+) ([]uint64, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	l, p, err := t.producerStates(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	return p.append(producerID, sequence, records, l.AppendBatch)
+}
+
+// パーティションのログとプロデューサーの状態を返す。
+func (t *Topics) producerStates(name string, partition uint32) (*Log, *producerStates, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return nil, nil, api.ErrTopicNotFound{Topic: name}
+	}
+	if partition >= uint32(len(tp.logs)) {
+		return nil, nil, api.ErrPartitionNotFound{Topic: name, Partition: partition}
+	}
+	return tp.logs[partition], tp.producers[partition], nil
+}
+
+func (t *Topics) Append(topic string, partition uint32, record *api.Record) (uint64, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	return l.Append(record)
+}
+
+func (t *Topics) AppendBatch(topic string, partition uint32, records []*api.Record) ([]uint64, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	return l.AppendBatch(records)
+}
+
+func (t *Topics) Read(topic string, partition uint32, off uint64) (*api.Record, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	return l.Read(off)
+}
+
+// WaitForOffset はパーティションのログにオフセットoffのレコードが追加されるまで待つ。
+// スナップショットからの復元でログが置き換えられたら、新しいログで待ち直す。
+// 待っている間にトピックが削除されたらapi.ErrTopicNotFoundを返す。
+func (t *Topics) WaitForOffset(ctx context.Context, topic string, partition uint32, off uint64) error {
+	var closed *Log
+	for {
+		l, err := t.Log(topic, partition)
+		if err != nil {
+			return err
+		}
+		// 置き換えられずに閉じられたのは、ノードを止めようとしているとき
+		if l == closed {
+			return api.ErrUnavailable{Reason: "the partition log is closed"}
+		}
+		if err = l.WaitForOffset(ctx, off); err != errLogClosed {
+			return err
+		}
+		closed = l
+	}
+}
+
+func (t *Topics) OffsetForTime(topic string, partition uint32, tm time.Time) (uint64, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	return l.OffsetForTime(tm)
+}
+
+// OffsetRange はパーティションのログに残っている最も古いオフセットと、次に追加されるレコードのオフセットを返す。
+func (t *Topics) OffsetRange(topic string, partition uint32) (lowest, next uint64, err error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	lowest, next = l.OffsetRange()
+	return lowest, next, nil
+}
+
+// DescribeLog はパーティションのログのオフセットの範囲とセグメントを返す。Raftの状態は含まない。
+func (t *Topics) DescribeLog(topic string, partition uint32) (*api.NodeLog, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	return l.Describe(), nil
+}
+
+// RetentionOffset はパーティションのログで、保持期間か保持サイズを超えたセグメントを除いて残す最初のオフセットを返す。
+func (t *Topics) RetentionOffset(topic string, partition uint32) (uint64, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	return l.RetentionOffset(), nil
+}
+
+// TruncateBefore はパーティションのログからオフセットoffより前のレコードだけを持つセグメントを削除して、
+// 残っている最も古いオフセットを返す。
+func (t *Topics) TruncateBefore(topic string, partition uint32, off uint64) (uint64, error) {
+	l, err := t.Log(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	return l.TruncateBefore(off)
+}
+
+// すべてのトピックのログをクローズする
+func (t *Topics) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tp := range t.topics {
+		for _, l := range tp.logs {
+			if err := l.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// すべてのトピックをクローズして、そのデータをすべて削除する
+func (t *Topics) Remove() error {
+	if err := t.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(t.Dir)
+}
+
+// パーティションのログとコミットされたオフセットとプロデューサーの状態を削除して、
+// installが空のディレクトリに書き込んだセグメントを開いたログに置き換える。installがnilか、セグメントを書き込まなければ、
+// initialOffsetから始まる空のログになる。スナップショットから復元するときに使う。
+func (t *Topics) installPartition(
+	name string,
+	partition uint32,
+	initialOffset uint64,
+	install func(dir string) error,
+) (*Log, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tp, ok := t.topics[name]
+	if !ok {
+		return nil, api.ErrTopicNotFound{Topic: name}
+	}
+	if partition >= uint32(len(tp.logs)) {
+		return nil, api.ErrPartitionNotFound{Topic: name, Partition: partition}
+	}
+	l := tp.logs[partition]
+	if err := l.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(l.Dir); err != nil {
+		return nil, err
+	}
+	if install != nil {
+		if err := os.MkdirAll(l.Dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := install(l.Dir); err != nil {
+			return nil, err
+		}
+	}
+	c := l.Config
+	c.Segment.InitialOffset = initialOffset
+	l, err := t.newLog(name, partition, c)
+	if err != nil {
+		return nil, err
+	}
+	tp.logs[partition] = l
+	// コミットされたオフセットとプロデューサーの状態もログのディレクトリごと消えたので空になる
+	if tp.offsets[partition], err = openCommittedOffsets(l.Dir); err != nil {
+		return nil, err
+	}
+	if tp.producers[partition], err = openProducerStates(l.Dir); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// トピックのディレクトリと設定ファイルを作ってから開く。t.muを取得してから呼び出す。
+func (t *Topics) create(name string, config *api.TopicConfig, initialOffset uint64) error {
+	dir := filepath.Join(t.Dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeTopicConfig(dir, config); err != nil {
+		return err
+	}
+	return t.open(name, config)
+}
+
+func (t *Topics) open(name string, config *api.TopicConfig) error {
+	config = normalizeTopicConfig(config)
+	tp := &topic{config: config}
+	c := t.logConfig(config)
+	for p := uint32(0); p < config.Partitions; p++ {
+		l, err := t.newLog(name, p, c)
+		if err != nil {
+			return err
+		}
+		tp.logs = append(tp.logs, l)
+		o, err := openCommittedOffsets(l.Dir)
+		if err != nil {
+			return err
+		}
+		tp.offsets = append(tp.offsets, o)
+		ps, err := openProducerStates(l.Dir)
+		if err != nil {
+			return err
+		}
+		tp.producers = append(tp.producers, ps)
+	}
+	t.topics[name] = tp
+	return nil
+}
+
+func (t *Topics) newLog(name string, partition uint32, c Config) (*Log, error) {
+	dir := filepath.Join(t.Dir, name, strconv.FormatUint(uint64(partition), 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return NewLog(dir, c)
+}
+
+// ノードの設定をトピックの設定で上書きしたログの設定を返す。
+func (t *Topics) logConfig(config *api.TopicConfig) Config {
+	c := t.Config
+	if config.RetentionMaxAge != nil {
+		c.Retention.MaxAge = config.RetentionMaxAge.AsDuration()
+	}
+	if config.RetentionMaxBytes != 0 {
+		c.Retention.MaxBytes = config.RetentionMaxBytes
+	}
+	if config.Compaction {
+		c.Compaction.Enabled = true
+	}
+	return c
+}
+
+func validateTopic(name string) error {
+	if !topicNameRegexp.MatchString(name) || name == "." || name == ".." {
+		return api.ErrInvalidTopic{
+			Topic:  name,
+			Reason: "topic names must be 1 to 249 letters, digits, '.', '_' or '-'",
+		}
+	}
+	return nil
+}
+
+func validateTopicConfig(name string, config *api.TopicConfig) error {
+	if config.GetPartitions() > maxPartitions {
+		return api.ErrInvalidTopic{
+			Topic:  name,
+			Reason: fmt.Sprintf("a topic can have at most %d partitions", maxPartitions),
+		}
+	}
+	return nil
+}
+
+// 省略された設定にデフォルト値を入れたコピーを返す。
+func normalizeTopicConfig(config *api.TopicConfig) *api.TopicConfig {
+	if config == nil {
+		config = &api.TopicConfig{}
+	} else {
+		config = proto.Clone(config).(*api.TopicConfig)
+	}
+	if config.Partitions == 0 {
+		config.Partitions = 1
+	}
+	return config
+}
+
+// トピックのディレクトリから設定を読み出す。設定ファイルを書き込む前にクラッシュした場合は空の設定を返す。
+func readTopicConfig(dir string) (*api.TopicConfig, error) {
+	config := &api.TopicConfig{}
+	b, err := os.ReadFile(filepath.Join(dir, topicConfigFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return config, proto.Unmarshal(b, config)
+}
+
+// 書きかけの設定ファイルが残らないように、一時ファイルに書き込んでから置き換える。
+func writeTopicConfig(dir string, config *api.TopicConfig) error {
+	b, err := proto.Marshal(config)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, topicConfigFile+".tmp")
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, topicConfigFile))
+}
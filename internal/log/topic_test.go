@@ -0,0 +1,624 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestTopics(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	topics, err := NewTopics(dir, c)
+	require.NoError(t, err)
+
+	config := &api.TopicConfig{
+		RetentionMaxAge:   durationpb.New(time.Hour),
+		RetentionMaxBytes: 1024,
+		Compaction:        true,
+	}
+	_, err = topics.CreateTopic("orders", config)
+	require.NoError(t, err)
+	_, err = topics.CreateTopic("orders", nil)
+	require.Equal(t, api.ErrTopicExists{Topic: "orders"}, err)
+	for _, name := range []string{"", ".", "..", "a/b", "トピック"} {
+		_, err = topics.CreateTopic(name, nil)
+		require.IsType(t, api.ErrInvalidTopic{}, err, name)
+	}
+	_, err = topics.CreateTopic("huge", &api.TopicConfig{Partitions: maxPartitions + 1})
+	require.IsType(t, api.ErrInvalidTopic{}, err)
+
+	// トピックごとにオフセットが振られる
+	for i := uint64(0); i < 3; i++ {
+		off, err := topics.Append("orders", 0, &api.Record{Value: []byte("order")})
+		require.NoError(t, err)
+		require.Equal(t, i, off)
+	}
+	off, err := topics.Append("", 0, &api.Record{Value: []byte("default")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), off)
+	_, err = topics.Append("missing", 0, &api.Record{Value: []byte("missing")})
+	require.Equal(t, api.ErrTopicNotFound{Topic: "missing"}, err)
+	_, err = topics.Append("orders", 1, &api.Record{Value: []byte("order")})
+	require.Equal(t, api.ErrPartitionNotFound{Topic: "orders", Partition: 1}, err)
+
+	// トピックのログにはトピックの設定が反映される
+	l, err := topics.Log("orders", 0)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "orders", "0"), l.Dir)
+	require.Equal(t, time.Hour, l.Config.Retention.MaxAge)
+	require.Equal(t, uint64(1024), l.Config.Retention.MaxBytes)
+	require.True(t, l.Config.Compaction.Enabled)
+
+	// 開き直してもトピックと設定が残っている
+	require.NoError(t, topics.Close())
+	topics, err = NewTopics(dir, c)
+	require.NoError(t, err)
+	list, err := topics.ListTopics()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list))
+	require.Equal(t, DefaultTopic, list[0].Name)
+	require.Equal(t, "orders", list[1].Name)
+	require.True(t, list[1].Config.Compaction)
+	require.Equal(t, uint32(1), list[1].Config.Partitions)
+	read, err := topics.Read("orders", 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("order"), read.Value)
+
+	require.Equal(t, api.ErrInvalidTopic{
+		Topic:  DefaultTopic,
+		Reason: "the default topic can't be deleted",
+	}, topics.DeleteTopic(DefaultTopic))
+	require.NoError(t, topics.DeleteTopic("orders"))
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, topics.DeleteTopic("orders"))
+	_, err = os.Stat(filepath.Join(dir, "orders"))
+	require.True(t, os.IsNotExist(err))
+	require.NoError(t, topics.Close())
+}
+
+// パーティションごとにオフセットが振られることと、キーが同じレコードは同じパーティションに追加されることをテストする。
+func TestTopicsPartitions(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-partitions-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	topics, err := NewTopics(dir, Config{})
+	require.NoError(t, err)
+	defer topics.Close()
+	topic, err := topics.CreateTopic("orders", &api.TopicConfig{Partitions: 3})
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), topic.Config.Partitions)
+	n, err := topics.Partitions("orders")
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), n)
+
+	for p := uint32(0); p < 3; p++ {
+		off, err := topics.Append("orders", p, &api.Record{Value: []byte("order")})
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), off)
+	}
+
+	p, err := topics.Partition("orders", []byte("customer-1"))
+	require.NoError(t, err)
+	require.Equal(t, api.PartitionForKey([]byte("customer-1"), 3), p)
+	for i := 0; i < 3; i++ {
+		again, err := topics.Partition("orders", []byte("customer-1"))
+		require.NoError(t, err)
+		require.Equal(t, p, again)
+	}
+
+	// キーのないレコードはパーティションを順番に使う
+	seen := make(map[uint32]bool)
+	for i := 0; i < 3; i++ {
+		p, err := topics.Partition("orders", nil)
+		require.NoError(t, err)
+		seen[p] = true
+	}
+	require.Equal(t, 3, len(seen))
+
+	_, err = topics.Partition("missing", nil)
+	require.Equal(t, api.ErrTopicNotFound{Topic: "missing"}, err)
+}
+
+// コミットしたオフセットがグループごとに保存され、開き直しても残ることをテストする。
+func TestTopicsCommittedOffsets(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-committed-offsets-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	topics, err := NewTopics(dir, Config{})
+	require.NoError(t, err)
+	_, err = topics.CreateTopic("orders", &api.TopicConfig{Partitions: 2})
+	require.NoError(t, err)
+
+	_, err = topics.FetchCommittedOffset("orders", 0, "billing")
+	require.Equal(t, api.ErrNoCommittedOffset{Group: "billing", Topic: "orders"}, err)
+	require.Equal(t, api.ErrInvalidGroup{}, topics.CommitOffset("orders", 0, "", 1))
+	require.Equal(t, api.ErrPartitionNotFound{Topic: "orders", Partition: 2}, topics.CommitOffset("orders", 2, "billing", 1))
+	require.NoError(t, topics.CommitOffset("orders", 0, "billing", 5))
+	require.NoError(t, topics.CommitOffset("orders", 1, "billing", 7))
+	require.NoError(t, topics.CommitOffset("orders", 0, "shipping", 2))
+
+	require.NoError(t, topics.Close())
+	topics, err = NewTopics(dir, Config{})
+	require.NoError(t, err)
+	defer topics.Close()
+	for _, want := range []struct {
+		partition uint32
+		group     string
+		offset    uint64
+	}{
+		{0, "billing", 5},
+		{1, "billing", 7},
+		{0, "shipping", 2},
+	} {
+		off, err := topics.FetchCommittedOffset("orders", want.partition, want.group)
+		require.NoError(t, err)
+		require.Equal(t, want.offset, off)
+	}
+	_, err = topics.FetchCommittedOffset("orders", 1, "shipping")
+	require.IsType(t, api.ErrNoCommittedOffset{}, err)
+}
+
+// プロデューサーのIDを指定したバッチが再送されても重複して追加されないことと、
+// プロデューサーのIDと状態が開き直しても残ることをテストする。
+func TestTopicsIdempotentAppend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-idempotent-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	topics, err := NewTopics(dir, Config{})
+	require.NoError(t, err)
+	first, err := topics.InitProducer()
+	require.NoError(t, err)
+	second, err := topics.InitProducer()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), first)
+	require.Equal(t, uint64(2), second)
+
+	record := func(value string) []*api.Record {
+		return []*api.Record{{Value: []byte(value)}}
+	}
+	offs, err := topics.AppendIdempotent("", 0, first, 0, record("first"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, offs)
+	offs, err = topics.AppendIdempotent("", 0, first, 1, []*api.Record{
+		{Value: []byte("batch 1")},
+		{Value: []byte("batch 2")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, offs)
+	// 再送されたバッチは追加せずに最初のオフセットを返す
+	offs, err = topics.AppendIdempotent("", 0, first, 0, record("first"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, offs)
+	// バッチの途中の番号や飛ばした番号は追加しない
+	for _, sequence := range []uint64{2, 4} {
+		_, err = topics.AppendIdempotent("", 0, first, sequence, record("out of order"))
+		require.Equal(t, api.ErrOutOfOrderSequence{ProducerID: first, Sequence: sequence, Expected: 3}, err)
+	}
+	// プロデューサーごとに別の番号を持つ
+	offs, err = topics.AppendIdempotent("", 0, second, 0, record("second"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, offs)
+
+	// 直近のmaxProducerBatches個のバッチだけを覚えている
+	for sequence := uint64(3); sequence < 3+maxProducerBatches; sequence++ {
+		_, err = topics.AppendIdempotent("", 0, first, sequence, record("more"))
+		require.NoError(t, err)
+	}
+	_, err = topics.AppendIdempotent("", 0, first, 1, record("batch 1"))
+	require.IsType(t, api.ErrOutOfOrderSequence{}, err)
+
+	require.NoError(t, topics.Close())
+	topics, err = NewTopics(dir, Config{})
+	require.NoError(t, err)
+	defer topics.Close()
+	offs, err = topics.AppendIdempotent("", 0, second, 0, record("second"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, offs)
+	third, err := topics.InitProducer()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), third)
+	l, err := topics.Log("", 0)
+	require.NoError(t, err)
+	highest, err := l.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3+maxProducerBatches), highest)
+}
+
+// パーティションのスナップショットにコミットしたオフセットが含まれることと、
+// セグメントのファイルを含める前や、コンシューマーグループを導入する前のスナップショットも復元できることをテストする。
+func TestPartitionSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp("", "partition-snapshot-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	topics, err := NewTopics(dir, c)
+	require.NoError(t, err)
+	defer topics.Close()
+	for i := 0; i < 3; i++ {
+		_, err = topics.Append(DefaultTopic, 0, &api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, topics.CommitOffset(DefaultTopic, 0, "billing", 2))
+	_, err = topics.AppendIdempotent(DefaultTopic, 0, 1, 0, []*api.Record{{Value: []byte("hello world")}})
+	require.NoError(t, err)
+	snap, err := (&partitionFSM{topics: topics, topic: DefaultTopic, dir: filepath.Join(dir, "staging")}).Snapshot()
+	require.NoError(t, err)
+	b := persistSnapshot(t, snap)
+
+	restoreDir, err := os.MkdirTemp("", "partition-snapshot-restore-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(restoreDir)
+	restored, err := NewTopics(restoreDir, c)
+	require.NoError(t, err)
+	defer restored.Close()
+	// 復元前にコミットされていたオフセットは消える
+	require.NoError(t, restored.CommitOffset(DefaultTopic, 0, "stale", 1))
+	f := &partitionFSM{topics: restored, topic: DefaultTopic}
+	require.NoError(t, f.Restore(io.NopCloser(bytes.NewReader(b))))
+	off, err := restored.FetchCommittedOffset(DefaultTopic, 0, "billing")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), off)
+	_, err = restored.FetchCommittedOffset(DefaultTopic, 0, "stale")
+	require.IsType(t, api.ErrNoCommittedOffset{}, err)
+	// プロデューサーの状態も復元されるので、再送されたバッチは追加しない
+	offs, err := restored.AppendIdempotent(DefaultTopic, 0, 1, 0, []*api.Record{{Value: []byte("hello world")}})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, offs)
+	for off := uint64(0); off < 4; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello world"), read.Value)
+	}
+
+	// セグメントのファイルを含める前のスナップショットはストアのフレームを並べたもの
+	l, err := topics.Log(DefaultTopic, 0)
+	require.NoError(t, err)
+	var frames bytes.Buffer
+	frames.WriteString(producersSnapshotMagic)
+	require.NoError(t, writeSnapshotMessage(&frames, &api.CommittedOffsets{Offsets: map[string]uint64{"billing": 1}}))
+	require.NoError(t, writeSnapshotMessage(&frames, &api.ProducerStates{}))
+	r, lowest, _ := storesOf(l)
+	h := make([]byte, lenWidth)
+	enc.PutUint64(h, lowest)
+	frames.Write(h)
+	_, err = io.Copy(&frames, r)
+	require.NoError(t, err)
+	require.NoError(t, f.Restore(io.NopCloser(&frames)))
+	off, err = restored.FetchCommittedOffset(DefaultTopic, 0, "billing")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), off)
+	for off := uint64(0); off < 4; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello world"), read.Value)
+	}
+
+	// コンシューマーグループを導入する前のスナップショットは最小のオフセットとストアのフレームだけ
+	r, lowest, _ = storesOf(l)
+	var old bytes.Buffer
+	enc.PutUint64(h, lowest)
+	old.Write(h)
+	_, err = io.Copy(&old, r)
+	require.NoError(t, err)
+	require.NoError(t, f.Restore(io.NopCloser(&old)))
+	_, err = restored.FetchCommittedOffset(DefaultTopic, 0, "billing")
+	require.IsType(t, api.ErrNoCommittedOffset{}, err)
+	for off := uint64(0); off < 3; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello world"), read.Value)
+	}
+}
+
+// 追加を待っている間にスナップショットからパーティションを復元しても新しいログで待ち続け、
+// トピックを削除するか、止めるためにログを閉じたら待つのをやめることをテストする。
+func TestTopicsWaitForOffset(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-wait-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = 1024
+	for _, name := range []string{"source", "topics"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+	source, err := NewTopics(filepath.Join(dir, "source"), c)
+	require.NoError(t, err)
+	defer source.Close()
+	for i := 0; i < 3; i++ {
+		_, err = source.Append(DefaultTopic, 0, &api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	snap, err := (&partitionFSM{topics: source, topic: DefaultTopic, dir: filepath.Join(dir, "staging")}).Snapshot()
+	require.NoError(t, err)
+	b := persistSnapshot(t, snap)
+
+	topics, err := NewTopics(filepath.Join(dir, "topics"), c)
+	require.NoError(t, err)
+	_, err = topics.CreateTopic("orders", nil)
+	require.NoError(t, err)
+	wait := func(topic string, off uint64) <-chan error {
+		errc := make(chan error, 1)
+		go func() {
+			errc <- topics.WaitForOffset(context.Background(), topic, 0, off)
+		}()
+		// 待ち始めるまで待つ
+		time.Sleep(50 * time.Millisecond)
+		return errc
+	}
+	received := func(errc <-chan error) error {
+		t.Helper()
+		select {
+		case err := <-errc:
+			return err
+		case <-time.After(time.Second):
+			require.Fail(t, "still waiting for the offset")
+			return nil
+		}
+	}
+
+	errc := wait(DefaultTopic, 2)
+	f := &partitionFSM{topics: topics, topic: DefaultTopic}
+	require.NoError(t, f.Restore(io.NopCloser(bytes.NewReader(b))))
+	require.NoError(t, received(errc))
+
+	errc = wait("orders", 0)
+	require.NoError(t, topics.DeleteTopic("orders"))
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, received(errc))
+
+	errc = wait(DefaultTopic, 3)
+	require.NoError(t, topics.Close())
+	require.IsType(t, api.ErrUnavailable{}, received(errc))
+}
+
+// 古いディレクトリの構成が、今の構成に移されることをテストする。
+func TestTopicsMigrate(t *testing.T) {
+	for scenario, layout := range map[string]func(dir string) string{
+		// トピックを導入する前はdirに直接セグメントを置いていた
+		"no topics": func(dir string) string { return dir },
+		// パーティションを導入する前はトピックのディレクトリに直接セグメントを置いていた
+		"no partitions": func(dir string) string { return filepath.Join(dir, DefaultTopic) },
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "topics-migrate-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.MaxIndexBytes = entWidth * 2
+			require.NoError(t, os.MkdirAll(layout(dir), 0755))
+			l, err := NewLog(layout(dir), c)
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				_, err = l.Append(&api.Record{Value: []byte("hello world")})
+				require.NoError(t, err)
+			}
+			require.NoError(t, l.Close())
+
+			topics, err := NewTopics(dir, c)
+			require.NoError(t, err)
+			defer topics.Close()
+			for off := uint64(0); off < 3; off++ {
+				read, err := topics.Read(DefaultTopic, 0, off)
+				require.NoError(t, err)
+				require.Equal(t, []byte("hello world"), read.Value)
+			}
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(entries))
+			require.Equal(t, DefaultTopic, entries[0].Name())
+			entries, err = os.ReadDir(filepath.Join(dir, DefaultTopic))
+			require.NoError(t, err)
+			for _, entry := range entries {
+				require.True(t, entry.IsDir() || entry.Name() == topicConfigFile, entry.Name())
+			}
+		})
+	}
+}
+
+// メタデータのスナップショットにすべてのトピックとその設定と、払い出したプロデューサーのIDが含まれることと、
+// パーティションを導入する前のスナップショットのレコードをパーティション0に復元できることをテストする。
+func TestTopicsSnapshot(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-snapshot-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	topics, err := NewTopics(dir, c)
+	require.NoError(t, err)
+	defer topics.Close()
+	_, err = topics.CreateTopic("orders", &api.TopicConfig{Compaction: true, Partitions: 2})
+	require.NoError(t, err)
+	_, err = topics.CreateTopic("empty", nil)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = topics.Append("orders", 0, &api.Record{Value: []byte("order")})
+		require.NoError(t, err)
+	}
+	_, err = topics.Append(DefaultTopic, 0, &api.Record{Value: []byte("default")})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = topics.InitProducer()
+		require.NoError(t, err)
+	}
+
+	snap, err := (&metadataFSM{topics: topics, groups: nopGroups{}}).Snapshot()
+	require.NoError(t, err)
+	b, err := io.ReadAll(snap.(*snapshot).reader)
+	require.NoError(t, err)
+
+	restoreDir, err := os.MkdirTemp("", "topics-snapshot-restore-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(restoreDir)
+	restored, err := NewTopics(restoreDir, c)
+	require.NoError(t, err)
+	defer restored.Close()
+	// 復元前にあったトピックは消える
+	_, err = restored.CreateTopic("stale", nil)
+	require.NoError(t, err)
+	f := &metadataFSM{topics: restored, groups: nopGroups{}}
+	require.NoError(t, f.Restore(io.NopCloser(bytes.NewReader(b))))
+
+	list, err := restored.ListTopics()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(list))
+	require.Equal(t, []string{DefaultTopic, "empty", "orders"}, []string{list[0].Name, list[1].Name, list[2].Name})
+	require.True(t, list[2].Config.Compaction)
+	require.Equal(t, uint32(2), list[2].Config.Partitions)
+	// レコードはパーティションのスナップショットで復元する
+	_, err = restored.Read("orders", 0, 0)
+	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+	// 払い出したプロデューサーのIDは払い出さない
+	id, err := restored.InitProducer()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), id)
+
+	// トピックを導入した後、パーティションを導入する前のスナップショットはトピックごとにレコードを含む
+	var v2 bytes.Buffer
+	v2.WriteString(snapshotMagic)
+	for _, name := range []string{DefaultTopic, "orders"} {
+		l, err := topics.Log(name, 0)
+		require.NoError(t, err)
+		b, err := proto.Marshal(&api.Topic{Name: name, Config: &api.TopicConfig{}})
+		require.NoError(t, err)
+		r, lowest, size := storesOf(l)
+		h := make([]byte, lenWidth)
+		enc.PutUint64(h, uint64(len(b)))
+		v2.Write(h)
+		v2.Write(b)
+		enc.PutUint64(h, lowest)
+		v2.Write(h)
+		enc.PutUint64(h, size)
+		v2.Write(h)
+		_, err = io.Copy(&v2, r)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Restore(io.NopCloser(&v2)))
+	list, err = restored.ListTopics()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list))
+	require.Equal(t, uint32(1), list[1].Config.Partitions)
+	for off := uint64(0); off < 5; off++ {
+		read, err := restored.Read("orders", 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("order"), read.Value)
+	}
+	read, err := restored.Read(DefaultTopic, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("default"), read.Value)
+
+	// トピックを導入する前のスナップショットはデフォルトのトピックのストアそのもの
+	l, err := topics.Log("orders", 0)
+	require.NoError(t, err)
+	legacy, err := io.ReadAll(l.Reader())
+	require.NoError(t, err)
+	require.NoError(t, f.Restore(io.NopCloser(bytes.NewReader(legacy))))
+	list, err = restored.ListTopics()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list))
+	for off := uint64(0); off < 5; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("order"), read.Value)
+	}
+
+	// フレームにチェックサムを入れる前のスナップショットは | length (8) | Record | を並べたもの
+	var baseline bytes.Buffer
+	for off := uint64(3); off < 5; off++ {
+		b, err := proto.Marshal(&api.Record{Value: []byte("baseline"), Offset: off})
+		require.NoError(t, err)
+		h := make([]byte, lenWidth)
+		enc.PutUint64(h, uint64(len(b)))
+		baseline.Write(h)
+		baseline.Write(b)
+	}
+	require.NoError(t, f.Restore(io.NopCloser(&baseline)))
+	_, err = restored.Read(DefaultTopic, 0, 2)
+	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+	for off := uint64(3); off < 5; off++ {
+		read, err := restored.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte("baseline"), read.Value)
+	}
+}
+
+// パーティションを導入する前のログにあるレコードの追加を、適用し直しても重複させずにパーティション0に追加することをテストする。
+func TestTopicsLegacyAppend(t *testing.T) {
+	dir, err := os.MkdirTemp("", "topics-legacy-append-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = 1024
+	topics, err := NewTopics(dir, c)
+	require.NoError(t, err)
+	defer topics.Close()
+
+	var entries []*raft.Log
+	for i := 0; i < 3; i++ {
+		b, err := proto.Marshal(&api.ProduceRequest{Record: &api.Record{Value: []byte(fmt.Sprintf("record %d", i))}})
+		require.NoError(t, err)
+		entries = append(entries, &raft.Log{Data: append([]byte{byte(AppendRequestType)}, b...)})
+	}
+	b, err := proto.Marshal(&api.ProduceBatchRequest{Records: []*api.Record{
+		{Value: []byte("record 3")},
+		{Value: []byte("record 4")},
+	}})
+	require.NoError(t, err)
+	entries = append(entries, &raft.Log{Data: append([]byte{byte(AppendBatchRequestType)}, b...)})
+
+	f := &metadataFSM{topics: topics, groups: nopGroups{}}
+	for _, entry := range entries[:2] {
+		require.Nil(t, f.Apply(entry))
+	}
+	// 再起動すると最初のエントリから適用し直す
+	f = &metadataFSM{topics: topics, groups: nopGroups{}}
+	for _, entry := range entries {
+		require.Nil(t, f.Apply(entry))
+	}
+	for off := uint64(0); off < 5; off++ {
+		read, err := topics.Read(DefaultTopic, 0, off)
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("record %d", off)), read.Value)
+	}
+	_, err = topics.Read(DefaultTopic, 0, 5)
+	require.IsType(t, api.ErrOffsetOutOfRange{}, err)
+}
+
+// 以前の形式のスナップショットのために、ストアを連結したものと、ログの最小のオフセットとストアのバイト数を返す。
+func storesOf(l *Log) (r io.Reader, lowest uint64, size uint64) {
+	readers := make([]io.Reader, len(l.segments))
+	for i, segment := range l.segments {
+		n := segment.store.Size()
+		readers[i] = io.NewSectionReader(segment.store, 0, int64(n))
+		size += n
+	}
+	return io.MultiReader(readers...), l.segments[0].baseOffset, size
+}
+
+// パーティションのグループを起動しないpartitionGroups
+type nopGroups struct{}
+
+func (nopGroups) startTopic(*api.Topic, []*api.Server) error { return nil }
+func (nopGroups) stopTopic(string) error                     { return nil }
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/serf/serf"
+	api "github.com/yurakawa/proglog/api/v1"
+	"github.com/yurakawa/proglog/internal/discovery"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// クラスタのメンバーの変更とログの保守。Adminのサービスのすべてのエンドポイントで使う
+const adminAction = "admin"
+
+var _ api.AdminServer = (*adminServer)(nil)
+
+// adminServer はAdminのサービスを提供する。Logのサービスと同じ設定を使う。
+type adminServer struct {
+	api.UnimplementedAdminServer
+	*Config
+}
+
+// 運用者がクラスタのメンバーとパーティションのログを操作する
+type Admin interface {
+	AddVoter(id, addr string) error
+	RemoveServer(id string) error
+	TransferLeadership() error
+	// トピックとパーティションを受け取って、スナップショットのインデックスとタームを返す
+	TakeSnapshot(string, uint32) (uint64, uint64, error)
+	// トピックとパーティションとオフセットを受け取って、残っている最も古いオフセットを返す
+	TruncateBefore(string, uint32, uint64) (uint64, error)
+}
+
+// Serfから見たクラスタのメンバーを返す
+type MemberLister interface {
+	Members() []serf.Member
+}
+
+// 認可してから、Adminが設定されているか確認する。
+func (s *adminServer) authorize(ctx context.Context) error {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return err
+	}
+	if s.Admin == nil {
+		return status.Error(codes.Unimplemented, "admin is not supported")
+	}
+	return nil
+}
+
+func (s *adminServer) AddVoter(ctx context.Context, req *api.AddVoterRequest) (*api.AddVoterResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Id == "" || req.RpcAddr == "" {
+		return nil, status.Error(codes.InvalidArgument, "id and rpc_addr are required")
+	}
+	if err := s.Admin.AddVoter(req.Id, req.RpcAddr); err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.AddVoter(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.AddVoterResponse{}, nil
+}
+
+func (s *adminServer) RemoveServer(ctx context.Context, req *api.RemoveServerRequest) (*api.RemoveServerResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.Admin.RemoveServer(req.Id); err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.RemoveServer(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.RemoveServerResponse{}, nil
+}
+
+func (s *adminServer) TransferLeadership(
+	ctx context.Context, req *api.TransferLeadershipRequest,
+) (*api.TransferLeadershipResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.Admin.TransferLeadership(); err != nil {
+		return nil, err
+	}
+	return &api.TransferLeadershipResponse{}, nil
+}
+
+func (s *adminServer) TakeSnapshot(ctx context.Context, req *api.TakeSnapshotRequest) (*api.TakeSnapshotResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	index, term, err := s.Admin.TakeSnapshot(req.Topic, req.Partition)
+	if err != nil {
+		return nil, err
+	}
+	return &api.TakeSnapshotResponse{Index: index, Term: term}, nil
+}
+
+func (s *adminServer) TruncateBefore(
+	ctx context.Context, req *api.TruncateBeforeRequest,
+) (*api.TruncateBeforeResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	lowest, err := s.Admin.TruncateBefore(req.Topic, req.Partition, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return &api.TruncateBeforeResponse{LowestOffset: lowest}, nil
+}
+
+// ListMembers はSerfのメンバーとメタデータのグループの構成をIDで突き合わせて返す。
+// Serfから抜けずに止まったノードはRaftにだけ、まだ追加されていないノードはSerfにだけ含まれる。
+func (s *adminServer) ListMembers(ctx context.Context, req *api.ListMembersRequest) (*api.ListMembersResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	members := make(map[string]*api.Member)
+	member := func(id string) *api.Member {
+		m, ok := members[id]
+		if !ok {
+			m = &api.Member{Id: id}
+			members[id] = m
+		}
+		return m
+	}
+	if s.Membership != nil {
+		for _, sm := range s.Membership.Members() {
+			m := member(sm.Name)
+			m.RpcAddr = sm.Tags[discovery.RPCAddrTag]
+			m.SerfStatus = sm.Status.String()
+			m.Role = sm.Tags[discovery.RoleTag]
+		}
+	}
+	if s.GetServerer != nil {
+		servers, err := s.GetServerer.GetServers()
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range servers {
+			m := member(server.Id)
+			// Raftのアドレスが実際に接続しているアドレス
+			m.RpcAddr = server.RpcAddr
+			m.Raft = true
+			m.Suffrage = server.Suffrage
+			m.IsLeader = server.IsLeader
+		}
+	}
+	res := &api.ListMembersResponse{}
+	for _, m := range members {
+		res.Members = append(res.Members, m)
+	}
+	sort.Slice(res.Members, func(i, j int) bool {
+		return res.Members[i].Id < res.Members[j].Id
+	})
+	return res, nil
+}
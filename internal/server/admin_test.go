@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/serf/serf"
+	"github.com/stretchr/testify/require"
+	api "github.com/yurakawa/proglog/api/v1"
+	"github.com/yurakawa/proglog/internal/config"
+	"github.com/yurakawa/proglog/internal/discovery"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AdminのサービスのRPCがadminのアクションで認可されて、Adminに渡されることをテストする。
+func TestAdmin(t *testing.T) {
+	admin := &recordingAdmin{}
+	rootConn, nobodyConn, _, teardown := setupConns(t, func(c *Config) {
+		c.Admin = admin
+	})
+	defer teardown()
+	client := api.NewAdminClient(rootConn)
+	ctx := context.Background()
+
+	_, err := client.AddVoter(ctx, &api.AddVoterRequest{Id: "3", RpcAddr: "127.0.0.1:3"})
+	require.NoError(t, err)
+	_, err = client.AddVoter(ctx, &api.AddVoterRequest{Id: "3"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.RemoveServer(ctx, &api.RemoveServerRequest{Id: "2"})
+	require.NoError(t, err)
+	_, err = client.TransferLeadership(ctx, &api.TransferLeadershipRequest{})
+	require.NoError(t, err)
+	snapshot, err := client.TakeSnapshot(ctx, &api.TakeSnapshotRequest{Topic: "orders", Partition: 1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), snapshot.Index)
+	require.Equal(t, uint64(2), snapshot.Term)
+	truncated, err := client.TruncateBefore(ctx, &api.TruncateBeforeRequest{Topic: "orders", Partition: 1, Offset: 5})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), truncated.LowestOffset)
+	require.Equal(t, []string{
+		"add 3 127.0.0.1:3",
+		"remove 2",
+		"transfer",
+		"snapshot orders/1",
+		"truncate orders/1 before 5",
+	}, admin.calls)
+
+	// リーダーでなければリーダーのアドレスを返す
+	admin.err = api.ErrNotLeader{LeaderAddr: "127.0.0.1:1"}
+	_, err = client.RemoveServer(ctx, &api.RemoveServerRequest{Id: "2"})
+	requireStatus(t, err, codes.FailedPrecondition, true, "127.0.0.1:1")
+
+	// adminのアクションを許可されていなければ、Adminを呼び出さない
+	calls := len(admin.calls)
+	_, err = api.NewAdminClient(nobodyConn).TransferLeadership(ctx, &api.TransferLeadershipRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Equal(t, calls, len(admin.calls))
+}
+
+// フォロワーが受け取ったAddVoterとRemoveServerがリーダーに転送されることをテストする。
+func TestAdminForwardsToLeader(t *testing.T) {
+	leader := &recordingAdmin{}
+	leaderConn, _, _, teardown := setupConns(t, func(c *Config) {
+		c.Admin = leader
+	})
+	defer teardown()
+	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile: config.RootClientCertFile,
+		KeyFile:  config.RootClientKeyFile,
+		CAFile:   config.CAFile,
+	})
+	require.NoError(t, err)
+	forwarder := NewLeaderForwarder(peerTLSConfig)
+	defer forwarder.Close()
+	follower := &recordingAdmin{err: api.ErrNotLeader{LeaderAddr: leaderConn.Target()}}
+	followerConn, _, _, teardown := setupConns(t, func(c *Config) {
+		c.Admin = follower
+		c.Forwarder = forwarder
+	})
+	defer teardown()
+	client := api.NewAdminClient(followerConn)
+	ctx := context.Background()
+
+	_, err = client.AddVoter(ctx, &api.AddVoterRequest{Id: "3", RpcAddr: "127.0.0.1:3"})
+	require.NoError(t, err)
+	_, err = client.RemoveServer(ctx, &api.RemoveServerRequest{Id: "2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"add 3 127.0.0.1:3", "remove 2"}, leader.calls)
+
+	// 転送先もリーダーでなくなっていたら、もう一度は転送せずにリーダーのアドレスを返す
+	leader.err = api.ErrNotLeader{LeaderAddr: "127.0.0.1:1"}
+	_, err = client.RemoveServer(ctx, &api.RemoveServerRequest{Id: "2"})
+	requireStatus(t, err, codes.FailedPrecondition, true, "127.0.0.1:1")
+}
+
+// Adminが設定されていなければUnimplementedを返すことをテストする。
+func TestAdminUnimplemented(t *testing.T) {
+	rootConn, _, _, teardown := setupConns(t, nil)
+	defer teardown()
+	_, err := api.NewAdminClient(rootConn).TransferLeadership(context.Background(), &api.TransferLeadershipRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+// ListMembersがSerfとRaftのメンバーをIDで突き合わせて返すことをテストする。
+func TestAdminListMembers(t *testing.T) {
+	tags := func(rpcAddr string, role discovery.Role) map[string]string {
+		return map[string]string{discovery.RPCAddrTag: rpcAddr, discovery.RoleTag: string(role)}
+	}
+	rootConn, _, _, teardown := setupConns(t, func(c *Config) {
+		c.Admin = &recordingAdmin{}
+		c.GetServerer = &fixedServerer{servers: []*api.Server{
+			{Id: "0", RpcAddr: "127.0.0.1:1", IsLeader: true},
+			{Id: "1", RpcAddr: "127.0.0.1:2"},
+			{Id: "2", RpcAddr: "127.0.0.1:3", Suffrage: api.Suffrage_NONVOTER},
+		}}
+		c.Membership = fixedMembers{
+			{Name: "3", Status: serf.StatusAlive, Tags: tags("127.0.0.1:4", discovery.RoleVoter)},
+			{Name: "0", Status: serf.StatusAlive, Tags: tags("127.0.0.1:1", discovery.RoleVoter)},
+			{Name: "2", Status: serf.StatusFailed, Tags: tags("127.0.0.1:3", discovery.RoleNonvoter)},
+		}
+	})
+	defer teardown()
+	res, err := api.NewAdminClient(rootConn).ListMembers(context.Background(), &api.ListMembersRequest{})
+	require.NoError(t, err)
+	want := []*api.Member{
+		{Id: "0", RpcAddr: "127.0.0.1:1", SerfStatus: "alive", Role: "voter", Raft: true, IsLeader: true},
+		// Serfから抜けずに止まったノードはRaftにだけ含まれる
+		{Id: "1", RpcAddr: "127.0.0.1:2", Raft: true},
+		{Id: "2", RpcAddr: "127.0.0.1:3", SerfStatus: "failed", Role: "nonvoter", Raft: true, Suffrage: api.Suffrage_NONVOTER},
+		// まだクラスタに追加されていない
+		{Id: "3", RpcAddr: "127.0.0.1:4", SerfStatus: "alive", Role: "voter"},
+	}
+	require.Equal(t, len(want), len(res.Members))
+	for i, m := range want {
+		require.True(t, proto.Equal(m, res.Members[i]), res.Members[i].String())
+	}
+}
+
+// 呼び出されたメソッドと引数を記録する
+type recordingAdmin struct {
+	calls []string
+	// nilでなければ、すべてのメソッドでこのエラーを返す
+	err error
+}
+
+func (a *recordingAdmin) record(format string, args ...interface{}) error {
+	a.calls = append(a.calls, fmt.Sprintf(format, args...))
+	return a.err
+}
+
+func (a *recordingAdmin) AddVoter(id, addr string) error {
+	return a.record("add %s %s", id, addr)
+}
+
+func (a *recordingAdmin) RemoveServer(id string) error {
+	return a.record("remove %s", id)
+}
+
+func (a *recordingAdmin) TransferLeadership() error {
+	return a.record("transfer")
+}
+
+func (a *recordingAdmin) TakeSnapshot(topic string, partition uint32) (uint64, uint64, error) {
+	return 10, 2, a.record("snapshot %s/%d", topic, partition)
+}
+
+func (a *recordingAdmin) TruncateBefore(topic string, partition uint32, off uint64) (uint64, error) {
+	return off - 1, a.record("truncate %s/%d before %d", topic, partition, off)
+}
+
+type fixedMembers []serf.Member
+
+func (m fixedMembers) Members() []serf.Member {
+	return m
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/raft"
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 送り直すまでに待つ時間。リーダーの交代はRaftの選挙のタイムアウトほどで終わる
+const (
+	unavailableRetryDelay       = 100 * time.Millisecond
+	resourceExhaustedRetryDelay = time.Second
+)
+
+// ハンドラが返したエラーを、クライアントが送り直すかどうかを決められるgRPCのステータスに変換する。
+func errorUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	res, err := handler(ctx, req)
+	return res, translateError(err)
+}
+
+func errorStreamServerInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	return translateError(handler(srv, stream))
+}
+
+// すでにステータスを持つエラーはそのまま返し、それ以外はRaftやディスクのエラーを
+// api/v1の型のエラーにする。どれにも当てはまらなければInternalにする。
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus().Err()
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded),
+		errors.Is(err, raft.ErrEnqueueTimeout):
+		return api.ErrTimeout{Reason: err.Error()}
+	case errors.Is(err, raft.ErrNotLeader):
+		// エントリは追加されていないので、リーダーに送り直せる
+		return api.ErrNotLeader{}
+	case errors.Is(err, raft.ErrLeadershipLost),
+		errors.Is(err, raft.ErrLeadershipTransferInProgress),
+		errors.Is(err, raft.ErrRaftShutdown),
+		errors.Is(err, raft.ErrAbortedByRestore):
+		return api.ErrUnavailable{Reason: err.Error(), RetryDelay: unavailableRetryDelay}
+	case errors.Is(err, syscall.ENOSPC):
+		return api.ErrResourceExhausted{Reason: err.Error(), RetryDelay: resourceExhaustedRetryDelay}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// インデックスにあるはずのエントリが読み出せなかった
+		return api.ErrDataLoss{Reason: err.Error()}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	api "github.com/yurakawa/proglog/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// 転送したリクエストにつけるメタデータのキー。転送先もリーダーでなくなっていたときに、
+// さらに転送を繰り返さないようにするために使う。
+const forwardedKey = "proglog-forwarded"
+
+// LeaderForwarder はフォロワーが受け取ったProduceやクラスタのメンバーの変更をリーダーに転送する。
+// リーダーへの接続はノード間の通信に使うピアのTLS設定で作るので、リーダーはクライアントではなく
+// このノードのピア証明書で認可する。クライアントの認可は転送する前にフォロワーで済ませている。
+type LeaderForwarder struct {
+	opts []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewLeaderForwarder はpeerTLSConfigでリーダーに接続するLeaderForwarderを作る。nilならTLSを使わない。
+func NewLeaderForwarder(peerTLSConfig *tls.Config) *LeaderForwarder {
+	creds := insecure.NewCredentials()
+	if peerTLSConfig != nil {
+		creds = credentials.NewTLS(peerTLSConfig)
+	}
+	return &LeaderForwarder{
+		opts:  []grpc.DialOption{grpc.WithTransportCredentials(creds)},
+		conns: make(map[string]*grpc.ClientConn),
+	}
+}
+
+// addrのノードへの接続を返す。接続はノードごとに使い回す。
+func (f *LeaderForwarder) conn(addr string) (*grpc.ClientConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conn, ok := f.conns[addr]
+	if !ok {
+		var err error
+		if conn, err = grpc.Dial(addr, f.opts...); err != nil {
+			return nil, err
+		}
+		f.conns[addr] = conn
+	}
+	return conn, nil
+}
+
+// addrのノードのクライアントを返す。
+func (f *LeaderForwarder) client(addr string) (api.LogClient, error) {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewLogClient(conn), nil
+}
+
+// Close はリーダーへの接続をすべて閉じる。
+func (f *LeaderForwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	for addr, conn := range f.conns {
+		if e := conn.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(f.conns, addr)
+	}
+	return err
+}
+
+// errがapi.ErrNotLeaderで転送できる場合に、リーダーのクライアントと転送に使うコンテキストを返す。
+// 転送されてきたリクエストはもう一度転送しない。
+func (s *grpcServer) leaderClient(ctx context.Context, err error) (api.LogClient, context.Context, bool) {
+	conn, ctx, ok := s.leaderConn(ctx, err)
+	if !ok {
+		return nil, nil, false
+	}
+	return api.NewLogClient(conn), ctx, true
+}
+
+// leaderClientと同じ条件で、Adminのサービスのクライアントを返す。
+func (s *adminServer) leaderClient(ctx context.Context, err error) (api.AdminClient, context.Context, bool) {
+	conn, ctx, ok := s.leaderConn(ctx, err)
+	if !ok {
+		return nil, nil, false
+	}
+	return api.NewAdminClient(conn), ctx, true
+}
+
+func (c *Config) leaderConn(ctx context.Context, err error) (*grpc.ClientConn, context.Context, bool) {
+	notLeader, ok := err.(api.ErrNotLeader)
+	if !ok || c.Forwarder == nil || notLeader.LeaderAddr == "" {
+		return nil, nil, false
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedKey)) > 0 {
+		return nil, nil, false
+	}
+	conn, err := c.Forwarder.conn(notLeader.LeaderAddr)
+	if err != nil {
+		return nil, nil, false
+	}
+	return conn, metadata.AppendToOutgoingContext(ctx, forwardedKey, "true"), true
+}
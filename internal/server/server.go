@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"io"
+	"sync"
 	"time"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -20,20 +22,56 @@ import (
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type Config struct {
 	CommitLog   CommitLog
 	Authorizer  Authorizer
 	GetServerer GetServerer
+	// nilなら単一ノードとみなして、一貫性の指定によらずローカルのログから読み出す
+	ReadVerifier ReadVerifier
+	// nilでなければ、フォロワーが受け取ったProduceとProduceBatchとトピックの作成と削除と、
+	// AddVoterとRemoveServerをリーダーに転送する。
+	// nilならリーダーのアドレスを持つFailedPreconditionを返す
+	Forwarder *LeaderForwarder
+	// nilならトピックのRPCはUnimplementedを返す
+	TopicManager TopicManager
+	// nilならDescribeLogはUnimplementedを返す
+	LogDescriber LogDescriber
+	// DescribeLogでclusterが指定されたときに、ほかのノードの状態を取得するのに使う。
+	// nilならほかのノードはエラーとして返す
+	Peers *LeaderForwarder
+	// nilならAdminのサービスのRPCはUnimplementedを返す
+	Admin Admin
+	// nilならListMembersはRaftのメンバーだけを返す
+	Membership MemberLister
+	// nilでなければ、ヘルスチェックのサービスとして登録する。止める前にNOT_SERVINGにできるように渡す。
+	// nilなら常にSERVINGを返すものを登録する
+	Health *health.Server
+	// 閉じられたら、レコードの追加を待っているConsumeStreamをUnavailableで終わらせる。
+	// 止めるときにNOT_SERVINGにしてから閉じれば、GracefulStopが追いかけているストリームを待ち続けない
+	Draining <-chan struct{}
 }
 
 const (
 	objectWildcard = "*"
 	produceAction  = "produce"
 	consumeAction  = "consume"
+	// トピックの作成と削除。トピックの一覧はconsumeで取得できる
+	manageTopicAction = "manage_topic"
+	// コンシューマーグループのオフセットのコミット。コミットしたオフセットの取得はconsumeで行える
+	commitAction = "commit"
+
+	// ConsumeBatchでmax_recordsが指定されなかったときに返すレコードの件数
+	defaultConsumeBatchRecords = 100
+	// ProduceStreamで溜まっているリクエストをまとめて追加するときの最大件数
+	maxProduceStreamBatch = 100
 )
 
+// min_offsetのレコードが適用されるのを待つ最大の時間。過ぎたらapi.ErrStaleReadを返す
+var minOffsetTimeout = 3 * time.Second
+
 var _ api.LogServer = (*grpcServer)(nil)
 
 type grpcServer struct {
@@ -80,19 +118,24 @@ func NewGRPCServer(config *Config, grpcOpts ...grpc.ServerOption) (
 			grpc_middleware.ChainStreamServer(
 				grpc_ctxtags.StreamServerInterceptor(),
 				grpc_zap.StreamServerInterceptor(logger, zapOpts...), // gRPC呼び出しをログに記録する
+				errorStreamServerInterceptor,                         // 変換したステータスのコードをログに記録させる
 				grpc_auth.StreamServerInterceptor(authenticate),
 			)),
 		grpc.UnaryInterceptor(
 			grpc_middleware.ChainUnaryServer(
 				grpc_ctxtags.UnaryServerInterceptor(),
 				grpc_zap.UnaryServerInterceptor(logger, zapOpts...),
+				errorUnaryServerInterceptor,
 				grpc_auth.UnaryServerInterceptor(authenticate),
 			)),
 		grpc.StatsHandler(&ocgrpc.ServerHandler{}), // OpenCensusをサーバの統計情報(stats)ハンドラとして使う
 	)
 	gsrv := grpc.NewServer(grpcOpts...)
 
-	hsrv := health.NewServer()
+	hsrv := config.Health
+	if hsrv == nil {
+		hsrv = health.NewServer()
+	}
 	hsrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
 	healthpb.RegisterHealthServer(gsrv, hsrv)
 
@@ -101,6 +144,7 @@ func NewGRPCServer(config *Config, grpcOpts ...grpc.ServerOption) (
 		return nil, err
 	}
 	api.RegisterLogServer(gsrv, srv)
+	api.RegisterAdminServer(gsrv, &adminServer{Config: config})
 	return gsrv, nil
 }
 
@@ -116,71 +160,550 @@ func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api
 	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, produceAction); err != nil {
 		return nil, err
 	}
-	offset, err := s.CommitLog.Append(req.Record)
+	partition, err := s.partition(req.Topic, req.Partition, req.Record.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	// 転送先でも同じパーティションに追加させる
+	req.Partition = &partition
+	offset, err := s.append(req)
 	if err != nil {
-		// 生でエラーを返してる
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.Produce(ctx, req)
+		}
 		return nil, err
 	}
-	return &api.ProduceResponse{Offset: offset}, nil
+	return &api.ProduceResponse{Offset: offset, Partition: partition, CorrelationId: req.CorrelationId}, nil
+}
+
+// パーティションが決まったリクエストのレコードを追加する。プロデューサーのIDがあれば再送されたものは追加しない。
+func (s *grpcServer) append(req *api.ProduceRequest) (uint64, error) {
+	if req.ProducerId == 0 {
+		return s.CommitLog.Append(req.Topic, *req.Partition, req.Record)
+	}
+	offsets, err := s.CommitLog.AppendIdempotent(
+		req.Topic, *req.Partition, req.ProducerId, req.Sequence, []*api.Record{req.Record},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return offsets[0], nil
+}
+
+// 指定されたパーティションを返す。指定されていなければ、キーからレコードを追加するパーティションを選ぶ。
+func (s *grpcServer) partition(topic string, partition *uint32, key []byte) (uint32, error) {
+	if partition != nil {
+		return *partition, nil
+	}
+	return s.CommitLog.Partition(topic, key)
 }
 
 func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
 	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
 		return nil, err
 	}
-	record, err := s.CommitLog.Read(req.Offset)
+	if err := s.verifyRead(ctx, req.Topic, req.Partition, req.Consistency, req.MinOffset); err != nil {
+		return nil, err
+	}
+	record, err := s.CommitLog.Read(req.Topic, req.Partition, req.Offset)
 	if err != nil {
-		// 生でエラーを返してる
+		// ステータスへの変換はerrorUnaryServerInterceptorで行う
 		return nil, err
 	}
 	return &api.ConsumeResponse{Record: record}, nil
 }
 
+// 複数のレコードをまとめて1つのパーティションに追加する。
+// パーティションが指定されていなければ、最初のレコードのキーから選ぶ。
+func (s *grpcServer) ProduceBatch(ctx context.Context, req *api.ProduceBatchRequest) (*api.ProduceBatchResponse, error) {
+	var key []byte
+	if len(req.Records) > 0 {
+		key = req.Records[0].Key
+	}
+	partition, err := s.partition(req.Topic, req.Partition, key)
+	if err != nil {
+		return nil, err
+	}
+	req.Partition = &partition
+	offsets, err := s.produceBatch(ctx, req)
+	if err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.ProduceBatch(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.ProduceBatchResponse{Offsets: offsets, Partition: partition}, nil
+}
+
+// パーティションが決まったバッチを追加する。プロデューサーのIDがあれば再送されたバッチは追加しない。
+func (s *grpcServer) produceBatch(ctx context.Context, req *api.ProduceBatchRequest) ([]uint64, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, produceAction); err != nil {
+		return nil, err
+	}
+	if req.ProducerId == 0 {
+		return s.CommitLog.AppendBatch(req.Topic, *req.Partition, req.Records)
+	}
+	return s.CommitLog.AppendIdempotent(req.Topic, *req.Partition, req.ProducerId, req.Sequence, req.Records)
+}
+
+// 指定されたオフセットから、件数とバイト数の上限までのレコードを読み出す。
+// 圧縮で削除されたオフセットは飛ばす。最初のオフセットがログの範囲外ならエラーを返す。
+func (s *grpcServer) ConsumeBatch(ctx context.Context, req *api.ConsumeBatchRequest) (*api.ConsumeBatchResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return nil, err
+	}
+	if err := s.verifyRead(ctx, req.Topic, req.Partition, req.Consistency, req.MinOffset); err != nil {
+		return nil, err
+	}
+	maxRecords := int(req.MaxRecords)
+	if maxRecords == 0 {
+		maxRecords = defaultConsumeBatchRecords
+	}
+	res := &api.ConsumeBatchResponse{}
+	var size uint64
+	for off := req.Offset; len(res.Records) < maxRecords; off++ {
+		record, err := s.CommitLog.Read(req.Topic, req.Partition, off)
+		switch err.(type) {
+		case nil:
+		case api.ErrCompacted:
+			continue
+		case api.ErrOffsetOutOfRange:
+			if len(res.Records) == 0 {
+				return nil, err
+			}
+			return res, nil
+		default:
+			return nil, err
+		}
+		size += uint64(proto.Size(record))
+		if req.MaxBytes > 0 && size > req.MaxBytes && len(res.Records) > 0 {
+			break
+		}
+		res.Records = append(res.Records, record)
+	}
+	return res, nil
+}
+
 // ProduceStreamは双方向ストリーミングRPCを実装している。
 // クライアントは複数のリクエストをサーバへストリーミングでき、サーバは各リクエストが成功した稼働をかクライアントに伝えられる。
+// 追加している間に届いたリクエストは溜めておき、次にまとめて追加する。レスポンスはリクエストの順に返す。
+// キーもパーティションも指定されていないレコードは、溜まっているリクエストと同じパーティションに追加する。
+// 追加できなかったレコードはレスポンスのerrorで伝えてストリームを続け、送受信に失敗したときだけストリームを閉じる。
 func (s *grpcServer) ProduceStream(stream api.Log_ProduceStreamServer) error {
+	recvc := make(chan produceStreamRecv, maxProduceStreamBatch)
+	go recvProduceStream(stream, recvc)
+	// 前のバッチとトピックかパーティションが違ったので次のバッチに回したリクエストと、そのパーティションか選べなかったエラー
+	var next *api.ProduceRequest
+	var nextPartition uint32
+	var nextErr error
 	for {
-		req, err := stream.Recv()
+		req, partition, err := next, nextPartition, nextErr
+		if req == nil {
+			r := <-recvc
+			if r.err != nil {
+				return produceStreamRecvError(r.err)
+			}
+			req = r.req
+			partition, err = s.partition(req.Topic, req.Partition, req.Record.GetKey())
+		}
+		next, nextErr = nil, nil
 		if err != nil {
+			if err := sendProduceStream(stream, []*api.ProduceRequest{req}, partition, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		reqs := []*api.ProduceRequest{req}
+		batch := &api.ProduceBatchRequest{
+			Topic:      req.Topic,
+			Partition:  &partition,
+			Records:    []*api.Record{req.Record},
+			ProducerId: req.ProducerId,
+			Sequence:   req.Sequence,
+		}
+		// 溜まっているリクエストのうち、同じトピックとパーティションのものを待たずに取り出す。
+		// プロデューサーのIDがあれば、同じプロデューサーの続きの番号のものだけをまとめる
+		var recvErr error
+	drain:
+		for len(batch.Records) < maxProduceStreamBatch {
+			select {
+			case r := <-recvc:
+				if r.err != nil {
+					recvErr = r.err
+					break drain
+				}
+				// 指定されたパーティションは引き継がない。存在しないパーティションだと後のレコードまで失敗する
+				p := partition
+				if r.req.Partition != nil || len(r.req.Record.GetKey()) > 0 || r.req.Topic != batch.Topic || req.Partition != nil {
+					var err error
+					if p, err = s.partition(r.req.Topic, r.req.Partition, r.req.Record.GetKey()); err != nil {
+						next, nextErr = r.req, err
+						break drain
+					}
+				}
+				if r.req.Topic != batch.Topic || p != partition || r.req.ProducerId != batch.ProducerId ||
+					(batch.ProducerId != 0 && r.req.Sequence != batch.Sequence+uint64(len(batch.Records))) {
+					next, nextPartition = r.req, p
+					break drain
+				}
+				reqs = append(reqs, r.req)
+				batch.Records = append(batch.Records, r.req.Record)
+			default:
+				break drain
+			}
+		}
+		offsets, err := s.produceStreamBatch(stream.Context(), batch)
+		if err != nil && stream.Context().Err() != nil {
+			// ストリームがキャンセルされたので、レスポンスは届かない
 			return err
 		}
-		res, err := s.Produce(stream.Context(), req)
-		if err != nil {
+		if err := sendProduceStream(stream, reqs, partition, offsets, err); err != nil {
 			return err
 		}
+		if recvErr != nil {
+			return produceStreamRecvError(recvErr)
+		}
+	}
+}
+
+// reqsのレスポンスを順に送る。errがnilでなければ、すべてのリクエストにそのエラーを返す。
+func sendProduceStream(
+	stream api.Log_ProduceStreamServer,
+	reqs []*api.ProduceRequest,
+	partition uint32,
+	offsets []uint64,
+	err error,
+) error {
+	var produceErr *api.ProduceError
+	if err != nil {
+		produceErr = api.NewProduceError(translateError(err))
+	}
+	for i, req := range reqs {
+		res := &api.ProduceResponse{
+			Partition:     partition,
+			CorrelationId: req.CorrelationId,
+			Error:         produceErr,
+		}
+		if err == nil {
+			res.Offset = offsets[i]
+		}
 		if err := stream.Send(res); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// クライアントが送信を終えたなら、正常にストリームを閉じる。
+func produceStreamRecvError(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// ProduceStreamのバッチをパーティションに追加する。このノードがパーティションのリーダーでなければ、
+// ProduceBatchとしてリーダーに転送する。
+func (s *grpcServer) produceStreamBatch(ctx context.Context, req *api.ProduceBatchRequest) ([]uint64, error) {
+	offsets, err := s.produceBatch(ctx, req)
+	if err == nil {
+		return offsets, nil
+	}
+	client, ctx, ok := s.leaderClient(ctx, err)
+	if !ok {
+		return nil, err
+	}
+	res, err := client.ProduceBatch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Offsets, nil
+}
+
+type produceStreamRecv struct {
+	req *api.ProduceRequest
+	err error
+}
+
+// ストリームからリクエストを受信してrecvcに送る。受信に失敗したらそのエラーを送って終了する。
+func recvProduceStream(stream api.Log_ProduceStreamServer, recvc chan<- produceStreamRecv) {
+	for {
+		req, err := stream.Recv()
+		select {
+		case recvc <- produceStreamRecv{req: req, err: err}:
+		case <-stream.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
 }
 
 // ConsumeStreamはサーバ側のストリーミングRPCを実装しているので、クライアントはサーバにログ内のどのレコードを読み出すかを指示でき
 // サーバはそのレコード移行のまだ書き込まれていたにレコードも含めてすべてのレコードをスクリーミングする。
+// ログの末尾に達したら、次のレコードが追加されるまでWaitForOffsetで待つ。
+// end_offsetかmax_recordsかmax_bytesに達したら、正常にストリームを閉じる。
 func (s *grpcServer) ConsumeStream(req *api.ConsumeRequest, stream api.Log_ConsumeStreamServer) error {
+	ctx := stream.Context()
+	// 認可と一貫性の確認はストリームの最初に1回だけ行い、その後はログから直接読み出す
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return err
+	}
+	if err := s.verifyRead(ctx, req.Topic, req.Partition, req.Consistency, req.MinOffset); err != nil {
+		return err
+	}
+	offset, err := s.startOffset(req)
+	if err != nil {
+		return err
+	}
+	waitCtx, cancel := s.drainContext(ctx)
+	defer cancel()
+	// 直前にレコードの追加を待ったかどうか
+	var waited bool
+	// 送ったレコードの件数とバイト数
+	var sent, size uint64
 	for {
-		select {
-		// stream.Context().Done()を受けたとき
-		case <-stream.Context().Done():
+		// end_offsetに達したら閉じる
+		if req.EndOffset != nil && offset >= *req.EndOffset {
 			return nil
-		default:
-			// TODO:[訳注]スピンループしそうなのでsleepを入れる
-			res, err := s.Consume(stream.Context(), req)
-			switch err.(type) {
-			case nil:
-			case api.ErrOffsetOutOfRange:
-				time.Sleep(time.Second)
-				continue
-			default:
+		}
+		record, err := s.CommitLog.Read(req.Topic, req.Partition, offset)
+		switch err.(type) {
+		case nil:
+		case api.ErrOffsetOutOfRange:
+			if waited {
+				// 追加済みなのに読めないのは、保持期間などで削除されたログの先頭より前のオフセット
 				return err
 			}
-			if err = stream.Send(res); err != nil {
+			if err := s.CommitLog.WaitForOffset(waitCtx, req.Topic, req.Partition, offset); err != nil {
+				if ctx.Err() != nil {
+					// ストリームがキャンセルされた
+					return nil
+				}
+				if waitCtx.Err() != nil {
+					return status.Error(codes.Unavailable, "server is shutting down")
+				}
+				// 待っている間にトピックが削除されたか、ノードを止めるためにログが閉じられた
 				return err
 			}
-			req.Offset++
+			waited = true
+			continue
+		case api.ErrCompacted:
+			// 圧縮で削除されたレコードは飛ばして次のレコードを読む
+			waited = false
+			offset++
+			continue
+		default:
+			return err
+		}
+		waited = false
+		if req.MaxBytes > 0 {
+			size += uint64(proto.Size(record))
+			if size > req.MaxBytes && sent > 0 {
+				return nil
+			}
+		}
+		if err = stream.Send(&api.ConsumeResponse{Record: record}); err != nil {
+			return err
+		}
+		sent++
+		// 次のレコードは入らないので、追加されるのを待たずに閉じる
+		if (req.MaxRecords > 0 && sent >= req.MaxRecords) ||
+			(req.MaxBytes > 0 && size >= req.MaxBytes) {
+			return nil
 		}
+		offset++
 	}
 }
 
+// ctxがキャンセルされるか、サーバを止め始めたら終わるコンテキストを返す。
+func (s *grpcServer) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if s.Draining != nil {
+		go func() {
+			select {
+			case <-s.Draining:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// ConsumeStreamで最初に読み出すオフセットを返す。グループがコミットしていればそのオフセットから、
+// なければstart_positionの位置から読み出す。ログの先頭より前ならapi.ErrOffsetBeforeLowestを返す。
+func (s *grpcServer) startOffset(req *api.ConsumeRequest) (uint64, error) {
+	if req.Group != "" {
+		// グループがコミットしたオフセットから再開する
+		off, err := s.CommitLog.FetchCommittedOffset(req.Topic, req.Partition, req.Group)
+		switch err.(type) {
+		case nil:
+			return s.checkStartOffset(req.Topic, req.Partition, off)
+		case api.ErrNoCommittedOffset:
+		default:
+			return 0, err
+		}
+	}
+	switch req.StartPosition {
+	case api.StartPosition_EARLIEST:
+		lowest, _, err := s.CommitLog.OffsetRange(req.Topic, req.Partition)
+		return lowest, err
+	case api.StartPosition_LATEST:
+		_, next, err := s.CommitLog.OffsetRange(req.Topic, req.Partition)
+		return next, err
+	case api.StartPosition_TIMESTAMP:
+		if req.StartTime == nil {
+			return 0, status.Error(codes.InvalidArgument, "start_time is required to start from a timestamp")
+		}
+		return s.CommitLog.OffsetForTime(req.Topic, req.Partition, req.StartTime.AsTime())
+	default:
+		return s.checkStartOffset(req.Topic, req.Partition, req.Offset)
+	}
+}
+
+func (s *grpcServer) checkStartOffset(topic string, partition uint32, off uint64) (uint64, error) {
+	lowest, next, err := s.CommitLog.OffsetRange(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+	if off < lowest {
+		return 0, api.ErrOffsetBeforeLowest{Offset: off, LowestOffset: lowest, NextOffset: next}
+	}
+	return off, nil
+}
+
+// 読み出す前に、min_offsetのレコードがローカルのログに適用されるのを待ち、consistencyを満たしているか確認する。
+func (s *grpcServer) verifyRead(
+	ctx context.Context,
+	topic string,
+	partition uint32,
+	consistency api.Consistency,
+	minOffset *uint64,
+) error {
+	if minOffset != nil {
+		waitCtx, cancel := context.WithTimeout(ctx, minOffsetTimeout)
+		defer cancel()
+		if err := s.CommitLog.WaitForOffset(waitCtx, topic, partition, *minOffset); err != nil {
+			return api.ErrStaleRead{MinOffset: *minOffset}
+		}
+	}
+	if s.ReadVerifier == nil {
+		return nil
+	}
+	return s.ReadVerifier.VerifyRead(ctx, topic, partition, consistency)
+}
+
+// 指定された時刻以降に追加された最初のレコードのオフセットを返す。
+// クライアントはこのオフセットからConsumeStreamすれば、その時刻以降のレコードを読み直せる。
+func (s *grpcServer) GetOffsetForTime(
+	ctx context.Context, req *api.GetOffsetForTimeRequest,
+) (*api.GetOffsetForTimeResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return nil, err
+	}
+	offset, err := s.CommitLog.OffsetForTime(req.Topic, req.Partition, req.Time.AsTime())
+	if err != nil {
+		return nil, err
+	}
+	return &api.GetOffsetForTimeResponse{Offset: offset}, nil
+}
+
+// コンシューマーグループが次に読み出すオフセットをコミットする。
+// このノードがパーティションのリーダーでなければ、Produceと同じようにリーダーに転送する。
+func (s *grpcServer) CommitOffset(ctx context.Context, req *api.CommitOffsetRequest) (*api.CommitOffsetResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, commitAction); err != nil {
+		return nil, err
+	}
+	if err := s.CommitLog.CommitOffset(req.Topic, req.Partition, req.Group, req.Offset); err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.CommitOffset(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.CommitOffsetResponse{}, nil
+}
+
+// コンシューマーグループがコミットしたオフセットを返す。まだコミットしていなければNotFoundを返す。
+func (s *grpcServer) FetchCommittedOffset(
+	ctx context.Context, req *api.FetchCommittedOffsetRequest,
+) (*api.FetchCommittedOffsetResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return nil, err
+	}
+	if err := s.verifyRead(ctx, req.Topic, req.Partition, req.Consistency, nil); err != nil {
+		return nil, err
+	}
+	offset, err := s.CommitLog.FetchCommittedOffset(req.Topic, req.Partition, req.Group)
+	if err != nil {
+		return nil, err
+	}
+	return &api.FetchCommittedOffsetResponse{Offset: offset}, nil
+}
+
+// 冪等なプロデューサーのIDを払い出す。このノードがメタデータのリーダーでなければリーダーに転送する。
+func (s *grpcServer) InitProducer(ctx context.Context, req *api.InitProducerRequest) (*api.InitProducerResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, produceAction); err != nil {
+		return nil, err
+	}
+	id, err := s.CommitLog.InitProducer()
+	if err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.InitProducer(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.InitProducerResponse{ProducerId: id}, nil
+}
+
+func (s *grpcServer) CreateTopic(ctx context.Context, req *api.CreateTopicRequest) (*api.CreateTopicResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, manageTopicAction); err != nil {
+		return nil, err
+	}
+	if s.TopicManager == nil {
+		return nil, status.Error(codes.Unimplemented, "topics are not supported")
+	}
+	topic, err := s.TopicManager.CreateTopic(req.Name, req.Config)
+	if err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.CreateTopic(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.CreateTopicResponse{Topic: topic}, nil
+}
+
+func (s *grpcServer) DeleteTopic(ctx context.Context, req *api.DeleteTopicRequest) (*api.DeleteTopicResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, manageTopicAction); err != nil {
+		return nil, err
+	}
+	if s.TopicManager == nil {
+		return nil, status.Error(codes.Unimplemented, "topics are not supported")
+	}
+	if err := s.TopicManager.DeleteTopic(req.Name); err != nil {
+		if client, ctx, ok := s.leaderClient(ctx, err); ok {
+			return client.DeleteTopic(ctx, req)
+		}
+		return nil, err
+	}
+	return &api.DeleteTopicResponse{}, nil
+}
+
+func (s *grpcServer) ListTopics(ctx context.Context, req *api.ListTopicsRequest) (*api.ListTopicsResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return nil, err
+	}
+	if s.TopicManager == nil {
+		return nil, status.Error(codes.Unimplemented, "topics are not supported")
+	}
+	topics, err := s.TopicManager.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListTopicsResponse{Topics: topics}, nil
+}
+
 func (s *grpcServer) GetServers(
 	ctx context.Context, req *api.GetServersRequest,
 ) (*api.GetServersResponse, error) {
@@ -188,16 +711,121 @@ func (s *grpcServer) GetServers(
 	if err != nil {
 		return nil, err
 	}
-	return &api.GetServersResponse{Servers: servers}, nil
+	partitions, err := s.GetServerer.GetPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return &api.GetServersResponse{Servers: servers, Partitions: partitions}, nil
+}
+
+// DescribeLog はパーティションのログとRaftのグループの状態を返す。clusterが指定されたら、
+// メタデータのグループのリーダーだけがほかのノードに問い合わせて、すべてのノードの状態をまとめて返す。
+func (s *grpcServer) DescribeLog(ctx context.Context, req *api.DescribeLogRequest) (*api.DescribeLogResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+		return nil, err
+	}
+	if s.LogDescriber == nil {
+		return nil, status.Error(codes.Unimplemented, "describing logs is not supported")
+	}
+	local, err := s.LogDescriber.DescribeLog(req.Topic, req.Partition)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Cluster || s.GetServerer == nil {
+		return &api.DescribeLogResponse{Nodes: []*api.NodeLog{local}}, nil
+	}
+	servers, err := s.GetServerer.GetServers()
+	if err != nil {
+		return nil, err
+	}
+	var leader *api.Server
+	for _, server := range servers {
+		if server.IsLeader {
+			leader = server
+		}
+	}
+	if leader == nil {
+		return nil, api.ErrNotLeader{}
+	}
+	if leader.Id != local.Id {
+		return nil, api.ErrNotLeader{LeaderAddr: leader.RpcAddr}
+	}
+	nodes := make([]*api.NodeLog, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		if server.Id == local.Id {
+			nodes[i] = local
+			continue
+		}
+		wg.Add(1)
+		go func(i int, server *api.Server) {
+			defer wg.Done()
+			nodes[i] = s.describePeer(ctx, server, req)
+		}(i, server)
+	}
+	wg.Wait()
+	return &api.DescribeLogResponse{Nodes: nodes}, nil
+}
+
+// ほかのノードのパーティションのログの状態を返す。取得できなければエラーを持つNodeLogを返す。
+func (s *grpcServer) describePeer(ctx context.Context, server *api.Server, req *api.DescribeLogRequest) *api.NodeLog {
+	failed := func(err error) *api.NodeLog {
+		return &api.NodeLog{Id: server.Id, RpcAddr: server.RpcAddr, Error: err.Error()}
+	}
+	if s.Peers == nil {
+		return failed(status.Error(codes.Unimplemented, "peers are not configured"))
+	}
+	client, err := s.Peers.client(server.RpcAddr)
+	if err != nil {
+		return failed(err)
+	}
+	res, err := client.DescribeLog(ctx, &api.DescribeLogRequest{Topic: req.Topic, Partition: req.Partition})
+	if err != nil {
+		return failed(err)
+	}
+	if len(res.Nodes) != 1 {
+		return failed(status.Errorf(codes.Internal, "got %d nodes", len(res.Nodes)))
+	}
+	return res.Nodes[0]
 }
 
+// GetPartitionsはトピックのパーティションごとのリーダーを返す
 type GetServerer interface {
 	GetServers() ([]*api.Server, error)
+	GetPartitions() ([]*api.Partition, error)
+}
+
+type ReadVerifier interface {
+	VerifyRead(ctx context.Context, topic string, partition uint32, consistency api.Consistency) error
 }
 
+// トピックが空ならデフォルトのトピックを使う。Partitionはキーからレコードを追加するパーティションを選ぶ
 type CommitLog interface {
-	Append(*api.Record) (uint64, error)
-	Read(uint64) (*api.Record, error)
+	Partition(string, []byte) (uint32, error)
+	Append(string, uint32, *api.Record) (uint64, error)
+	AppendBatch(string, uint32, []*api.Record) ([]uint64, error)
+	// プロデューサーのIDと、最初のレコードの番号を受け取る
+	AppendIdempotent(string, uint32, uint64, uint64, []*api.Record) ([]uint64, error)
+	InitProducer() (uint64, error)
+	Read(string, uint32, uint64) (*api.Record, error)
+	OffsetForTime(string, uint32, time.Time) (uint64, error)
+	// ログに残っている最も古いオフセットと、次に追加されるオフセットを返す
+	OffsetRange(string, uint32) (uint64, uint64, error)
+	WaitForOffset(context.Context, string, uint32, uint64) error
+	// トピックとパーティションに続けて、コンシューマーグループとオフセットを受け取る
+	CommitOffset(string, uint32, string, uint64) error
+	FetchCommittedOffset(string, uint32, string) (uint64, error)
+}
+
+// トピックが空ならデフォルトのトピックを使う
+type LogDescriber interface {
+	DescribeLog(string, uint32) (*api.NodeLog, error)
+}
+
+type TopicManager interface {
+	CreateTopic(string, *api.TopicConfig) (*api.Topic, error)
+	DeleteTopic(string) error
+	ListTopics() ([]*api.Topic, error)
 }
 
 type Authorizer interface {
@@ -2,9 +2,14 @@ package server
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"net"
 	"os"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -12,15 +17,19 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/hashicorp/raft"
 	"github.com/stretchr/testify/require"
 	api "github.com/yurakawa/proglog/api/v1"
 	"github.com/yurakawa/proglog/internal/auth"
 	"github.com/yurakawa/proglog/internal/config"
 	"github.com/yurakawa/proglog/internal/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var debug = flag.Bool("debug", false, "Enable observability for debugging.")
@@ -48,6 +57,18 @@ func TestServer(t *testing.T) {
 		"produce/consume stream succeeds":                     testProduceConsumeStream,
 		"consume past log boundary fails":                     testConsumePastBoundary,
 		"unauthorized fails":                                  testUnauthorized,
+		"get offset for time succeeds":                        testGetOffsetForTime,
+		"records with the same key go to the same partition":  testPartitions,
+		"produce/consume batch succeeds":                      testProduceConsumeBatch,
+		"consume stream delivers new records quickly":         testConsumeStreamTailLatency,
+		"consume with min offset waits for the record":        testConsumeMinOffset,
+		"create/list/delete topics succeeds":                  testTopics,
+		"consumer group resumes from committed offset":        testConsumerGroup,
+		"retried produce with a producer id is deduplicated":  testIdempotentProduce,
+		"produce stream reports failed records inline":        testProduceStreamErrors,
+		"bounded consume stream closes at the bound":          testConsumeStreamBounds,
+		"consume stream starts from the start position":       testConsumeStreamStartPosition,
+		"describe log reports offsets and segments":           testDescribeLog,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			rootClient, nobodyClient, config, teardown := setupTest(t, nil)
@@ -64,6 +85,18 @@ func setupTest(t *testing.T, fn func(*Config)) (
 	teardown func(),
 ) {
 	t.Helper()
+	rootConn, nobodyConn, cfg, teardown := setupConns(t, fn)
+	return api.NewLogClient(rootConn), api.NewLogClient(nobodyConn), cfg, teardown
+}
+
+// サーバを起動して、rootとnobodyのクライアントの接続を返す。Log以外のサービスのテストで使う
+func setupConns(t *testing.T, fn func(*Config)) (
+	rootConn *grpc.ClientConn,
+	nobodyConn *grpc.ClientConn,
+	cfg *Config,
+	teardown func(),
+) {
+	t.Helper()
 
 	// :0 => 自動的に空きポートを割り当てる
 	l, err := net.Listen("tcp", "127.0.0.1:0")
@@ -90,13 +123,11 @@ func setupTest(t *testing.T, fn func(*Config)) (
 		client := api.NewLogClient(conn)
 		return conn, client, opts
 	}
-	var rootConn *grpc.ClientConn
-	rootConn, rootClient, _ = newClient(
+	rootConn, _, _ = newClient(
 		config.RootClientCertFile,
 		config.RootClientKeyFile,
 	)
-	var nobodyConn *grpc.ClientConn
-	nobodyConn, nobodyClient, _ = newClient(
+	nobodyConn, _, _ = newClient(
 		config.NobodyClientCertFile,
 		config.NobodyClientKeyFile,
 	)
@@ -114,13 +145,15 @@ func setupTest(t *testing.T, fn func(*Config)) (
 	dir, err := os.MkdirTemp("", "server-test")
 	require.NoError(t, err)
 
-	clog, err := log.NewLog(dir, log.Config{})
+	clog, err := log.NewTopics(dir, log.Config{})
 	require.NoError(t, err)
 
 	authorizer := auth.New(config.ACLModelFile, config.ACLPolicyFile)
 	cfg = &Config{
-		CommitLog:  clog,
-		Authorizer: authorizer,
+		CommitLog:    clog,
+		Authorizer:   authorizer,
+		TopicManager: clog,
+		LogDescriber: clog,
 	}
 
 	var telemetryExporter *exporter.LogExporter
@@ -153,7 +186,7 @@ func setupTest(t *testing.T, fn func(*Config)) (
 		server.Serve(l)
 	}()
 
-	return rootClient, nobodyClient, cfg, func() {
+	return rootConn, nobodyConn, cfg, func() {
 		rootConn.Close()
 		nobodyConn.Close()
 		server.Stop()
@@ -259,14 +292,733 @@ func testProduceConsumeStream(
 		for i, record := range records {
 			res, err := stream.Recv()
 			require.NoError(t, err)
-			require.Equal(t, res.Record, &api.Record{
-				Value:  record.Value,
-				Offset: uint64(i),
-			})
+			require.Equal(t, record.Value, res.Record.Value)
+			require.Equal(t, uint64(i), res.Record.Offset)
+			// ログが追加した時刻を設定している
+			require.NotNil(t, res.Record.Timestamp)
 		}
 	}
 }
 
+// ログの末尾に達したConsumeStreamが、新しいレコードをすぐに受け取れることをテストする。
+func testConsumeStreamTailLatency(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 0})
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 3; i++ {
+		// ストリームがログの末尾で待っている状態にする
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		_, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Value: []byte("hello world")},
+		})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, i, res.Record.Offset)
+		require.Less(t, time.Since(start), 100*time.Millisecond)
+	}
+}
+
+func testProduceConsumeBatch(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	var records []*api.Record
+	for i := 0; i < 5; i++ {
+		records = append(records, &api.Record{Value: []byte(fmt.Sprintf("message %d", i))})
+	}
+	produce, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{Records: records})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 1, 2, 3, 4}, produce.Offsets)
+
+	consume, err := client.ConsumeBatch(ctx, &api.ConsumeBatchRequest{Offset: 1, MaxRecords: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(consume.Records))
+	for i, record := range consume.Records {
+		require.Equal(t, uint64(i+1), record.Offset)
+		require.Equal(t, records[i+1].Value, record.Value)
+	}
+
+	// バイト数の上限を超えていても最初のレコードは返す
+	consume, err = client.ConsumeBatch(ctx, &api.ConsumeBatchRequest{Offset: 0, MaxBytes: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(consume.Records))
+
+	// ログの末尾で止まる
+	consume, err = client.ConsumeBatch(ctx, &api.ConsumeBatchRequest{Offset: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(consume.Records))
+
+	_, err = client.ConsumeBatch(ctx, &api.ConsumeBatchRequest{Offset: 5})
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+// min_offsetを指定したConsumeが、そのレコードが追加されるまで待つことと、
+// 時間内に追加されなければUnavailableを返すことをテストする。
+func testConsumeMinOffset(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	minOffset := uint64(0)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Value: []byte("hello world")},
+		})
+	}()
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{
+		Offset:      0,
+		MinOffset:   &minOffset,
+		Consistency: api.Consistency_LINEARIZABLE,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), consume.Record.Value)
+
+	timeout := minOffsetTimeout
+	minOffsetTimeout = 50 * time.Millisecond
+	defer func() { minOffsetTimeout = timeout }()
+	minOffset = 1
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 0, MinOffset: &minOffset})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = client.ConsumeBatch(ctx, &api.ConsumeBatchRequest{Offset: 0, MinOffset: &minOffset})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+// トピックごとに独立したオフセットで読み書きできることと、トピックの作成と削除をテストする。
+func testTopics(
+	t *testing.T,
+	client, nobody api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	_, err := client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders"})
+	require.NoError(t, err)
+	_, err = client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders"})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "a/b"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = nobody.CreateTopic(ctx, &api.CreateTopicRequest{Name: "payments"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	for i, topic := range []string{"orders", "", "orders"} {
+		produce, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Value: []byte(fmt.Sprintf("message %d", i))},
+			Topic:  topic,
+		})
+		require.NoError(t, err)
+		require.Equal(t, uint64(i/2), produce.Offset)
+	}
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: 1, Topic: "orders"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("message 2"), consume.Record.Value)
+	consume, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 0})
+	require.NoError(t, err)
+	require.Equal(t, []byte("message 1"), consume.Record.Value)
+
+	// ProduceStreamではトピックの違うリクエストは別々に追加される
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	for _, topic := range []string{"orders", "", "orders"} {
+		require.NoError(t, stream.Send(&api.ProduceRequest{
+			Record: &api.Record{Value: []byte("hello world")},
+			Topic:  topic,
+		}))
+	}
+	for _, want := range []uint64{2, 1, 3} {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, want, res.Offset)
+	}
+
+	list, err := client.ListTopics(ctx, &api.ListTopicsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list.Topics))
+	require.Equal(t, "orders", list.Topics[1].Name)
+
+	_, err = client.DeleteTopic(ctx, &api.DeleteTopicRequest{Name: "orders"})
+	require.NoError(t, err)
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 0, Topic: "orders"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record: &api.Record{Value: []byte("hello world")},
+		Topic:  "orders",
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.DeleteTopic(ctx, &api.DeleteTopicRequest{Name: log.DefaultTopic})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// キーが同じレコードは同じパーティションに追加され、パーティションごとにオフセットが振られることをテストする。
+// コンシューマーグループがコミットしたオフセットを取得できることと、
+// グループを指定したConsumeStreamがコミットしたオフセットから再開することをテストする。
+func testConsumerGroup(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Value: []byte(fmt.Sprintf("message %d", i))},
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := client.FetchCommittedOffset(ctx, &api.FetchCommittedOffsetRequest{Group: "billing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{Offset: 1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// コミットしていないグループはoffsetから読み出す
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 0, Group: "billing"})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res.Record.Offset)
+
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{Group: "billing", Offset: 2})
+	require.NoError(t, err)
+	fetched, err := client.FetchCommittedOffset(ctx, &api.FetchCommittedOffsetRequest{Group: "billing"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), fetched.Offset)
+
+	// コミットしたグループはoffsetを無視してコミットしたオフセットから読み出す
+	stream, err = client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 0, Group: "billing"})
+	require.NoError(t, err)
+	res, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Record.Offset)
+	require.Equal(t, []byte("message 2"), res.Record.Value)
+
+	// グループごとに別のオフセットを持つ
+	_, err = client.FetchCommittedOffset(ctx, &api.FetchCommittedOffsetRequest{Group: "shipping"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// プロデューサーのIDと番号を指定したレコードが、再送されても重複して追加されないことをテストする。
+func testIdempotentProduce(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	producer, err := client.InitProducer(ctx, &api.InitProducerRequest{})
+	require.NoError(t, err)
+	require.NotZero(t, producer.ProducerId)
+
+	req := &api.ProduceRequest{
+		Record:     &api.Record{Value: []byte("hello world")},
+		ProducerId: producer.ProducerId,
+	}
+	first, err := client.Produce(ctx, req)
+	require.NoError(t, err)
+	retried, err := client.Produce(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, first.Offset, retried.Offset)
+
+	req.Sequence = 2
+	_, err = client.Produce(ctx, req)
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	batch, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{
+		Records: []*api.Record{
+			{Value: []byte("batch 1")},
+			{Value: []byte("batch 2")},
+		},
+		ProducerId: producer.ProducerId,
+		Sequence:   1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, batch.Offsets)
+
+	// ProduceStreamでも、再送されたレコードには最初のオフセットを返す
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	for _, sequence := range []uint64{3, 4, 3} {
+		require.NoError(t, stream.Send(&api.ProduceRequest{
+			Record:     &api.Record{Value: []byte("stream")},
+			ProducerId: producer.ProducerId,
+			Sequence:   sequence,
+		}))
+	}
+	for _, want := range []uint64{3, 4, 3} {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, want, res.Offset)
+	}
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 5})
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func testPartitions(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	_, err := client.CreateTopic(ctx, &api.CreateTopicRequest{
+		Name:   "orders",
+		Config: &api.TopicConfig{Partitions: 3},
+	})
+	require.NoError(t, err)
+
+	key := []byte("customer-1")
+	want := api.PartitionForKey(key, 3)
+	for i := uint64(0); i < 2; i++ {
+		produce, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{Key: key, Value: []byte("order")},
+			Topic:  "orders",
+		})
+		require.NoError(t, err)
+		require.Equal(t, want, produce.Partition)
+		require.Equal(t, i, produce.Offset)
+	}
+	other := (want + 1) % 3
+	produce, err := client.Produce(ctx, &api.ProduceRequest{
+		Record:    &api.Record{Key: key, Value: []byte("explicit")},
+		Topic:     "orders",
+		Partition: &other,
+	})
+	require.NoError(t, err)
+	require.Equal(t, other, produce.Partition)
+	require.Equal(t, uint64(0), produce.Offset)
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{
+		Topic:     "orders",
+		Partition: other,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("explicit"), consume.Record.Value)
+
+	// ProduceStreamではパーティションの違うリクエストは別々に追加される
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	for _, p := range []uint32{want, other, want} {
+		p := p
+		require.NoError(t, stream.Send(&api.ProduceRequest{
+			Record:    &api.Record{Value: []byte("hello world")},
+			Topic:     "orders",
+			Partition: &p,
+		}))
+	}
+	for _, w := range []struct {
+		partition uint32
+		offset    uint64
+	}{{want, 2}, {other, 1}, {want, 3}} {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, w.partition, res.Partition)
+		require.Equal(t, w.offset, res.Offset)
+	}
+
+	missing := uint32(3)
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:    &api.Record{Value: []byte("order")},
+		Topic:     "orders",
+		Partition: &missing,
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Topic: "orders", Partition: missing})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// ProduceStreamで追加できなかったレコードはレスポンスでエラーを返し、ストリームは閉じないことをテストする。
+func testProduceStreamErrors(t *testing.T, client, nobodyClient api.LogClient, config *Config) {
+	ctx := context.Background()
+	producer, err := client.InitProducer(ctx, &api.InitProducerRequest{})
+	require.NoError(t, err)
+	missing := uint32(5)
+	reqs := []*api.ProduceRequest{
+		{Record: &api.Record{Value: []byte("first")}},
+		{Record: &api.Record{Value: []byte("no topic")}, Topic: "missing"},
+		{Record: &api.Record{Value: []byte("second")}},
+		{Record: &api.Record{Value: []byte("no partition")}, Partition: &missing},
+		{Record: &api.Record{Value: []byte("third")}, ProducerId: producer.ProducerId, Sequence: 0},
+		{Record: &api.Record{Value: []byte("skipped sequence")}, ProducerId: producer.ProducerId, Sequence: 5},
+		{Record: &api.Record{Value: []byte("fourth")}, ProducerId: producer.ProducerId, Sequence: 1},
+	}
+	want := []struct {
+		code   codes.Code
+		offset uint64
+	}{
+		{codes.OK, 0},
+		{codes.NotFound, 0},
+		{codes.OK, 1},
+		{codes.NotFound, 0},
+		{codes.OK, 2},
+		{codes.Aborted, 0},
+		{codes.OK, 3},
+	}
+	stream, err := client.ProduceStream(ctx)
+	require.NoError(t, err)
+	for i, req := range reqs {
+		req.CorrelationId = uint64(i + 1)
+		require.NoError(t, stream.Send(req))
+	}
+	for i, w := range want {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(i+1), res.CorrelationId)
+		require.Equal(t, w.code, status.Code(res.Error.Err()), res.Error.GetMessage())
+		require.Equal(t, w.offset, res.Offset)
+	}
+	// 失敗した後も同じストリームで追加でき、送信を終えると正常に閉じる
+	require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("fifth")}, CorrelationId: 8}))
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Nil(t, res.Error)
+	require.Equal(t, uint64(4), res.Offset)
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	// 権限がなくても、レコードごとにエラーを返す
+	stream, err = nobodyClient.ProduceStream(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, stream.Send(&api.ProduceRequest{Record: &api.Record{Value: []byte("denied")}}))
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, codes.PermissionDenied, status.Code(res.Error.Err()))
+	}
+}
+
+// end_offset、max_records、max_bytesのどれかに達すると、ConsumeStreamが正常に閉じることをテストする。
+func testConsumeStreamBounds(t *testing.T, client, _ api.LogClient, config *Config) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("hello world")}})
+		require.NoError(t, err)
+	}
+	size := uint64(proto.Size(&api.Record{Value: []byte("hello world"), Offset: 1, Timestamp: timestamppb.Now()}))
+	end := uint64(3)
+	for scenario, tc := range map[string]struct {
+		req  *api.ConsumeRequest
+		want []uint64
+	}{
+		"end offset":       {&api.ConsumeRequest{Offset: 1, EndOffset: &end}, []uint64{1, 2}},
+		"empty range":      {&api.ConsumeRequest{Offset: 3, EndOffset: &end}, nil},
+		"max records":      {&api.ConsumeRequest{Offset: 2, MaxRecords: 2}, []uint64{2, 3}},
+		"max bytes":        {&api.ConsumeRequest{Offset: 0, MaxBytes: size*2 + 1}, []uint64{0, 1}},
+		"first over bytes": {&api.ConsumeRequest{Offset: 4, MaxBytes: 1}, []uint64{4}},
+		"tightest bound":   {&api.ConsumeRequest{Offset: 0, EndOffset: &end, MaxRecords: 10}, []uint64{0, 1, 2}},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			require.Equal(t, tc.want, consumeAll(t, client, tc.req))
+		})
+	}
+}
+
+// ConsumeStreamがstart_positionの位置から読み出すことをテストする。
+func testConsumeStreamStartPosition(t *testing.T, client, _ api.LogClient, config *Config) {
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("before")}})
+		require.NoError(t, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("after")}})
+	require.NoError(t, err)
+
+	end := uint64(3)
+	for position, want := range map[api.StartPosition][]uint64{
+		api.StartPosition_OFFSET:    {1, 2},
+		api.StartPosition_EARLIEST:  {0, 1, 2},
+		api.StartPosition_TIMESTAMP: {2},
+	} {
+		require.Equal(t, want, consumeAll(t, client, &api.ConsumeRequest{
+			Offset:        1,
+			StartPosition: position,
+			StartTime:     timestamppb.New(start),
+			EndOffset:     &end,
+		}), position.String())
+	}
+
+	// 末尾から読み出すと、ストリームを始めた後に追加されたレコードだけを受け取る
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{StartPosition: api.StartPosition_LATEST, MaxRecords: 1})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	produce, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("latest")}})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, produce.Offset, res.Record.Offset)
+	require.Equal(t, []byte("latest"), res.Record.Value)
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	stream, err = client.ConsumeStream(ctx, &api.ConsumeRequest{StartPosition: api.StartPosition_TIMESTAMP})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// ConsumeStreamが閉じるまでに受け取ったレコードのオフセットを返す。
+func consumeAll(t *testing.T, client api.LogClient, req *api.ConsumeRequest) []uint64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stream, err := client.ConsumeStream(ctx, req)
+	require.NoError(t, err)
+	var offsets []uint64
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return offsets
+		}
+		require.NoError(t, err)
+		offsets = append(offsets, res.Record.Offset)
+	}
+}
+
+// DescribeLogがログのオフセットの範囲とセグメントを返すことをテストする。
+func testDescribeLog(t *testing.T, client, nobodyClient api.LogClient, config *Config) {
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("hello world")}})
+		require.NoError(t, err)
+	}
+	// 単一ノードではclusterを指定してもこのノードだけを返す
+	for _, cluster := range []bool{false, true} {
+		res, err := client.DescribeLog(ctx, &api.DescribeLogRequest{Cluster: cluster})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(res.Nodes))
+		node := res.Nodes[0]
+		require.Equal(t, uint64(0), node.LowestOffset)
+		require.Equal(t, uint64(2), node.HighestOffset)
+		require.Equal(t, uint64(3), node.NextOffset)
+		require.Equal(t, 1, len(node.Segments))
+		require.Equal(t, uint64(3), node.Segments[0].NextOffset)
+		require.Less(t, uint64(0), node.Segments[0].StoreBytes)
+		require.Less(t, uint64(0), node.Segments[0].IndexBytes)
+		require.Nil(t, node.Raft)
+	}
+
+	_, err := client.DescribeLog(ctx, &api.DescribeLogRequest{Partition: 1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = nobodyClient.DescribeLog(ctx, &api.DescribeLogRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// clusterを指定したDescribeLogを、メタデータのグループのリーダーだけがまとめて返すことをテストする。
+func TestDescribeLogCluster(t *testing.T) {
+	servers := &fixedServerer{servers: []*api.Server{
+		{Id: "0", RpcAddr: "127.0.0.1:1"},
+		{Id: "1", RpcAddr: "127.0.0.1:2"},
+	}}
+	client, _, _, teardown := setupTest(t, func(c *Config) {
+		c.GetServerer = servers
+		c.LogDescriber = &namedDescriber{LogDescriber: c.LogDescriber, id: "0"}
+	})
+	defer teardown()
+	ctx := context.Background()
+
+	// リーダーでなければ、リーダーのアドレスを返す
+	servers.servers[1].IsLeader = true
+	_, err := client.DescribeLog(ctx, &api.DescribeLogRequest{Cluster: true})
+	requireStatus(t, err, codes.FailedPrecondition, true, "127.0.0.1:2")
+	// clusterを指定しなければリーダーでなくても返す
+	res, err := client.DescribeLog(ctx, &api.DescribeLogRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res.Nodes))
+
+	// 状態を取得できないノードはエラーとして返す
+	servers.servers[0].IsLeader, servers.servers[1].IsLeader = true, false
+	res, err = client.DescribeLog(ctx, &api.DescribeLogRequest{Cluster: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(res.Nodes))
+	require.Equal(t, "0", res.Nodes[0].Id)
+	require.Empty(t, res.Nodes[0].Error)
+	require.Equal(t, "1", res.Nodes[1].Id)
+	require.Equal(t, "127.0.0.1:2", res.Nodes[1].RpcAddr)
+	require.NotEmpty(t, res.Nodes[1].Error)
+}
+
+type fixedServerer struct {
+	servers []*api.Server
+}
+
+func (s *fixedServerer) GetServers() ([]*api.Server, error) {
+	return s.servers, nil
+}
+
+func (s *fixedServerer) GetPartitions() ([]*api.Partition, error) {
+	return nil, nil
+}
+
+// 返すNodeLogにノードのIDをつける
+type namedDescriber struct {
+	LogDescriber
+	id string
+}
+
+func (d *namedDescriber) DescribeLog(topic string, partition uint32) (*api.NodeLog, error) {
+	nl, err := d.LogDescriber.DescribeLog(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	nl.Id = d.id
+	return nl, nil
+}
+
+// ログの先頭より前から読み出そうとすると、読み出せる範囲をつけたエラーを返すことをテストする。
+func TestConsumeStreamBeforeLowest(t *testing.T) {
+	client, _, _, teardown := setupTest(t, func(c *Config) {
+		c.CommitLog = &truncatedCommitLog{CommitLog: c.CommitLog, lowest: 2}
+	})
+	defer teardown()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("hello world")}})
+		require.NoError(t, err)
+	}
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	st := status.Convert(err)
+	require.Equal(t, codes.OutOfRange, st.Code())
+	var info *errdetails.ErrorInfo
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.ErrorInfo); ok {
+			info = d
+		}
+	}
+	require.NotNil(t, info)
+	require.Equal(t, map[string]string{"lowest_offset": "2", "next_offset": "3"}, info.Metadata)
+
+	// 先頭から読み出すときは、残っている最も古いオフセットから始める
+	end := uint64(3)
+	require.Equal(t, []uint64{2}, consumeAll(t, client, &api.ConsumeRequest{
+		StartPosition: api.StartPosition_EARLIEST,
+		EndOffset:     &end,
+	}))
+}
+
+// ログの先頭のlowestより前のレコードが、保持期間で削除されたことにする
+type truncatedCommitLog struct {
+	CommitLog
+	lowest uint64
+}
+
+func (c *truncatedCommitLog) OffsetRange(topic string, partition uint32) (uint64, uint64, error) {
+	_, next, err := c.CommitLog.OffsetRange(topic, partition)
+	return c.lowest, next, err
+}
+
+// 追加している間に届いたProduceStreamのリクエストがまとめて追加されることをテストする。
+func TestProduceStreamBatching(t *testing.T) {
+	var clog *slowCommitLog
+	client, _, _, teardown := setupTest(t, func(c *Config) {
+		clog = &slowCommitLog{CommitLog: c.CommitLog}
+		c.CommitLog = clog
+	})
+	defer teardown()
+
+	stream, err := client.ProduceStream(context.Background())
+	require.NoError(t, err)
+	n := 10
+	for i := 0; i < n; i++ {
+		require.NoError(t, stream.Send(&api.ProduceRequest{
+			Record: &api.Record{Value: []byte("hello world")},
+		}))
+	}
+	for i := 0; i < n; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), res.Offset)
+	}
+	require.Less(t, int(atomic.LoadInt32(&clog.batches)), n)
+}
+
+// 最初の追加だけ遅くして、その間にリクエストを溜めさせる
+type slowCommitLog struct {
+	CommitLog
+	batches int32
+}
+
+func (c *slowCommitLog) AppendBatch(topic string, partition uint32, records []*api.Record) ([]uint64, error) {
+	if atomic.AddInt32(&c.batches, 1) == 1 {
+		time.Sleep(100 * time.Millisecond)
+	}
+	return c.CommitLog.AppendBatch(topic, partition, records)
+}
+
+// ログが返したエラーが、送り直せるかどうかを表すステータスに変換されることをテストする。
+func TestErrorStatus(t *testing.T) {
+	clog := &failingCommitLog{}
+	client, _, _, teardown := setupTest(t, func(c *Config) {
+		clog.CommitLog = c.CommitLog
+		c.CommitLog = clog
+	})
+	defer teardown()
+
+	for _, tc := range []struct {
+		err        error
+		code       codes.Code
+		retry      bool
+		leaderAddr string
+	}{
+		{raft.ErrLeadershipLost, codes.Unavailable, true, ""},
+		{api.ErrUnavailable{Reason: "leadership lost", LeaderAddr: "127.0.0.1:8400"}, codes.Unavailable, true, "127.0.0.1:8400"},
+		{raft.ErrEnqueueTimeout, codes.DeadlineExceeded, true, ""},
+		{api.ErrNotLeader{LeaderAddr: "127.0.0.1:8400"}, codes.FailedPrecondition, true, "127.0.0.1:8400"},
+		{fmt.Errorf("write segment: %w", syscall.ENOSPC), codes.ResourceExhausted, true, ""},
+		{io.EOF, codes.DataLoss, false, ""},
+		{api.ErrCorruptRecord{Offset: 1}, codes.DataLoss, false, ""},
+		{errors.New("boom"), codes.Internal, false, ""},
+	} {
+		clog.err = tc.err
+		_, err := client.Consume(context.Background(), &api.ConsumeRequest{})
+		requireStatus(t, err, tc.code, tc.retry, tc.leaderAddr)
+
+		stream, err := client.ConsumeStream(context.Background(), &api.ConsumeRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		requireStatus(t, err, tc.code, tc.retry, tc.leaderAddr)
+	}
+}
+
+func requireStatus(t *testing.T, err error, code codes.Code, retry bool, leaderAddr string) {
+	t.Helper()
+	st := status.Convert(err)
+	require.Equal(t, code, st.Code(), st.Message())
+	var retryInfo *errdetails.RetryInfo
+	var addr string
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.RetryInfo:
+			retryInfo = d
+		case *errdetails.ErrorInfo:
+			addr = d.Metadata["leader_addr"]
+		}
+	}
+	require.Equal(t, retry, retryInfo != nil)
+	require.Equal(t, leaderAddr, addr)
+}
+
+// Readでerrを返す
+type failingCommitLog struct {
+	CommitLog
+	err error
+}
+
+func (c *failingCommitLog) Read(string, uint32, uint64) (*api.Record, error) {
+	return nil, c.err
+}
+
 func testUnauthorized(
 	t *testing.T,
 	_,
@@ -297,4 +1049,48 @@ func testUnauthorized(
 	if gotCode != wantCode {
 		t.Fatalf("got code: %d, want: %d", gotCode, wantCode)
 	}
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{Group: "group", Offset: 1})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.InitProducer(ctx, &api.InitProducerRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func testGetOffsetForTime(
+	t *testing.T,
+	client, _ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+	before := time.Now()
+	var offsets []uint64
+	for i := 0; i < 3; i++ {
+		produce, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.Record{
+				Value: []byte("hello world"),
+			},
+		})
+		require.NoError(t, err)
+		offsets = append(offsets, produce.Offset)
+	}
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: offsets[1]})
+	require.NoError(t, err)
+
+	res, err := client.GetOffsetForTime(ctx, &api.GetOffsetForTimeRequest{
+		Time: timestamppb.New(before),
+	})
+	require.NoError(t, err)
+	require.Equal(t, offsets[0], res.Offset)
+
+	res, err = client.GetOffsetForTime(ctx, &api.GetOffsetForTimeRequest{
+		Time: consume.Record.Timestamp,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Offset <= offsets[1])
+
+	// 未来の時刻なら次に追加されるオフセット
+	res, err = client.GetOffsetForTime(ctx, &api.GetOffsetForTimeRequest{
+		Time: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, offsets[2]+1, res.Offset)
 }